@@ -2,52 +2,149 @@ package game
 
 import (
 	"errors"
+	"fmt"
+	"hash/maphash"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // AlphaBetaEngine performs a depth-limited minimax search with material-only evaluation.
 type AlphaBetaEngine struct {
-	search *alphaBetaSearch
+	// Workers searches the root moves concurrently when greater than one.
+	Workers int
+	search  *alphaBetaSearch
 }
 
-func NewAlphaBetaEngine(depth int) *AlphaBetaEngine {
-	return &AlphaBetaEngine{
-		search: newAlphaBetaSearch(depth, materialEvaluation),
+func NewAlphaBetaEngine(depth int) (*AlphaBetaEngine, error) {
+	if err := checkSearchDepth(depth); err != nil {
+		return nil, err
 	}
+	return &AlphaBetaEngine{
+		search: newAlphaBetaSearch(depth, EvalConfig{}.function()),
+	}, nil
+}
+
+// SetHandMultiplier weights pieces in hand in the evaluation; see EvalConfig. Call it
+// before the engine searches.
+func (e *AlphaBetaEngine) SetHandMultiplier(multiplier float64) {
+	e.search.evaluate = EvalConfig{HandMultiplier: multiplier}.function()
 }
 
 func (e *AlphaBetaEngine) NextMove(state GameState) (Move, error) {
-	return e.search.nextMove(state)
+	return e.search.nextMove(state, e.Workers)
+}
+
+func (e *AlphaBetaEngine) LastSearch() SearchInfo {
+	return e.search.last
 }
 
 // MobilityAlphaBetaEngine adds a mobility-aware evaluation on top of alpha-beta search.
 type MobilityAlphaBetaEngine struct {
-	search *alphaBetaSearch
+	// Workers searches the root moves concurrently when greater than one.
+	Workers int
+	search  *alphaBetaSearch
 }
 
-func NewMobilityAlphaBetaEngine(depth int) *MobilityAlphaBetaEngine {
-	return &MobilityAlphaBetaEngine{
-		search: newAlphaBetaSearch(depth, mobilityEvaluation),
+func NewMobilityAlphaBetaEngine(depth int) (*MobilityAlphaBetaEngine, error) {
+	if err := checkSearchDepth(depth); err != nil {
+		return nil, err
 	}
+	return &MobilityAlphaBetaEngine{
+		search: newAlphaBetaSearch(depth, EvalConfig{Evaluation: EvaluationMobility}.function()),
+	}, nil
+}
+
+// SetHandMultiplier weights pieces in hand in the evaluation; see EvalConfig. Call it
+// before the engine searches.
+func (e *MobilityAlphaBetaEngine) SetHandMultiplier(multiplier float64) {
+	e.search.evaluate = EvalConfig{Evaluation: EvaluationMobility, HandMultiplier: multiplier}.function()
 }
 
 func (e *MobilityAlphaBetaEngine) NextMove(state GameState) (Move, error) {
-	return e.search.nextMove(state)
+	return e.search.nextMove(state, e.Workers)
+}
+
+func (e *MobilityAlphaBetaEngine) LastSearch() SearchInfo {
+	return e.search.last
 }
 
-type evaluationFunc func(GameState, Player, int) int
+type evaluationFunc func(*searchNode, Player, int) int
 
 type alphaBetaSearch struct {
 	depth    int
-	table    map[stateKey]ttEntry
+	table    *transpositionTable
 	evaluate evaluationFunc
+	// nodes and moveGenerations count visited positions and move generation calls.
+	nodes           atomic.Int64
+	moveGenerations atomic.Int64
+	// last describes the move returned by the most recent nextMove.
+	last SearchInfo
+}
+
+// searchNode generates the side to move's legal moves at most once and shares them
+// between terminal detection, move ordering, the search loop and evaluation.
+// kings is passed down from the parent so the board is not rescanned for them.
+type searchNode struct {
+	search    *alphaBetaSearch
+	state     GameState
+	kings     kingSquares
+	legal     []Move
+	generated bool
+}
+
+func (s *alphaBetaSearch) newNode(state GameState) *searchNode {
+	return s.newNodeWithKings(state, findKings(state))
+}
+
+func (s *alphaBetaSearch) newNodeWithKings(state GameState, kings kingSquares) *searchNode {
+	s.nodes.Add(1)
+	return &searchNode{search: s, state: state, kings: kings}
+}
+
+func (n *searchNode) legalMoves() []Move {
+	if !n.generated {
+		n.search.moveGenerations.Add(1)
+		n.legal = n.generate(n.state.Turn)
+		n.generated = true
+	}
+	return n.legal
+}
+
+func (n *searchNode) generate(player Player) []Move {
+	return generateLegalMoves(n.state, player, n.kings.pos[player], n.kings.found[player])
+}
+
+func (n *searchNode) inCheck(player Player) bool {
+	return n.kings.inCheck(&n.state.Board, player)
+}
+
+// movesFor returns player's legal moves, reusing the cached list for the side to move.
+func (n *searchNode) movesFor(player Player) []Move {
+	if player == n.state.Turn {
+		return n.legalMoves()
+	}
+	n.search.moveGenerations.Add(1)
+	return n.generate(player)
+}
+
+// hasLegalMove reports whether the side to move can move, without a full generation
+// unless the moves are already cached.
+func (n *searchNode) hasLegalMove() bool {
+	if n.generated {
+		return len(n.legal) > 0
+	}
+	n.search.moveGenerations.Add(1)
+	turn := n.state.Turn
+	return hasLegalMove(n.state, turn, n.kings.pos[turn], n.kings.found[turn])
 }
 
 func newAlphaBetaSearch(depth int, evaluate evaluationFunc) *alphaBetaSearch {
 	return &alphaBetaSearch{
 		depth:    depth,
-		table:    make(map[stateKey]ttEntry),
+		table:    newTranspositionTable(),
 		evaluate: evaluate,
 	}
 }
@@ -74,30 +171,178 @@ type stateKey struct {
 	maximizer Player
 }
 
+const ttShardCount = 64
+
+// transpositionTable is sharded by key hash so parallel root workers can share it
+// without serializing on a single lock.
+type transpositionTable struct {
+	seed   maphash.Seed
+	shards [ttShardCount]ttShard
+}
+
+type ttShard struct {
+	mu      sync.Mutex
+	entries map[stateKey]ttEntry
+}
+
+func newTranspositionTable() *transpositionTable {
+	table := &transpositionTable{seed: maphash.MakeSeed()}
+	for i := range table.shards {
+		table.shards[i].entries = make(map[stateKey]ttEntry)
+	}
+	return table
+}
+
+func (t *transpositionTable) shard(key stateKey) *ttShard {
+	return &t.shards[maphash.String(t.seed, key.boardKey)%ttShardCount]
+}
+
+func (t *transpositionTable) get(key stateKey) (ttEntry, bool) {
+	shard := t.shard(key)
+	shard.mu.Lock()
+	entry, ok := shard.entries[key]
+	shard.mu.Unlock()
+	return entry, ok
+}
+
+func (t *transpositionTable) put(key stateKey, entry ttEntry) {
+	shard := t.shard(key)
+	shard.mu.Lock()
+	shard.entries[key] = entry
+	shard.mu.Unlock()
+}
+
 const (
 	checkmateScore = 100000
 	infiniteScore  = 1_000_000_000
 )
 
-func (s *alphaBetaSearch) nextMove(state GameState) (Move, error) {
+func (s *alphaBetaSearch) nextMove(state GameState, workers int) (Move, error) {
 	moves := GenerateLegalMoves(state, state.Turn)
 	if len(moves) == 0 {
 		return Move{}, errors.New("no legal moves to play")
 	}
 	if s.table == nil {
-		s.table = make(map[stateKey]ttEntry)
+		s.table = newTranspositionTable()
 	}
-	_, best := s.search(state, s.depth, -infiniteScore, infiniteScore, state.Turn)
-	if best == nil {
+	var best rootChoice
+	if workers > 1 {
+		best = s.searchRootParallel(state, moves, workers)
+	} else {
+		best = s.searchRoot(state, moves)
+	}
+	if !best.found {
 		return Move{}, errors.New("failed to find a move")
 	}
-	return *best, nil
+	s.last = SearchInfo{Depth: s.depth, Score: best.score}
+	return best.move, nil
+}
+
+// rootChoice tracks the best root move. Equal scores are broken deterministically so the
+// choice does not depend on move generation order: the move with the higher static
+// evaluation of the resulting position for the mover wins, then the lexicographically
+// smaller FormatMove string.
+type rootChoice struct {
+	move  Move
+	score int
+	eval  int
+	found bool
+}
+
+func (c *rootChoice) consider(s *alphaBetaSearch, next GameState, mv Move, score int, maximizer Player) {
+	if c.found && score < c.score {
+		return
+	}
+	eval := s.evaluate(s.newNode(next), maximizer, 0)
+	if c.found && score == c.score && !preferOnTie(mv, eval, c.move, c.eval) {
+		return
+	}
+	*c = rootChoice{move: mv, score: score, eval: eval, found: true}
+}
+
+func preferOnTie(candidate Move, candidateEval int, current Move, currentEval int) bool {
+	if candidateEval != currentEval {
+		return candidateEval > currentEval
+	}
+	return FormatMove(candidate) < FormatMove(current)
+}
+
+// searchRoot searches the root moves serially. legal must be the legal moves of state so
+// the root list is generated only once per call.
+func (s *alphaBetaSearch) searchRoot(state GameState, legal []Move) rootChoice {
+	maximizer := state.Turn
+	var best rootChoice
+	if s.depth <= 0 {
+		return best
+	}
+	alpha := -infiniteScore
+	kings := findKings(state)
+	for _, mv := range legal {
+		next := childState(state, mv)
+		score, _ := s.search(next, kings.after(&state.Board, mv), s.depth-1, alpha, infiniteScore, maximizer)
+		best.consider(s, next, mv, score, maximizer)
+		// Keep alpha one below the best score so later ties are searched exactly.
+		if best.found && best.score-1 > alpha {
+			alpha = best.score - 1
+		}
+	}
+	s.storeRoot(state, best)
+	return best
+}
+
+// searchRootParallel searches each root move with a full window on a worker pool sharing
+// the transposition table, then combines the scores in generation order.
+func (s *alphaBetaSearch) searchRootParallel(state GameState, legal []Move, workers int) rootChoice {
+	maximizer := state.Turn
+	var best rootChoice
+	if s.depth <= 0 {
+		return best
+	}
+	children := make([]GameState, len(legal))
+	scores := make([]int, len(legal))
+	jobs := make(chan int)
+	kings := findKings(state)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				children[idx] = childState(state, legal[idx])
+				scores[idx], _ = s.search(children[idx], kings.after(&state.Board, legal[idx]), s.depth-1, -infiniteScore, infiniteScore, maximizer)
+			}
+		}()
+	}
+	for idx := range legal {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+	for idx, mv := range legal {
+		best.consider(s, children[idx], mv, scores[idx], maximizer)
+	}
+	s.storeRoot(state, best)
+	return best
 }
 
-func (s *alphaBetaSearch) search(state GameState, depth int, alpha, beta int, maximizer Player) (int, *Move) {
+func (s *alphaBetaSearch) storeRoot(state GameState, best rootChoice) {
+	if best.found {
+		s.table.put(makeStateKey(state, state.Turn), makeEntry(best.score, s.depth, boundExact, &best.move))
+	}
+}
+
+func childState(state GameState, mv Move) GameState {
+	next := CloneState(state)
+	ApplyMove(&next, mv)
+	next.Turn = next.Turn.Opponent()
+	return next
+}
+
+func (s *alphaBetaSearch) search(state GameState, kings kingSquares, depth int, alpha, beta int, maximizer Player) (int, *Move) {
 	alphaOrig, betaOrig := alpha, beta
+	node := s.newNodeWithKings(state, kings)
 	key := makeStateKey(state, maximizer)
-	if entry, ok := s.table[key]; ok && entry.depth >= depth {
+	if entry, ok := s.table.get(key); ok && entry.depth >= depth {
 		switch entry.bound {
 		case boundExact:
 			return entry.score, duplicateEntryMove(entry)
@@ -116,18 +361,19 @@ func (s *alphaBetaSearch) search(state GameState, depth int, alpha, beta int, ma
 	}
 
 	if depth == 0 {
-		score := s.evaluate(state, maximizer, depth)
-		s.table[key] = ttEntry{depth: depth, score: score, bound: boundExact}
+		score := s.evaluate(node, maximizer, depth)
+		s.table.put(key, ttEntry{depth: depth, score: score, bound: boundExact})
 		return score, nil
 	}
 
-	legal := GenerateLegalMoves(state, state.Turn)
+	legal := node.legalMoves()
 	if len(legal) == 0 {
-		score := s.evaluate(state, maximizer, depth)
-		s.table[key] = ttEntry{depth: depth, score: score, bound: boundExact}
+		score := noMovesScore(state, maximizer, depth)
+		s.table.put(key, ttEntry{depth: depth, score: score, bound: boundExact})
 		return score, nil
 	}
 
+	orderMoves(state, legal)
 	var chosen *Move
 	if state.Turn == maximizer {
 		bestScore := -infiniteScore
@@ -136,7 +382,7 @@ func (s *alphaBetaSearch) search(state GameState, depth int, alpha, beta int, ma
 			ApplyMove(&next, mv)
 			next.Turn = next.Turn.Opponent()
 
-			score, _ := s.search(next, depth-1, alpha, beta, maximizer)
+			score, _ := s.search(next, kings.after(&state.Board, mv), depth-1, alpha, beta, maximizer)
 			if score > bestScore {
 				bestScore = score
 				mvCopy := mv
@@ -150,7 +396,7 @@ func (s *alphaBetaSearch) search(state GameState, depth int, alpha, beta int, ma
 			}
 		}
 		bound := determineBound(bestScore, alphaOrig, betaOrig)
-		s.table[key] = makeEntry(bestScore, depth, bound, chosen)
+		s.table.put(key, makeEntry(bestScore, depth, bound, chosen))
 		return bestScore, chosen
 	}
 
@@ -160,7 +406,7 @@ func (s *alphaBetaSearch) search(state GameState, depth int, alpha, beta int, ma
 		ApplyMove(&next, mv)
 		next.Turn = next.Turn.Opponent()
 
-		score, _ := s.search(next, depth-1, alpha, beta, maximizer)
+		score, _ := s.search(next, kings.after(&state.Board, mv), depth-1, alpha, beta, maximizer)
 		if score < bestScore {
 			bestScore = score
 			mvCopy := mv
@@ -174,10 +420,37 @@ func (s *alphaBetaSearch) search(state GameState, depth int, alpha, beta int, ma
 		}
 	}
 	bound := determineBound(bestScore, alphaOrig, betaOrig)
-	s.table[key] = makeEntry(bestScore, depth, bound, chosen)
+	s.table.put(key, makeEntry(bestScore, depth, bound, chosen))
 	return bestScore, chosen
 }
 
+// orderMoves sorts moves in place so captures that win material by SEE come first, quiet
+// moves next and captures that lose material last. The order within each group is kept.
+func orderMoves(state GameState, moves []Move) {
+	type rankedMove struct {
+		move Move
+		rank int
+	}
+	ranked := make([]rankedMove, len(moves))
+	for i, mv := range moves {
+		ranked[i].move = mv
+		if mv.From == nil || !state.Board[mv.To.Y][mv.To.X].Present {
+			continue
+		}
+		if see := SEE(state, mv); see >= 0 {
+			ranked[i].rank = -1 - see
+		} else {
+			ranked[i].rank = 1
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].rank < ranked[j].rank
+	})
+	for i := range ranked {
+		moves[i] = ranked[i].move
+	}
+}
+
 func determineBound(score, alphaOrig, betaOrig int) boundType {
 	switch {
 	case score <= alphaOrig:
@@ -246,30 +519,155 @@ func encodeState(state GameState) string {
 	return b.String()
 }
 
+// Evaluation names a static evaluation shared by the searches.
+type Evaluation int
+
+const (
+	// EvaluationMaterial scores material and checks.
+	EvaluationMaterial Evaluation = iota
+	// EvaluationMobility adds the difference in legal move counts to EvaluationMaterial.
+	EvaluationMobility
+)
+
+// ParseEvaluation maps "material" (or "") and "mobility" to an Evaluation.
+func ParseEvaluation(name string) (Evaluation, error) {
+	switch name {
+	case "", "material":
+		return EvaluationMaterial, nil
+	case "mobility":
+		return EvaluationMobility, nil
+	default:
+		return EvaluationMaterial, fmt.Errorf("unknown evaluation %q", name)
+	}
+}
+
+func (ev Evaluation) String() string {
+	if ev == EvaluationMobility {
+		return "mobility"
+	}
+	return "material"
+}
+
+// EvalConfig configures a static evaluation. The zero value is EvaluationMaterial with pieces
+// in hand worth as much as on the board.
+type EvalConfig struct {
+	Evaluation Evaluation
+	// HandMultiplier scales the value of pieces in hand relative to pieces on the board.
+	// Above 1 favours keeping pieces for drops; below 1 favours board presence. 0 means 1.
+	HandMultiplier float64
+}
+
+func (c EvalConfig) handMultiplier() float64 {
+	if c.HandMultiplier == 0 {
+		return 1
+	}
+	return c.HandMultiplier
+}
+
+func (c EvalConfig) function() evaluationFunc {
+	hand := c.handMultiplier()
+	if c.Evaluation == EvaluationMobility {
+		return func(node *searchNode, maximizer Player, depth int) int {
+			return mobilityEvaluation(node, maximizer, depth, hand)
+		}
+	}
+	return func(node *searchNode, maximizer Player, depth int) int {
+		return materialEvaluation(node, maximizer, depth, hand)
+	}
+}
+
+// Evaluate returns the score of state under c from Bottom's point of view after a
+// depth-limited alpha-beta search. Positive values favour Bottom.
+func (c EvalConfig) Evaluate(state GameState, depth int) int {
+	search := newAlphaBetaSearch(depth, c.function())
+	score, _ := search.search(state, findKings(state), depth, -infiniteScore, infiniteScore, Bottom)
+	return score
+}
+
+// Evaluate is EvalConfig.Evaluate with the default material evaluation.
+func Evaluate(state GameState, depth int) int {
+	return EvalConfig{}.Evaluate(state, depth)
+}
+
+// staticScore evaluates state for player with evaluate without searching. The
+// throwaway search only carries counters, so no transposition table is allocated.
+func staticScore(state GameState, player Player, evaluate evaluationFunc) int {
+	search := &alphaBetaSearch{evaluate: evaluate}
+	return evaluate(search.newNode(state), player, 0)
+}
+
 const mobilityWeight = 2
 
-func materialEvaluation(state GameState, maximizer Player, depth int) int {
-	if IsCheckmate(state, maximizer) {
+// noMovesScore scores a position whose side to move has no legal moves. It is a loss for
+// that side whether or not it is in check, so the search avoids running out of moves.
+func noMovesScore(state GameState, maximizer Player, depth int) int {
+	if state.Turn == maximizer {
 		return -checkmateScore - depth
 	}
-	if IsCheckmate(state, maximizer.Opponent()) {
-		return checkmateScore + depth
+	return checkmateScore + depth
+}
+
+func materialEvaluation(node *searchNode, maximizer Player, depth int, handMultiplier float64) int {
+	state := node.state
+	if !node.hasLegalMove() {
+		return noMovesScore(state, maximizer, depth)
 	}
 
-	score := materialBalance(state, maximizer)
-	if InCheck(state, maximizer) {
-		score -= 5
+	score := materialBalance(state, maximizer, handMultiplier)
+	if node.inCheck(maximizer) {
+		score -= checkBonus
 	}
-	if InCheck(state, maximizer.Opponent()) {
-		score += 5
+	if node.inCheck(maximizer.Opponent()) {
+		score += checkBonus
 	}
 	return score
 }
 
-func mobilityEvaluation(state GameState, maximizer Player, depth int) int {
-	score := materialEvaluation(state, maximizer, depth)
-	myMoves := len(GenerateLegalMoves(state, maximizer))
-	opponentMoves := len(GenerateLegalMoves(state, maximizer.Opponent()))
+// checkBonus is what materialEvaluation adds for checking the opponent and subtracts for
+// being in check.
+const checkBonus = 5
+
+// EvalBreakdown splits the static material evaluation into its terms. Terminal replaces
+// the other terms when the side to move has no legal moves.
+type EvalBreakdown struct {
+	Material int `json:"material"`
+	Hand     int `json:"hand"`
+	Check    int `json:"check"`
+	Terminal int `json:"terminal"`
+	Total    int `json:"total"`
+}
+
+// Explain returns the terms of the material evaluation of state for forPlayer, with pieces
+// in hand weighted as configured. When c.Evaluation is EvaluationMaterial, Total equals
+// c.Evaluate(state, 0) for Bottom and its negation for Top.
+func (c EvalConfig) Explain(state GameState, forPlayer Player) EvalBreakdown {
+	var b EvalBreakdown
+	if !HasLegalMove(state, state.Turn) {
+		b.Terminal = noMovesScore(state, forPlayer, 0)
+	} else {
+		b.Material = boardMaterial(state, forPlayer)
+		b.Hand = handMaterial(state, forPlayer, c.handMultiplier())
+		if InCheck(state, forPlayer) {
+			b.Check -= checkBonus
+		}
+		if InCheck(state, forPlayer.Opponent()) {
+			b.Check += checkBonus
+		}
+	}
+	b.Total = b.Material + b.Hand + b.Check + b.Terminal
+	return b
+}
+
+// EvaluateExplain is EvalConfig.Explain with the default material evaluation.
+func EvaluateExplain(state GameState, forPlayer Player) EvalBreakdown {
+	return EvalConfig{}.Explain(state, forPlayer)
+}
+
+func mobilityEvaluation(node *searchNode, maximizer Player, depth int, handMultiplier float64) int {
+	// Generate first so materialEvaluation's terminal check reuses the cached moves.
+	myMoves := len(node.movesFor(maximizer))
+	opponentMoves := len(node.movesFor(maximizer.Opponent()))
+	score := materialEvaluation(node, maximizer, depth, handMultiplier)
 	score += mobilityWeight * (myMoves - opponentMoves)
 	return score
 }
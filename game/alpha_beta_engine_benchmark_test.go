@@ -0,0 +1,54 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+)
+
+func BenchmarkAlphaBetaNextMove(b *testing.B) {
+	for _, depth := range []int{1, 3} {
+		b.Run(fmt.Sprintf("depth%d", depth), func(b *testing.B) {
+			state := NewGame()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				// A fresh engine keeps the transposition table from skipping the search.
+				engine, err := NewAlphaBetaEngine(depth)
+				if err != nil {
+					b.Fatalf("NewAlphaBetaEngine failed: %v", err)
+				}
+				if _, err := engine.NextMove(state); err != nil {
+					b.Fatalf("NextMove failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkAlphaBetaMoveGenerations reports how often legal moves are generated (or
+// checked for existence) per searched node. Each node generates at most once for the
+// side to move; mobility leaves add one generation for the other side.
+func BenchmarkAlphaBetaMoveGenerations(b *testing.B) {
+	evaluations := []struct {
+		name     string
+		evaluate evaluationFunc
+	}{
+		{"material", EvalConfig{}.function()},
+		{"mobility", EvalConfig{Evaluation: EvaluationMobility}.function()},
+	}
+	for _, eval := range evaluations {
+		b.Run(eval.name, func(b *testing.B) {
+			state := NewGame()
+			var nodes, generations int64
+			for i := 0; i < b.N; i++ {
+				search := newAlphaBetaSearch(3, eval.evaluate)
+				if _, err := search.nextMove(state, 1); err != nil {
+					b.Fatalf("nextMove failed: %v", err)
+				}
+				nodes += search.nodes.Load()
+				generations += search.moveGenerations.Load()
+			}
+			b.ReportMetric(float64(generations)/float64(nodes), "movegens/node")
+			b.ReportMetric(float64(nodes)/b.Elapsed().Seconds(), "nodes/s")
+		})
+	}
+}
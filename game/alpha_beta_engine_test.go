@@ -0,0 +1,264 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAlphaBetaTieBreakPrefersSmallerMoveString(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+
+	// a1b1, a1a2 and a1b2 all keep material and safety equal; a1b1 is generated first.
+	engine, err := NewAlphaBetaEngine(1)
+	if err != nil {
+		t.Fatalf("NewAlphaBetaEngine failed: %v", err)
+	}
+	mv, err := engine.NextMove(state)
+	if err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+	if got := FormatMove(mv); got != "a1a2" {
+		t.Fatalf("tie-break chose %s, want a1a2", got)
+	}
+}
+
+func TestPreferOnTieOrdersByEvaluationThenMoveString(t *testing.T) {
+	a2, err := ParseMove("a1a2")
+	if err != nil {
+		t.Fatalf("ParseMove failed: %v", err)
+	}
+	b1, err := ParseMove("a1b1")
+	if err != nil {
+		t.Fatalf("ParseMove failed: %v", err)
+	}
+	if !preferOnTie(b1, 5, a2, 0) {
+		t.Fatalf("expected higher evaluation to win the tie")
+	}
+	if preferOnTie(b1, 0, a2, 0) {
+		t.Fatalf("expected lexicographically smaller move to win an evaluation tie")
+	}
+}
+
+func TestAlphaBetaParallelRootMatchesSerial(t *testing.T) {
+	tactical := newEmptyState(Bottom)
+	tactical.Board[0][2] = Piece{Kind: King, Owner: Bottom, Present: true}
+	tactical.Board[5][2] = Piece{Kind: King, Owner: Top, Present: true}
+	tactical.Board[2][1] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+	tactical.Board[3][2] = Piece{Kind: Silver, Owner: Top, Present: true}
+	tactical.Board[4][1] = Piece{Kind: Gold, Owner: Top, Present: true}
+	tactical.Hands[Bottom][Pawn] = 1
+
+	for name, state := range map[string]GameState{"opening": NewGame(), "tactical": tactical} {
+		legal := GenerateLegalMoves(state, state.Turn)
+		serial := newAlphaBetaSearch(3, EvalConfig{}.function()).searchRoot(state, legal)
+		parallel := newAlphaBetaSearch(3, EvalConfig{}.function()).searchRootParallel(state, legal, 4)
+		if !serial.found || !parallel.found {
+			t.Fatalf("%s: expected both searches to find a move", name)
+		}
+		if serial.score != parallel.score {
+			t.Fatalf("%s: parallel score %d, serial score %d", name, parallel.score, serial.score)
+		}
+	}
+}
+
+func TestAlphaBetaAvoidsRunningOutOfMoves(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][1] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[1][3] = Piece{Kind: Silver, Owner: Top, Present: true}
+	state.Board[2][3] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[2][0] = Piece{Kind: Pawn, Owner: Top, Present: true}
+
+	// After b1a1 Top can cover a2 and b2 without giving check, leaving Bottom without moves.
+	engine, err := NewAlphaBetaEngine(2)
+	if err != nil {
+		t.Fatalf("NewAlphaBetaEngine failed: %v", err)
+	}
+	mv, err := engine.NextMove(state)
+	if err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+	if got := FormatMove(mv); got == "b1a1" {
+		t.Fatalf("engine walked into a position without legal moves")
+	}
+}
+
+func TestMaterialEvaluationTreatsNoMovesAsLoss(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[2][1] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[1][2] = Piece{Kind: Silver, Owner: Top, Present: true}
+
+	if InCheck(state, Bottom) || HasLegalMove(state, Bottom) {
+		t.Fatalf("test position must leave Bottom without moves and out of check")
+	}
+	if score := materialEvaluation(newAlphaBetaSearch(0, EvalConfig{}.function()).newNode(state), Bottom, 0, 1); score > -checkmateScore {
+		t.Fatalf("score = %d, want a loss for Bottom", score)
+	}
+}
+
+// Both state encodings terminate each hand count with a delimiter, so multi-digit counts
+// cannot alias neighbouring entries (e.g. 1 pawn + 1 gold versus 11 pawns).
+func TestStateEncodingsDistinguishHandCounts(t *testing.T) {
+	type hand struct{ gold, silver, pawn int }
+	var hands []hand
+	for gold := 0; gold <= 2; gold++ {
+		for silver := 0; silver <= 2; silver++ {
+			for pawn := 0; pawn <= 18; pawn++ {
+				hands = append(hands, hand{gold, silver, pawn})
+			}
+		}
+	}
+	alphaBetaKeys := make(map[string]bool)
+	mctsKeys := make(map[string]bool)
+	for _, bottom := range hands {
+		for _, top := range hands {
+			state := newEmptyState(Bottom)
+			state.Hands[Bottom][Gold], state.Hands[Bottom][Silver], state.Hands[Bottom][Pawn] = bottom.gold, bottom.silver, bottom.pawn
+			state.Hands[Top][Gold], state.Hands[Top][Silver], state.Hands[Top][Pawn] = top.gold, top.silver, top.pawn
+			alphaBetaKey, mctsKey := encodeState(state), encodeStateKey(state)
+			if alphaBetaKeys[alphaBetaKey] {
+				t.Fatalf("encodeState collision for bottom %+v top %+v", bottom, top)
+			}
+			if mctsKeys[mctsKey] {
+				t.Fatalf("encodeStateKey collision for bottom %+v top %+v", bottom, top)
+			}
+			alphaBetaKeys[alphaBetaKey] = true
+			mctsKeys[mctsKey] = true
+		}
+	}
+}
+
+func TestSEEFlagsLosingCapture(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[2][2] = Piece{Kind: Silver, Owner: Bottom, Present: true}
+	state.Board[3][2] = Piece{Kind: Pawn, Owner: Top, Present: true}
+	state.Board[4][2] = Piece{Kind: Pawn, Owner: Top, Present: true}
+
+	// Silver takes the pawn and is recaptured by the defending pawn.
+	capture := Move{From: &Coord{X: 2, Y: 2}, To: Coord{X: 2, Y: 3}}
+	if got := SEE(state, capture); got != pieceScores[Pawn]-pieceScores[Silver] {
+		t.Fatalf("SEE = %d, want %d", got, pieceScores[Pawn]-pieceScores[Silver])
+	}
+
+	// Without the defender the capture simply wins the pawn.
+	state.Board[4][2] = Piece{}
+	if got := SEE(state, capture); got != pieceScores[Pawn] {
+		t.Fatalf("SEE = %d, want %d", got, pieceScores[Pawn])
+	}
+}
+
+func TestOrderMovesPutsLosingCapturesLast(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[2][2] = Piece{Kind: Silver, Owner: Bottom, Present: true}
+	state.Board[3][2] = Piece{Kind: Pawn, Owner: Top, Present: true}
+	state.Board[4][2] = Piece{Kind: Pawn, Owner: Top, Present: true}
+
+	moves := GenerateLegalMoves(state, Bottom)
+	orderMoves(state, moves)
+	if last := FormatMove(moves[len(moves)-1]); last != "c3c4" {
+		t.Fatalf("last move = %s, want the losing capture c3c4", last)
+	}
+}
+
+func TestHandMultiplierRaisesHandValue(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Hands[Bottom][Gold] = 1
+	state.Hands[Bottom][Pawn] = 2
+
+	base := Evaluate(state, 0)
+	if raised := (EvalConfig{HandMultiplier: 1.5}).Evaluate(state, 0); raised <= base {
+		t.Fatalf("Evaluate with multiplier 1.5 = %d, want more than %d", raised, base)
+	}
+}
+
+func TestEvaluateExplainSumsToEvaluate(t *testing.T) {
+	state := NewGame()
+	// Top has lost a silver and a pawn; Bottom has a pawn in hand and an extra gold checking
+	// the king from c5.
+	state.Board[5][0] = Piece{}
+	state.Hands[Bottom][Pawn] = 1
+	state.Board[4][2] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+	state.Board[3][2] = Piece{}
+	state.Turn = Top
+
+	b := EvaluateExplain(state, Bottom)
+	if b.Material+b.Hand+b.Check+b.Terminal != b.Total {
+		t.Fatalf("components %+v do not add up to the total", b)
+	}
+	if want := Evaluate(state, 0); b.Total != want {
+		t.Fatalf("total = %d, want Evaluate = %d", b.Total, want)
+	}
+	if b.Material <= 0 || b.Hand <= 0 || b.Check <= 0 {
+		t.Fatalf("breakdown %+v should favour Bottom in material, hand and check", b)
+	}
+	if top := EvaluateExplain(state, Top); top.Total != -b.Total {
+		t.Fatalf("Top's total = %d, want %d", top.Total, -b.Total)
+	}
+}
+
+func TestSearchDepthAboveCapIsRejected(t *testing.T) {
+	if _, err := NewAlphaBetaEngine(MaxSearchDepth + 1); !errors.Is(err, ErrSearchTooDeep) {
+		t.Fatalf("NewAlphaBetaEngine above the cap: err = %v, want ErrSearchTooDeep", err)
+	}
+	if _, err := NewMobilityAlphaBetaEngine(MaxSearchDepth + 1); !errors.Is(err, ErrSearchTooDeep) {
+		t.Fatalf("NewMobilityAlphaBetaEngine above the cap: err = %v, want ErrSearchTooDeep", err)
+	}
+	if _, _, err := MateSearchContext(context.Background(), NewGame(), Bottom, MaxSearchDepth+1, 0); !errors.Is(err, ErrSearchTooDeep) {
+		t.Fatalf("MateSearchContext above the cap: err = %v, want ErrSearchTooDeep", err)
+	}
+	if mate, line := MateSearch(NewGame(), Bottom, MaxSearchDepth+1); mate || line != nil {
+		t.Fatalf("MateSearch above the cap = %v %v, want no mate", mate, line)
+	}
+	if _, err := NewAlphaBetaEngine(MaxSearchDepth); err != nil {
+		t.Fatalf("NewAlphaBetaEngine at the cap failed: %v", err)
+	}
+}
+
+func TestParseEvaluationRoundTrips(t *testing.T) {
+	for _, ev := range []Evaluation{EvaluationMaterial, EvaluationMobility} {
+		if got, err := ParseEvaluation(ev.String()); err != nil || got != ev {
+			t.Fatalf("ParseEvaluation(%q) = %v, %v; want %v", ev.String(), got, err, ev)
+		}
+	}
+	if _, err := ParseEvaluation("king-safety"); err == nil {
+		t.Fatalf("ParseEvaluation accepted an unknown name")
+	}
+}
+
+// alphaBetaRootAllocBound caps the allocations of a depth-1 NextMove from the opening,
+// engine construction excluded. It sits about 15 below what generating the root moves a
+// second time would add, so the reuse of the root list cannot silently regress.
+const alphaBetaRootAllocBound = 310
+
+func TestAlphaBetaNextMoveRootAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector changes allocation counts")
+	}
+	state := NewGame()
+	newEngine := func() *AlphaBetaEngine {
+		engine, err := NewAlphaBetaEngine(1)
+		if err != nil {
+			t.Fatalf("NewAlphaBetaEngine failed: %v", err)
+		}
+		return engine
+	}
+	construction := testing.AllocsPerRun(50, func() { newEngine() })
+	// A fresh engine keeps the transposition table from skipping the search.
+	total := testing.AllocsPerRun(50, func() {
+		if _, err := newEngine().NextMove(state); err != nil {
+			t.Fatalf("NextMove failed: %v", err)
+		}
+	})
+	if got := total - construction; got > alphaBetaRootAllocBound {
+		t.Fatalf("depth-1 NextMove allocates %.0f times, want at most %d", got, alphaBetaRootAllocBound)
+	}
+}
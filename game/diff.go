@@ -0,0 +1,37 @@
+package game
+
+// CellChange is one difference between two states: either a board square whose contents
+// changed, or, when Hand is set, a change in how many pieces of Kind Owner holds in hand.
+type CellChange struct {
+	At     Coord
+	Before Piece
+	After  Piece
+
+	Hand  bool
+	Owner Player
+	Kind  PieceType
+	Delta int
+}
+
+// BoardDiff lists the squares that differ between a and b in board order, followed by the
+// hand-count deltas from a to b. It allocates only when something changed.
+func BoardDiff(a, b GameState) []CellChange {
+	var changes []CellChange
+	for y := 0; y < BoardRows; y++ {
+		for x := 0; x < BoardCols; x++ {
+			before, after := a.Board[y][x], b.Board[y][x]
+			if !before.Present && !after.Present || before == after {
+				continue
+			}
+			changes = append(changes, CellChange{At: Coord{X: x, Y: y}, Before: before, After: after})
+		}
+	}
+	for _, owner := range []Player{Bottom, Top} {
+		for _, kind := range orderedPieceTypes {
+			if delta := b.Hands[owner][kind] - a.Hands[owner][kind]; delta != 0 {
+				changes = append(changes, CellChange{Hand: true, Owner: owner, Kind: kind, Delta: delta})
+			}
+		}
+	}
+	return changes
+}
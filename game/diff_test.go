@@ -0,0 +1,34 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBoardDiffAfterCapture(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+	silver := Piece{Kind: Silver, Owner: Bottom, Present: true}
+	pawn := Piece{Kind: Pawn, Owner: Top, Present: true}
+	state.Board[2][2] = silver
+	state.Board[3][2] = pawn
+
+	capture := Move{From: &Coord{X: 2, Y: 2}, To: Coord{X: 2, Y: 3}}
+	legal, next := TryApplyMove(state, capture)
+	if !legal {
+		t.Fatalf("capture %s should be legal", FormatMove(capture))
+	}
+
+	want := []CellChange{
+		{At: Coord{X: 2, Y: 2}, Before: silver},
+		{At: Coord{X: 2, Y: 3}, Before: pawn, After: silver},
+		{Hand: true, Owner: Bottom, Kind: Pawn, Delta: 1},
+	}
+	if got := BoardDiff(state, next); !reflect.DeepEqual(got, want) {
+		t.Fatalf("BoardDiff = %+v, want %+v", got, want)
+	}
+	if got := BoardDiff(next, next); got != nil {
+		t.Fatalf("BoardDiff of identical states = %+v, want nil", got)
+	}
+}
@@ -1,9 +1,13 @@
 package game
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"sort"
 	"strings"
+	"time"
 )
 
 const (
@@ -66,19 +70,70 @@ type Engine interface {
 	NextMove(state GameState) (Move, error)
 }
 
+// DrawOfferer is optionally implemented by engines that may propose a draw before moving.
+// A draw is agreed when both sides offer on consecutive plies.
+type DrawOfferer interface {
+	OfferDraw(state GameState) bool
+}
+
+// Warmer is optionally implemented by engines that finish loading stored knowledge in the
+// background. They can play, if weaker, before Warmup returns.
+type Warmer interface {
+	Warmup(ctx context.Context) error
+}
+
+// SearchInfo is an engine's own assessment of the move it last returned.
+type SearchInfo struct {
+	Depth int
+	// Score is from the point of view of the side that moved.
+	Score int
+}
+
+// SearchReporter is optionally implemented by engines that can describe their last search.
+// LastSearch is only meaningful right after NextMove returns.
+type SearchReporter interface {
+	LastSearch() SearchInfo
+}
+
+// RuleSet selects how a game is won.
+type RuleSet int
+
+const (
+	// RulesStandard is regular play: moves may not leave the own king in check and the
+	// game is won by checkmate.
+	RulesStandard RuleSet = iota
+	// RulesKingCapture is a teaching variant: moves into or staying in check are allowed
+	// and the game is won by actually capturing the opponent's king.
+	RulesKingCapture
+)
+
 type GameState struct {
 	Board [BoardRows][BoardCols]Piece
 	Hands [2]map[PieceType]int
 	Turn  Player
+	// Rules is RulesStandard unless the game plays a variant.
+	Rules RuleSet
+	// PromotionZoneDepth is how many of the opponent's ranks form the promotion zone, where
+	// silvers and pawns may promote. 0 means the standard two; other values are clamped to
+	// 1..BoardRows.
+	PromotionZoneDepth int
+	// CheckInvariants makes move generation panic when it produces a move capturing the
+	// opponent's king, which legal play can never allow. It is meant for tests and debugging.
+	CheckInvariants bool
 }
 
 func NewGame() GameState {
+	return NewGameWithTurn(Bottom)
+}
+
+// NewGameWithTurn returns the standard starting position with turn to move.
+func NewGameWithTurn(turn Player) GameState {
 	state := GameState{
 		Hands: [2]map[PieceType]int{
 			Bottom: make(map[PieceType]int),
 			Top:    make(map[PieceType]int),
 		},
-		Turn: Bottom,
+		Turn: turn,
 	}
 
 	placeMajor := func(y int, owner Player) {
@@ -103,6 +158,23 @@ func NewGame() GameState {
 	return state
 }
 
+// NewShuffledGame returns the starting position with the back rank pieces shuffled by seed.
+// Top mirrors Bottom's arrangement through the board centre, so both sides keep one king
+// and identical material. Pawns stay on their usual squares.
+func NewShuffledGame(seed int64) GameState {
+	state := NewGame()
+	backRank := []PieceType{Silver, Gold, King, Gold, Silver}
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(backRank), func(i, j int) {
+		backRank[i], backRank[j] = backRank[j], backRank[i]
+	})
+	for x, kind := range backRank {
+		state.Board[0][x] = Piece{Kind: kind, Owner: Bottom, Present: true}
+		state.Board[BoardRows-1][BoardCols-1-x] = Piece{Kind: kind, Owner: Top, Present: true}
+	}
+	return state
+}
+
 func PieceTypeCode(pt PieceType) string {
 	switch pt {
 	case King:
@@ -223,6 +295,27 @@ func TryApplyMove(state GameState, move Move) (bool, GameState) {
 	return false, state
 }
 
+// TryApplyMoveInPlace is TryApplyMove without the clone: a legal move is applied to state
+// directly, leaving the turn unchanged, and the returned diff lets UndoMove take it back.
+// state is untouched when the move is illegal.
+func TryApplyMoveInPlace(state *GameState, move Move) (bool, MoveDiff) {
+	for _, m := range GenerateLegalMoves(*state, state.Turn) {
+		if movesEqual(m, move) {
+			return true, applyMoveInPlace(state, m, state.Turn)
+		}
+	}
+	return false, MoveDiff{}
+}
+
+// GivesCheck reports whether move, played by the side to move, checks the opponent's king.
+func GivesCheck(state GameState, move Move) bool {
+	player := state.Turn
+	diff := applyMoveInPlace(&state, move, player)
+	check := InCheck(state, player.Opponent())
+	UndoMove(&state, diff)
+	return check
+}
+
 func movesEqual(a, b Move) bool {
 	if (a.Drop == nil) != (b.Drop == nil) {
 		return false
@@ -262,7 +355,8 @@ func ApplyMove(state *GameState, move Move) {
 	}
 
 	fromPiece := state.Board[move.From.Y][move.From.X]
-	if target := state.Board[move.To.Y][move.To.X]; target.Present {
+	// A king can only be captured under RulesKingCapture, and it ends the game there.
+	if target := state.Board[move.To.Y][move.To.X]; target.Present && target.Kind != King {
 		state.Hands[player][target.Kind]++
 	}
 
@@ -276,32 +370,34 @@ func ApplyMove(state *GameState, move Move) {
 	state.Board[move.To.Y][move.To.X] = fromPiece
 }
 
-type handDelta struct {
-	player Player
-	piece  PieceType
-	delta  int
+// HandDelta is a change of Delta pieces of kind Piece in Player's hand.
+type HandDelta struct {
+	Player Player
+	Piece  PieceType
+	Delta  int
 }
 
-type moveDiff struct {
-	hasFrom    bool
-	from       Coord
-	fromBefore Piece
-	to         Coord
-	toBefore   Piece
-	handChange handDelta
-	hasHand    bool
+// MoveDiff records what a move applied in place overwrote, so UndoMove can restore it.
+type MoveDiff struct {
+	HasFrom    bool
+	From       Coord
+	FromBefore Piece
+	To         Coord
+	ToBefore   Piece
+	HandChange HandDelta
+	HasHand    bool
 }
 
-// applyMoveInPlace mutates the board and hands directly and returns the diff for undoMove.
-func applyMoveInPlace(state *GameState, move Move, player Player) moveDiff {
-	diff := moveDiff{
-		to:       move.To,
-		toBefore: state.Board[move.To.Y][move.To.X],
+// applyMoveInPlace mutates the board and hands directly and returns the diff for UndoMove.
+func applyMoveInPlace(state *GameState, move Move, player Player) MoveDiff {
+	diff := MoveDiff{
+		To:       move.To,
+		ToBefore: state.Board[move.To.Y][move.To.X],
 	}
 
 	if move.Drop != nil {
-		diff.handChange = handDelta{player: player, piece: *move.Drop, delta: -1}
-		diff.hasHand = true
+		diff.HandChange = HandDelta{Player: player, Piece: *move.Drop, Delta: -1}
+		diff.HasHand = true
 		state.Hands[player][*move.Drop]--
 		state.Board[move.To.Y][move.To.X] = Piece{Kind: *move.Drop, Owner: player, Present: true}
 		return diff
@@ -312,17 +408,17 @@ func applyMoveInPlace(state *GameState, move Move, player Player) moveDiff {
 	}
 
 	from := *move.From
-	diff.hasFrom = true
-	diff.from = from
-	diff.fromBefore = state.Board[from.Y][from.X]
+	diff.HasFrom = true
+	diff.From = from
+	diff.FromBefore = state.Board[from.Y][from.X]
 
-	movingPiece := diff.fromBefore
+	movingPiece := diff.FromBefore
 	state.Board[from.Y][from.X] = Piece{}
 
-	if diff.toBefore.Present {
-		diff.handChange = handDelta{player: player, piece: diff.toBefore.Kind, delta: 1}
-		diff.hasHand = true
-		state.Hands[player][diff.toBefore.Kind]++
+	if diff.ToBefore.Present && diff.ToBefore.Kind != King {
+		diff.HandChange = HandDelta{Player: player, Piece: diff.ToBefore.Kind, Delta: 1}
+		diff.HasHand = true
+		state.Hands[player][diff.ToBefore.Kind]++
 	}
 
 	if move.Promote {
@@ -333,20 +429,29 @@ func applyMoveInPlace(state *GameState, move Move, player Player) moveDiff {
 	return diff
 }
 
-func undoMove(state *GameState, diff moveDiff) {
-	state.Board[diff.to.Y][diff.to.X] = diff.toBefore
-	if diff.hasFrom {
-		state.Board[diff.from.Y][diff.from.X] = diff.fromBefore
+// UndoMove reverts a move applied in place, given the diff it returned. Moves must be undone
+// in the reverse order they were applied.
+func UndoMove(state *GameState, diff MoveDiff) {
+	state.Board[diff.To.Y][diff.To.X] = diff.ToBefore
+	if diff.HasFrom {
+		state.Board[diff.From.Y][diff.From.X] = diff.FromBefore
 	}
-	if diff.hasHand {
-		change := diff.handChange
-		state.Hands[change.player][change.piece] -= change.delta
+	if diff.HasHand {
+		change := diff.HandChange
+		state.Hands[change.Player][change.Piece] -= change.Delta
 	}
 }
 
+// GenerateLegalMoves returns player's legal moves in a deterministic order: board moves
+// scanning ranks then files, followed by drops grouped by piece type.
 func GenerateLegalMoves(state GameState, player Player) []Move {
+	kingPos, kingFound := guardedKing(state, player)
+	return generateLegalMoves(state, player, kingPos, kingFound)
+}
+
+// generateLegalMoves is GenerateLegalMoves with player's king square already known.
+func generateLegalMoves(state GameState, player Player, kingPos Coord, kingFound bool) []Move {
 	statePtr := &state
-	kingPos, kingFound := findKing(state, player)
 	moves := make([]Move, 0, 48)
 	for y := 0; y < BoardRows; y++ {
 		for x := 0; x < BoardCols; x++ {
@@ -359,12 +464,88 @@ func GenerateLegalMoves(state GameState, player Player) []Move {
 		}
 	}
 
-	for dropType := range state.Hands[player] {
+	// Drops follow orderedPieceTypes rather than map order so the move list is deterministic.
+	for _, dropType := range orderedPieceTypes {
+		if state.Hands[player][dropType] == 0 {
+			continue
+		}
 		moves = appendLegalDrops(statePtr, player, dropType, kingPos, kingFound, moves)
 	}
+	if state.CheckInvariants && state.Rules == RulesStandard {
+		checkNoKingCapture(state, player, moves)
+	}
 	return moves
 }
 
+func checkNoKingCapture(state GameState, player Player, moves []Move) {
+	kingPos, found := findKing(state, player.Opponent())
+	if !found {
+		return
+	}
+	for _, mv := range moves {
+		if mv.To == kingPos {
+			panic(fmt.Sprintf("game: generated move %s captures the king", FormatMove(mv)))
+		}
+	}
+}
+
+// VerifyState checks the invariants every reachable position satisfies: a valid side to
+// move, exactly one king per side on the board and none in hand, pieces of known kinds and
+// owners, promotions only on silvers and pawns, and non-negative hand counts. Load and
+// training paths use it to catch corrupted positions early.
+func VerifyState(state GameState) error {
+	if state.Turn != Bottom && state.Turn != Top {
+		return fmt.Errorf("game: invalid side to move %d", state.Turn)
+	}
+	var kings [2]int
+	for y := 0; y < BoardRows; y++ {
+		for x := 0; x < BoardCols; x++ {
+			p := state.Board[y][x]
+			if !p.Present {
+				continue
+			}
+			at := CoordToString(Coord{X: x, Y: y})
+			if p.Owner != Bottom && p.Owner != Top {
+				return fmt.Errorf("game: piece on %s has invalid owner %d", at, p.Owner)
+			}
+			if p.Kind < King || p.Kind > Pawn {
+				return fmt.Errorf("game: piece on %s has invalid kind %d", at, p.Kind)
+			}
+			if p.Promoted && p.Kind != Silver && p.Kind != Pawn {
+				return fmt.Errorf("game: promoted %s on %s", PieceTypeCode(p.Kind), at)
+			}
+			if p.Kind == King {
+				kings[p.Owner]++
+			}
+		}
+	}
+	for _, player := range []Player{Bottom, Top} {
+		// Under RulesKingCapture the captured king leaves the board as the game ends.
+		if kings[player] != 1 && !(kings[player] == 0 && state.Rules == RulesKingCapture) {
+			return fmt.Errorf("game: player %d has %d kings on the board, want 1", player, kings[player])
+		}
+		for pt, count := range state.Hands[player] {
+			if pt < King || pt > Pawn {
+				return fmt.Errorf("game: player %d holds invalid piece kind %d", player, pt)
+			}
+			if count < 0 {
+				return fmt.Errorf("game: player %d holds %d of %s", player, count, PieceTypeCode(pt))
+			}
+			if pt == King && count > 0 {
+				return fmt.Errorf("game: player %d holds a king in hand", player)
+			}
+		}
+	}
+	return nil
+}
+
+// SortMoves sorts moves in place by their FormatMove string, giving a canonical order.
+func SortMoves(moves []Move) {
+	sort.Slice(moves, func(i, j int) bool {
+		return FormatMove(moves[i]) < FormatMove(moves[j])
+	})
+}
+
 func GenerateLegalMovesFrom(state GameState, player Player, from Coord) []Move {
 	if !insideBoard(from) {
 		return nil
@@ -373,7 +554,7 @@ func GenerateLegalMovesFrom(state GameState, player Player, from Coord) []Move {
 	if !piece.Present || piece.Owner != player {
 		return nil
 	}
-	kingPos, kingFound := findKing(state, player)
+	kingPos, kingFound := guardedKing(state, player)
 	return appendLegalMovesForPiece(&state, from, piece, kingPos, kingFound, nil)
 }
 
@@ -381,10 +562,31 @@ func GenerateLegalDrops(state GameState, player Player, pieceKind PieceType) []M
 	if state.Hands[player][pieceKind] == 0 {
 		return nil
 	}
-	kingPos, kingFound := findKing(state, player)
+	kingPos, kingFound := guardedKing(state, player)
 	return appendLegalDrops(&state, player, pieceKind, kingPos, kingFound, nil)
 }
 
+// GenerateLegalDropsAssumingHand lists where player could drop pieceKind if it held one,
+// ignoring the current hand count but keeping every other drop rule. Puzzle setups use it
+// to preview drops for pieces not in hand.
+func GenerateLegalDropsAssumingHand(state GameState, player Player, pieceKind PieceType) []Move {
+	held := CloneState(state)
+	held.Hands[player][pieceKind] = max(held.Hands[player][pieceKind], 1)
+	return GenerateLegalDrops(held, player, pieceKind)
+}
+
+// DroppablePieces lists, in orderedPieceTypes order, the hand pieces of player that have at
+// least one legal drop square.
+func DroppablePieces(state GameState, player Player) []PieceType {
+	var droppable []PieceType
+	for _, pt := range orderedPieceTypes {
+		if len(GenerateLegalDrops(state, player, pt)) > 0 {
+			droppable = append(droppable, pt)
+		}
+	}
+	return droppable
+}
+
 func appendLegalMovesForPiece(state *GameState, from Coord, piece Piece, kingPos Coord, kingFound bool, moves []Move) []Move {
 	player := piece.Owner
 	for _, delta := range movementOffsets(piece) {
@@ -398,7 +600,7 @@ func appendLegalMovesForPiece(state *GameState, from Coord, piece Piece, kingPos
 		}
 
 		tryAppendMove(state, from, to, false, player, kingPos, kingFound, &moves)
-		if canPromote(piece, to.Y) {
+		if canPromote(state, piece, to.Y) {
 			tryAppendMove(state, from, to, true, player, kingPos, kingFound, &moves)
 		}
 	}
@@ -425,8 +627,12 @@ func appendLegalDrops(state *GameState, player Player, pieceKind PieceType, king
 }
 
 func HasLegalMove(state GameState, player Player) bool {
+	kingPos, kingFound := guardedKing(state, player)
+	return hasLegalMove(state, player, kingPos, kingFound)
+}
+
+func hasLegalMove(state GameState, player Player, kingPos Coord, kingFound bool) bool {
 	statePtr := &state
-	kingPos, kingFound := findKing(state, player)
 	for y := 0; y < BoardRows; y++ {
 		for x := 0; x < BoardCols; x++ {
 			piece := state.Board[y][x]
@@ -439,8 +645,8 @@ func HasLegalMove(state GameState, player Player) bool {
 			}
 		}
 	}
-	for pieceKind, count := range state.Hands[player] {
-		if count == 0 {
+	for _, pieceKind := range orderedPieceTypes {
+		if state.Hands[player][pieceKind] == 0 {
 			continue
 		}
 		if dropHasLegalMove(statePtr, player, pieceKind, kingPos, kingFound) {
@@ -464,7 +670,7 @@ func pieceHasLegalMove(state *GameState, from Coord, piece Piece, kingPos Coord,
 		if tryMove(state, from, to, false, player, kingPos, kingFound) {
 			return true
 		}
-		if canPromote(piece, to.Y) && tryMove(state, from, to, true, player, kingPos, kingFound) {
+		if canPromote(state, piece, to.Y) && tryMove(state, from, to, true, player, kingPos, kingFound) {
 			return true
 		}
 	}
@@ -517,7 +723,7 @@ func tryMove(state *GameState, from, to Coord, promote bool, player Player, king
 	diff := applyMoveInPlace(state, mv, player)
 	inCheck := playerInCheckAfterAppliedMove(state, player, diff, kingPos, kingFound)
 	valid := !inCheck && pieceHasBoardReach(state.Board[to.Y][to.X], to)
-	undoMove(state, diff)
+	UndoMove(state, diff)
 	return valid
 }
 
@@ -526,7 +732,7 @@ func tryDrop(state *GameState, to Coord, player Player, pieceKind PieceType, kin
 	diff := applyMoveInPlace(state, mv, player)
 	inCheck := playerInCheckAfterAppliedMove(state, player, diff, kingPos, kingFound)
 	valid := !inCheck && pieceHasBoardReach(state.Board[to.Y][to.X], to)
-	undoMove(state, diff)
+	UndoMove(state, diff)
 	return valid
 }
 
@@ -614,26 +820,34 @@ func movementOffsets(p Piece) []Coord {
 	return nil
 }
 
-func canPromote(p Piece, destY int) bool {
+func canPromote(state *GameState, p Piece, destY int) bool {
 	if p.Promoted {
 		return false
 	}
 	if p.Kind != Silver && p.Kind != Pawn {
 		return false
 	}
-	zoneMin, zoneMax := promotionZoneFor(p.Owner)
+	zoneMin, zoneMax := promotionZoneFor(state.PromotionZoneDepth, p.Owner)
 	return destY >= zoneMin && destY <= zoneMax
 }
 
-// promotionZoneFor returns inclusive Y bounds for the opponent's first two ranks.
-func promotionZoneFor(player Player) (minY, maxY int) {
+// defaultPromotionZoneDepth is the promotion zone depth of a GameState that sets none.
+const defaultPromotionZoneDepth = 2
+
+// promotionZoneFor returns inclusive Y bounds for the opponent's first depth ranks; see
+// GameState.PromotionZoneDepth.
+func promotionZoneFor(depth int, player Player) (minY, maxY int) {
+	if depth == 0 {
+		depth = defaultPromotionZoneDepth
+	}
+	depth = min(max(depth, 1), BoardRows)
 	if player == Bottom {
-		return BoardRows - 2, BoardRows - 1
+		return BoardRows - depth, BoardRows - 1
 	}
-	return 0, 1
+	return 0, depth - 1
 }
 
-func playerInCheckAfterAppliedMove(state *GameState, player Player, diff moveDiff, kingPos Coord, kingFound bool) bool {
+func playerInCheckAfterAppliedMove(state *GameState, player Player, diff MoveDiff, kingPos Coord, kingFound bool) bool {
 	nextKingPos, nextKingFound := kingPositionAfterDiff(player, diff, kingPos, kingFound)
 	if !nextKingFound {
 		return false
@@ -641,9 +855,11 @@ func playerInCheckAfterAppliedMove(state *GameState, player Player, diff moveDif
 	return isKingThreatened(&state.Board, player, nextKingPos)
 }
 
-func kingPositionAfterDiff(player Player, diff moveDiff, kingPos Coord, kingFound bool) (Coord, bool) {
-	if diff.hasFrom && diff.fromBefore.Owner == player && diff.fromBefore.Kind == King {
-		return diff.to, true
+func kingPositionAfterDiff(player Player, diff MoveDiff, kingPos Coord, kingFound bool) (Coord, bool) {
+	// kingFound is false only when the king is not guarded (see guardedKing), so a king
+	// move must not start guarding it.
+	if kingFound && diff.HasFrom && diff.FromBefore.Owner == player && diff.FromBefore.Kind == King {
+		return diff.To, true
 	}
 	return kingPos, kingFound
 }
@@ -656,6 +872,53 @@ func InCheck(state GameState, player Player) bool {
 	return isKingThreatened(&state.Board, player, kingPos)
 }
 
+// CheckingPieces returns the squares of the opponent pieces attacking player's king, in
+// board order. It is empty when player is not in check.
+func CheckingPieces(state GameState, player Player) []Coord {
+	kingPos, found := findKing(state, player)
+	if !found {
+		return nil
+	}
+	var checkers []Coord
+	for y := 0; y < BoardRows; y++ {
+		for x := 0; x < BoardCols; x++ {
+			p := state.Board[y][x]
+			if !p.Present || p.Owner == player {
+				continue
+			}
+			for _, delta := range movementOffsets(p) {
+				if x+delta.X == kingPos.X && y+delta.Y == kingPos.Y {
+					checkers = append(checkers, Coord{X: x, Y: y})
+					break
+				}
+			}
+		}
+	}
+	return checkers
+}
+
+// AttackMap counts, for every square, how many of player's pieces could move there, using
+// the same movement offsets as the check detection. Squares holding player's own pieces
+// are counted too, since they are defended.
+func AttackMap(state GameState, player Player) [BoardRows][BoardCols]int {
+	var counts [BoardRows][BoardCols]int
+	for y := 0; y < BoardRows; y++ {
+		for x := 0; x < BoardCols; x++ {
+			p := state.Board[y][x]
+			if !p.Present || p.Owner != player {
+				continue
+			}
+			for _, delta := range movementOffsets(p) {
+				to := Coord{X: x + delta.X, Y: y + delta.Y}
+				if insideBoard(to) {
+					counts[to.Y][to.X]++
+				}
+			}
+		}
+	}
+	return counts
+}
+
 func isKingThreatened(board *[BoardRows][BoardCols]Piece, player Player, kingPos Coord) bool {
 	opponent := player.Opponent()
 
@@ -680,6 +943,15 @@ func isKingThreatened(board *[BoardRows][BoardCols]Piece, player Player, kingPos
 	return false
 }
 
+// guardedKing is findKing for move generation: under RulesKingCapture no king is protected
+// from check, so it reports none.
+func guardedKing(state GameState, player Player) (Coord, bool) {
+	if state.Rules == RulesKingCapture {
+		return Coord{}, false
+	}
+	return findKing(state, player)
+}
+
 func findKing(state GameState, player Player) (Coord, bool) {
 	for y := 0; y < BoardRows; y++ {
 		for x := 0; x < BoardCols; x++ {
@@ -708,44 +980,133 @@ func IsCheckmate(state GameState, player Player) bool {
 	return len(GenerateLegalMoves(state, player)) == 0
 }
 
-// MateSearch performs a minimax search limited by depth (in plies) to detect a forced mate.
-// It returns the winning line starting from the current state if the attacker can force mate.
+// MaxSearchDepth is the deepest search, in plies, that the alpha-beta engines and the mate
+// search accept. Deeper searches would effectively never finish on this board.
+const MaxSearchDepth = 16
+
+// ErrSearchTooDeep is returned for a search depth above MaxSearchDepth.
+var ErrSearchTooDeep = fmt.Errorf("search depth exceeds %d plies", MaxSearchDepth)
+
+// checkSearchDepth rejects depths above MaxSearchDepth.
+func checkSearchDepth(depth int) error {
+	if depth > MaxSearchDepth {
+		return fmt.Errorf("%w: %d", ErrSearchTooDeep, depth)
+	}
+	return nil
+}
+
+// MateSearch performs a minimax search limited by depth (in plies) to detect a forced mate,
+// i.e. leaving the defender without a legal move, which loses even out of check. It returns
+// the winning line starting from the current state if the attacker can force mate.
+// A depth above MaxSearchDepth finds nothing; MateSearchContext reports it as an error.
 func MateSearch(state GameState, attacker Player, depth int) (bool, []Move) {
+	found, line, _ := MateSearchContext(context.Background(), state, attacker, depth, 0)
+	return found, line
+}
+
+// MateSearchContext is MateSearch with limits: it gives up and returns (false, nil) once ctx
+// is done or more than maxNodes positions were visited (maxNodes <= 0 means no node limit).
+func MateSearchContext(ctx context.Context, state GameState, attacker Player, depth, maxNodes int) (bool, []Move, error) {
+	found, line, _, err := MateSearchStats(ctx, state, attacker, depth, maxNodes)
+	return found, line, err
+}
+
+// MateStats describes the work done by a mate search.
+type MateStats struct {
+	Nodes   int
+	Elapsed time.Duration
+	Aborted bool
+}
+
+// NodesPerSecond returns the search speed, or 0 when no time was measured.
+func (s MateStats) NodesPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Nodes) / s.Elapsed.Seconds()
+}
+
+// MateSearchStats is MateSearchContext that also reports the nodes visited and time spent.
+func MateSearchStats(ctx context.Context, state GameState, attacker Player, depth, maxNodes int) (bool, []Move, MateStats, error) {
+	if err := checkSearchDepth(depth); err != nil {
+		return false, nil, MateStats{}, err
+	}
 	if depth <= 0 {
-		return false, nil
+		return false, nil, MateStats{}, nil
 	}
-	defender := attacker.Opponent()
-	return mateSearch(state, attacker, defender, depth)
+	start := time.Now()
+	search := &mateSearcher{ctx: ctx, attacker: attacker, defender: attacker.Opponent(), maxNodes: maxNodes, path: make(map[string]bool)}
+	found, line := search.search(state, depth)
+	stats := MateStats{Nodes: search.nodes, Elapsed: time.Since(start), Aborted: search.aborted}
+	if search.aborted {
+		return false, nil, stats, nil
+	}
+	return found, line, stats, nil
 }
 
-func mateSearch(state GameState, attacker, defender Player, depth int) (bool, []Move) {
-	if depth == 0 {
+// mateCancelCheckInterval is how many nodes are visited between ctx checks.
+const mateCancelCheckInterval = 256
+
+type mateSearcher struct {
+	ctx      context.Context
+	attacker Player
+	defender Player
+	maxNodes int
+	nodes    int
+	aborted  bool
+	// path holds the positions on the current line. Reaching one again means the
+	// defender can repeat forever, which counts as escaping mate.
+	path map[string]bool
+}
+
+// stop counts a visited node and reports whether the search must be abandoned.
+func (m *mateSearcher) stop() bool {
+	if m.aborted {
+		return true
+	}
+	m.nodes++
+	if m.maxNodes > 0 && m.nodes > m.maxNodes {
+		m.aborted = true
+	} else if m.nodes%mateCancelCheckInterval == 0 && m.ctx.Err() != nil {
+		m.aborted = true
+	}
+	return m.aborted
+}
+
+func (m *mateSearcher) search(state GameState, depth int) (bool, []Move) {
+	if depth == 0 || m.stop() {
+		return false, nil
+	}
+	key := PositionKey(state)
+	if m.path[key] {
 		return false, nil
 	}
+	m.path[key] = true
+	defer delete(m.path, key)
 
 	player := state.Turn
 	moves := GenerateLegalMoves(state, player)
 
 	if len(moves) == 0 {
-		if player == defender && InCheck(state, defender) {
-			return true, nil
-		}
-		return false, nil
+		return noMovesResult(state).Winner == m.attacker, nil
 	}
 
-	if player == attacker {
+	if player == m.attacker {
 		for _, mv := range moves {
 			next := CloneState(state)
 			ApplyMove(&next, mv)
 			next.Turn = player.Opponent()
 
-			if IsCheckmate(next, defender) {
+			if !HasLegalMove(next, m.defender) {
 				return true, []Move{mv}
 			}
-			found, line := mateSearch(next, attacker, defender, depth-1)
+			found, line := m.search(next, depth-1)
 			if found {
 				return true, append([]Move{mv}, line...)
 			}
+			if m.aborted {
+				return false, nil
+			}
 		}
 		return false, nil
 	}
@@ -756,7 +1117,7 @@ func mateSearch(state GameState, attacker, defender Player, depth int) (bool, []
 		ApplyMove(&next, mv)
 		next.Turn = player.Opponent()
 
-		found, line := mateSearch(next, attacker, defender, depth-1)
+		found, line := m.search(next, depth-1)
 		if !found {
 			return false, nil
 		}
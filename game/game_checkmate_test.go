@@ -47,3 +47,137 @@ func TestCheckmateStatusSafePosition(t *testing.T) {
 		t.Fatalf("expected no checkmate in safe position")
 	}
 }
+
+// newNoMovesState returns a position whose side to move, Bottom, has no legal move without
+// being in check.
+func newNoMovesState() GameState {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[2][1] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[1][2] = Piece{Kind: Silver, Owner: Top, Present: true}
+	return state
+}
+
+// newKingCapturableState returns a RulesKingCapture position where Bottom, to move, can take
+// the top king with its gold.
+func newKingCapturableState() GameState {
+	state := newEmptyState(Bottom)
+	state.Rules = RulesKingCapture
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[2][2] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+	state.Board[3][2] = Piece{Kind: King, Owner: Top, Present: true}
+	return state
+}
+
+func TestDetermineResultTreatsNoMovesAsLoss(t *testing.T) {
+	result := DetermineResult(newNoMovesState(), nil)
+	if !result.Over || result.Winner != Top || result.Reason != ReasonNoMoves {
+		t.Fatalf("result = %+v, want a no-moves win for Top", result)
+	}
+}
+
+func TestNoProgressDetectsShufflingKings(t *testing.T) {
+	history := []GameState{NewGame()}
+	play := func(moves ...string) {
+		for _, text := range moves {
+			mv, err := ParseMove(text)
+			if err != nil {
+				t.Fatalf("ParseMove(%q) failed: %v", text, err)
+			}
+			ok, next := TryApplyMove(history[len(history)-1], mv)
+			if !ok {
+				t.Fatalf("%s is illegal", text)
+			}
+			next.Turn = next.Turn.Opponent()
+			history = append(history, next)
+		}
+	}
+	play("b3b4")
+	if NoProgress(history, 8) {
+		t.Fatalf("NoProgress reported a game shorter than the window")
+	}
+	shuffle := []string{"c6c5", "c1c2", "c5c6", "c2c1"}
+	play(shuffle...)
+	play(shuffle[:3]...)
+	if NoProgress(history, 8) {
+		t.Fatalf("NoProgress reported a window that includes a capture")
+	}
+	play(shuffle[3])
+	if !NoProgress(history, 8) {
+		t.Fatalf("NoProgress missed eight plies of shuffling kings")
+	}
+	if NoProgress(history, 0) {
+		t.Fatalf("NoProgress with a zero window should never report")
+	}
+}
+
+func TestAttackMapCountsGoldSquares(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[2][2] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+	state.Board[4][2] = Piece{Kind: Pawn, Owner: Top, Present: true}
+
+	want := map[Coord]int{
+		{X: 1, Y: 3}: 1, {X: 2, Y: 3}: 1, {X: 3, Y: 3}: 1,
+		{X: 1, Y: 2}: 1, {X: 3, Y: 2}: 1,
+		{X: 2, Y: 1}: 1,
+	}
+	attacks := AttackMap(state, Bottom)
+	for y := 0; y < BoardRows; y++ {
+		for x := 0; x < BoardCols; x++ {
+			if got := attacks[y][x]; got != want[Coord{X: x, Y: y}] {
+				t.Fatalf("AttackMap[%d][%d] = %d, want %d", y, x, got, want[Coord{X: x, Y: y}])
+			}
+		}
+	}
+	// The top pawn on c5 attacks only c4, which the gold also attacks.
+	if got := AttackMap(state, Top); got[3][2] != 1 || got[2][2] != 0 {
+		t.Fatalf("top attacks c4 %d times and c3 %d times, want 1 and 0", got[3][2], got[2][2])
+	}
+}
+
+func TestKingCaptureRulesLetTheKingBeTaken(t *testing.T) {
+	state := newEmptyState(Top)
+	state.Rules = RulesKingCapture
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[2][2] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+	state.Board[4][2] = Piece{Kind: King, Owner: Top, Present: true}
+
+	play := func(text string) {
+		mv, err := ParseMove(text)
+		if err != nil {
+			t.Fatalf("ParseMove(%q) failed: %v", text, err)
+		}
+		ok, next := TryApplyMove(state, mv)
+		if !ok {
+			t.Fatalf("%s should be legal under king-capture rules", text)
+		}
+		next.Turn = next.Turn.Opponent()
+		state = next
+	}
+
+	// The top king walks into the gold's reach, which standard rules forbid.
+	standard := CloneState(state)
+	standard.Rules = RulesStandard
+	if legal, _ := TryApplyMove(standard, Move{From: &Coord{X: 2, Y: 4}, To: Coord{X: 2, Y: 3}}); legal {
+		t.Fatalf("c5c4 should be illegal under standard rules")
+	}
+	if result := DetermineResult(state, nil); result.Over {
+		t.Fatalf("result = %+v while no king can be taken, want the game to go on", result)
+	}
+	play("c5c4")
+	// The game ends as soon as Bottom can take the king, before the capture is played.
+	if result := DetermineResult(state, nil); !result.Over || result.Winner != Bottom || result.Reason != ReasonKingCaptured {
+		t.Fatalf("result = %+v with the king en prise, want a king-captured win for Bottom", result)
+	}
+	play("c3c4")
+	result := DetermineResult(state, nil)
+	if !result.Over || result.Winner != Bottom || result.Reason != ReasonKingCaptured {
+		t.Fatalf("result = %+v, want a king-captured win for Bottom", result)
+	}
+	if state.Hands[Bottom][King] != 0 {
+		t.Fatalf("the captured king went to the hand")
+	}
+	if err := VerifyState(state); err != nil {
+		t.Fatalf("VerifyState after the capture: %v", err)
+	}
+}
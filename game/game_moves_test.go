@@ -0,0 +1,185 @@
+package game
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGenerateLegalMovesIsDeterministicWithDrops(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Hands[Bottom][Pawn] = 2
+	state.Hands[Bottom][Silver] = 1
+	state.Hands[Bottom][Gold] = 1
+
+	first := GenerateLegalMoves(state, Bottom)
+	for i := 0; i < 20; i++ {
+		if next := GenerateLegalMoves(state, Bottom); !reflect.DeepEqual(first, next) {
+			t.Fatalf("move order changed between calls")
+		}
+	}
+}
+
+func TestGenerateLegalMovesSkipsEmptyHandEntries(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Hands[Bottom][Gold] = 0
+
+	for _, mv := range GenerateLegalMoves(state, Bottom) {
+		if mv.Drop != nil {
+			t.Fatalf("unexpected drop %s with an empty hand", FormatMove(mv))
+		}
+	}
+}
+
+func TestSortMovesIsCanonical(t *testing.T) {
+	moves := GenerateLegalMoves(NewGame(), Bottom)
+	SortMoves(moves)
+	if !sort.SliceIsSorted(moves, func(i, j int) bool { return FormatMove(moves[i]) < FormatMove(moves[j]) }) {
+		t.Fatalf("moves are not sorted")
+	}
+}
+
+func TestPawnDropAllowedBesideTokinAndOpponentPawn(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[4][2] = Piece{Kind: Pawn, Owner: Bottom, Promoted: true, Present: true}
+	state.Board[3][2] = Piece{Kind: Pawn, Owner: Top, Present: true}
+	state.Hands[Bottom][Pawn] = 1
+
+	drop := Move{Drop: ptrPieceType(Pawn), To: Coord{X: 2, Y: 1}}
+	if legal, _ := TryApplyMove(state, drop); !legal {
+		t.Fatalf("pawn drop on a file with a tokin and an opponent pawn should be legal")
+	}
+
+	// An unpromoted pawn of the dropping player still blocks the file.
+	state.Board[4][2] = Piece{Kind: Pawn, Owner: Bottom, Present: true}
+	if legal, _ := TryApplyMove(state, drop); legal {
+		t.Fatalf("pawn drop on a file with an own unpromoted pawn should be illegal")
+	}
+}
+
+func TestDroppablePiecesExcludesPawnBlockedByNifu(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][2] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][2] = Piece{Kind: King, Owner: Top, Present: true}
+	for x := 0; x < BoardCols; x++ {
+		state.Board[2][x] = Piece{Kind: Pawn, Owner: Bottom, Present: true}
+	}
+	state.Hands[Bottom][Pawn] = 1
+	state.Hands[Bottom][Silver] = 1
+
+	if got := DroppablePieces(state, Bottom); !reflect.DeepEqual(got, []PieceType{Silver}) {
+		t.Fatalf("DroppablePieces = %v, want only silver", got)
+	}
+}
+
+func TestGenerateLegalDropsAssumingHandRespectsNifu(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][2] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][2] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[2][0] = Piece{Kind: Pawn, Owner: Bottom, Present: true}
+
+	if got := GenerateLegalDrops(state, Bottom, Pawn); len(got) != 0 {
+		t.Fatalf("GenerateLegalDrops without a pawn in hand = %v, want none", got)
+	}
+	drops := GenerateLegalDropsAssumingHand(state, Bottom, Pawn)
+	if len(drops) == 0 {
+		t.Fatalf("GenerateLegalDropsAssumingHand returned no drops")
+	}
+	for _, mv := range drops {
+		if mv.To.X == 0 {
+			t.Fatalf("%s drops on the file of an own pawn", FormatMove(mv))
+		}
+		if mv.To.Y == BoardRows-1 {
+			t.Fatalf("%s drops a pawn where it can never move", FormatMove(mv))
+		}
+	}
+	if state.Hands[Bottom][Pawn] != 0 {
+		t.Fatalf("GenerateLegalDropsAssumingHand changed the hand to %d", state.Hands[Bottom][Pawn])
+	}
+}
+
+func TestRandomGameNeverGeneratesKingCapture(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	state := NewGame()
+	state.CheckInvariants = true
+	for ply := 0; ply < 60; ply++ {
+		moves := GenerateLegalMoves(state, state.Turn)
+		if len(moves) == 0 {
+			break
+		}
+		if kingPos, found := findKing(state, state.Turn.Opponent()); found {
+			for _, mv := range moves {
+				if mv.To == kingPos {
+					t.Fatalf("ply %d: %s targets the opposing king", ply, FormatMove(mv))
+				}
+			}
+		}
+		ApplyMove(&state, moves[rng.Intn(len(moves))])
+		state.Turn = state.Turn.Opponent()
+	}
+}
+
+func TestVerifyStateReportsCorruption(t *testing.T) {
+	if err := VerifyState(NewGame()); err != nil {
+		t.Fatalf("VerifyState(NewGame()) = %v, want nil", err)
+	}
+
+	state := NewGame()
+	state.Board[2][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	err := VerifyState(state)
+	if err == nil || !strings.Contains(err.Error(), "2 kings") {
+		t.Fatalf("VerifyState(two Bottom kings) = %v, want an error naming the extra king", err)
+	}
+
+	state = NewGame()
+	state.Hands[Top][Pawn] = -1
+	if err := VerifyState(state); err == nil {
+		t.Fatalf("VerifyState accepted a negative hand count")
+	}
+	state = NewGame()
+	state.Board[0][1].Promoted = true
+	if err := VerifyState(state); err == nil {
+		t.Fatalf("VerifyState accepted a promoted gold")
+	}
+}
+
+func TestTryApplyMoveInPlaceUndoesToOriginal(t *testing.T) {
+	state := NewGame()
+	original := CloneState(state)
+	var diffs []MoveDiff
+	// Captures by both sides and a drop of the captured pawn exercise every diff field.
+	for _, text := range []string{"b3b4", "c4c3", "P@a5"} {
+		mv, err := ParseMove(text)
+		if err != nil {
+			t.Fatalf("ParseMove(%q) failed: %v", text, err)
+		}
+		legal, diff := TryApplyMoveInPlace(&state, mv)
+		if !legal {
+			t.Fatalf("%s should be legal", text)
+		}
+		diffs = append(diffs, diff)
+		state.Turn = state.Turn.Opponent()
+	}
+	if legal, _ := TryApplyMoveInPlace(&state, Move{From: &Coord{X: 0, Y: 0}, To: Coord{X: 0, Y: 5}}); legal {
+		t.Fatalf("an impossible move was applied")
+	}
+	for i := len(diffs) - 1; i >= 0; i-- {
+		state.Turn = state.Turn.Opponent()
+		UndoMove(&state, diffs[i])
+	}
+	if state.Board != original.Board || PositionKey(state) != PositionKey(original) {
+		t.Fatalf("undoing every diff did not restore the original position")
+	}
+}
+
+func ptrPieceType(pt PieceType) *PieceType {
+	return &pt
+}
@@ -0,0 +1,53 @@
+package game
+
+import "testing"
+
+func hasPromotion(moves []Move) bool {
+	for _, mv := range moves {
+		if mv.Promote {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPromotionZoneDepthOne(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.PromotionZoneDepth = 1
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[3][2] = Piece{Kind: Silver, Owner: Bottom, Present: true}
+	state.Board[4][0] = Piece{Kind: Silver, Owner: Bottom, Present: true}
+	state.Board[2][2] = Piece{Kind: Silver, Owner: Top, Present: true}
+	state.Board[1][4] = Piece{Kind: Silver, Owner: Top, Present: true}
+
+	// Reaching the second-farthest rank no longer allows promotion for either side.
+	if hasPromotion(GenerateLegalMovesFrom(state, Bottom, Coord{X: 2, Y: 3})) {
+		t.Fatalf("bottom silver promoted outside the farthest rank")
+	}
+	if hasPromotion(GenerateLegalMovesFrom(state, Top, Coord{X: 2, Y: 2})) {
+		t.Fatalf("top silver promoted outside the farthest rank")
+	}
+	if !hasPromotion(GenerateLegalMovesFrom(state, Bottom, Coord{X: 0, Y: 4})) {
+		t.Fatalf("bottom silver could not promote on the farthest rank")
+	}
+	if !hasPromotion(GenerateLegalMovesFrom(state, Top, Coord{X: 4, Y: 1})) {
+		t.Fatalf("top silver could not promote on the farthest rank")
+	}
+}
+
+func TestPromotedSilverChecksWithGoldBackwardMove(t *testing.T) {
+	state := newEmptyState(Top)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[3][2] = Piece{Kind: King, Owner: Top, Present: true}
+	// Straight back is a gold move; an unpromoted silver only retreats diagonally.
+	state.Board[4][2] = Piece{Kind: Silver, Owner: Bottom, Promoted: true, Present: true}
+
+	if !InCheck(state, Top) {
+		t.Fatalf("promoted silver on c5 should check the king on c4")
+	}
+	state.Board[4][2].Promoted = false
+	if InCheck(state, Top) {
+		t.Fatalf("unpromoted silver on c5 should not check the king on c4")
+	}
+}
@@ -0,0 +1,57 @@
+package game
+
+import "testing"
+
+func TestShuffledGameIsSymmetric(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		state := NewShuffledGame(seed)
+		counts := [2]map[PieceType]int{make(map[PieceType]int), make(map[PieceType]int)}
+		for y := 0; y < BoardRows; y++ {
+			for x := 0; x < BoardCols; x++ {
+				p := state.Board[y][x]
+				mirrored := state.Board[BoardRows-1-y][BoardCols-1-x]
+				if p.Present != mirrored.Present || (p.Present && (p.Kind != mirrored.Kind || p.Owner == mirrored.Owner)) {
+					t.Fatalf("seed %d: square %d,%d is not mirrored", seed, x, y)
+				}
+				if p.Present {
+					counts[p.Owner][p.Kind]++
+				}
+			}
+		}
+		for _, player := range []Player{Bottom, Top} {
+			if counts[player][King] != 1 || counts[player][Gold] != 2 || counts[player][Silver] != 2 || counts[player][Pawn] != 3 {
+				t.Fatalf("seed %d: unexpected material for %v: %v", seed, player, counts[player])
+			}
+		}
+		if InCheck(state, Bottom) || InCheck(state, Top) {
+			t.Fatalf("seed %d: starting position has a king in check", seed)
+		}
+	}
+}
+
+func TestShuffledGameIsReproducible(t *testing.T) {
+	if NewShuffledGame(42).Board != NewShuffledGame(42).Board {
+		t.Fatalf("same seed produced different setups")
+	}
+	differs := false
+	for seed := int64(1); seed < 20 && !differs; seed++ {
+		differs = NewShuffledGame(seed).Board != NewShuffledGame(0).Board
+	}
+	if !differs {
+		t.Fatalf("expected seeds to produce different setups")
+	}
+}
+
+func TestNewGameWithTurn(t *testing.T) {
+	standard := NewGame()
+	state := NewGameWithTurn(Top)
+	if state.Turn != Top || standard.Turn != Bottom {
+		t.Fatalf("turns = %v and %v, want Top and Bottom", state.Turn, standard.Turn)
+	}
+	if state.Board != standard.Board {
+		t.Fatalf("board differs from the standard start")
+	}
+	if len(state.Hands[Bottom]) != 0 || len(state.Hands[Top]) != 0 {
+		t.Fatalf("hands should start empty")
+	}
+}
@@ -0,0 +1,39 @@
+package game
+
+// kingSquares caches both kings' positions so search nodes need not rescan the board.
+type kingSquares struct {
+	pos   [2]Coord
+	found [2]bool
+}
+
+func findKings(state GameState) kingSquares {
+	var k kingSquares
+	for y := 0; y < BoardRows; y++ {
+		for x := 0; x < BoardCols; x++ {
+			p := state.Board[y][x]
+			if p.Present && p.Kind == King {
+				k.pos[p.Owner] = Coord{X: x, Y: y}
+				k.found[p.Owner] = true
+			}
+		}
+	}
+	return k
+}
+
+// after returns the king squares once mv is played on board, the position before the move.
+func (k kingSquares) after(board *[BoardRows][BoardCols]Piece, mv Move) kingSquares {
+	if mv.From == nil {
+		return k
+	}
+	if moving := board[mv.From.Y][mv.From.X]; moving.Kind == King {
+		k.pos[moving.Owner] = mv.To
+	}
+	if captured := board[mv.To.Y][mv.To.X]; captured.Present && captured.Kind == King {
+		k.found[captured.Owner] = false
+	}
+	return k
+}
+
+func (k kingSquares) inCheck(board *[BoardRows][BoardCols]Piece, player Player) bool {
+	return k.found[player] && isKingThreatened(board, player, k.pos[player])
+}
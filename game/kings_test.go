@@ -0,0 +1,27 @@
+package game
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestKingSquaresTrackRandomGame(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	state := NewGame()
+	kings := findKings(state)
+	for ply := 0; ply < 80; ply++ {
+		moves := GenerateLegalMoves(state, state.Turn)
+		if len(moves) == 0 {
+			break
+		}
+		mv := moves[rng.Intn(len(moves))]
+		kings = kings.after(&state.Board, mv)
+		state = childState(state, mv)
+		for _, player := range []Player{Bottom, Top} {
+			pos, found := findKing(state, player)
+			if found != kings.found[player] || pos != kings.pos[player] {
+				t.Fatalf("ply %d: cached king of %v = %v/%v, board has %v/%v", ply, player, kings.pos[player], kings.found[player], pos, found)
+			}
+		}
+	}
+}
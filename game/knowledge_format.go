@@ -0,0 +1,157 @@
+package game
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// KnowledgeFormat selects how MCTS knowledge is encoded before compression.
+type KnowledgeFormat int
+
+const (
+	// KnowledgeText writes one readable line per position (the default).
+	KnowledgeText KnowledgeFormat = iota
+	// KnowledgeBinary writes length-prefixed records, which are smaller and faster to parse.
+	KnowledgeBinary
+)
+
+// Binary knowledge starts with knowledgeBinaryMagic followed by a version byte. Text
+// knowledge never starts with the magic because state keys begin with a turn digit.
+const (
+	knowledgeBinaryMagic   = "GKB"
+	knowledgeBinaryVersion = 1
+	// maxKnowledgeString rejects corrupt length prefixes before allocating.
+	maxKnowledgeString = 1 << 16
+)
+
+// encodeKnowledgeBinary writes the header followed by one record per position:
+// key, move count, then for each move its text, visits and wins. Strings and counts
+// are uvarint length-prefixed and wins are little-endian float64 bits.
+func encodeKnowledgeBinary(w io.Writer, knowledge map[string]map[string]moveStats) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(knowledgeBinaryMagic); err != nil {
+		return err
+	}
+	if err := bw.WriteByte(knowledgeBinaryVersion); err != nil {
+		return err
+	}
+	var scratch [binary.MaxVarintLen64]byte
+	writeUvarint := func(v uint64) error {
+		_, err := bw.Write(scratch[:binary.PutUvarint(scratch[:], v)])
+		return err
+	}
+	writeString := func(s string) error {
+		if err := writeUvarint(uint64(len(s))); err != nil {
+			return err
+		}
+		_, err := bw.WriteString(s)
+		return err
+	}
+	keys := make([]string, 0, len(knowledge))
+	for key := range knowledge {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		moves := knowledge[key]
+		if err := writeString(key); err != nil {
+			return err
+		}
+		if err := writeUvarint(uint64(len(moves))); err != nil {
+			return err
+		}
+		moveKeys := make([]string, 0, len(moves))
+		for mv := range moves {
+			moveKeys = append(moveKeys, mv)
+		}
+		sort.Strings(moveKeys)
+		for _, mv := range moveKeys {
+			stats := moves[mv]
+			if err := writeString(mv); err != nil {
+				return err
+			}
+			if err := writeUvarint(uint64(max(stats.Visits, 0))); err != nil {
+				return err
+			}
+			binary.LittleEndian.PutUint64(scratch[:8], math.Float64bits(stats.Wins))
+			if _, err := bw.Write(scratch[:8]); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// decodeKnowledgeBinary reads knowledge written by encodeKnowledgeBinary, header included.
+func decodeKnowledgeBinary(r io.Reader) (map[string]map[string]moveStats, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(knowledgeBinaryMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("binary knowledge header: %w", err)
+	}
+	if string(header[:len(knowledgeBinaryMagic)]) != knowledgeBinaryMagic {
+		return nil, errors.New("binary knowledge header is missing")
+	}
+	if version := header[len(knowledgeBinaryMagic)]; version != knowledgeBinaryVersion {
+		return nil, fmt.Errorf("unsupported binary knowledge version %d", version)
+	}
+	readString := func() (string, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return "", err
+		}
+		if n > maxKnowledgeString {
+			return "", fmt.Errorf("binary knowledge string of %d bytes", n)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+	entries := make(map[string]map[string]moveStats)
+	for {
+		if _, err := br.Peek(1); errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		key, err := readString()
+		if err != nil {
+			return nil, fmt.Errorf("binary knowledge state key: %w", err)
+		}
+		count, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("binary knowledge move count: %w", err)
+		}
+		moves := make(map[string]moveStats, min(count, 64))
+		for i := uint64(0); i < count; i++ {
+			mv, err := readString()
+			if err != nil {
+				return nil, fmt.Errorf("binary knowledge move: %w", err)
+			}
+			visits, err := binary.ReadUvarint(br)
+			if err != nil {
+				return nil, fmt.Errorf("binary knowledge visits: %w", err)
+			}
+			var wins [8]byte
+			if _, err := io.ReadFull(br, wins[:]); err != nil {
+				return nil, fmt.Errorf("binary knowledge wins: %w", err)
+			}
+			moves[mv] = moveStats{Visits: int(visits), Wins: math.Float64frombits(binary.LittleEndian.Uint64(wins[:]))}
+		}
+		entries[key] = moves
+	}
+}
+
+// decodeKnowledgeAuto reads knowledge in either format, detected from the header.
+func decodeKnowledgeAuto(r io.Reader) (map[string]map[string]moveStats, error) {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(knowledgeBinaryMagic)); string(head) == knowledgeBinaryMagic {
+		return decodeKnowledgeBinary(br)
+	}
+	return decodeKnowledge(br)
+}
@@ -0,0 +1,56 @@
+package game
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestKnowledgeBinaryRoundTrip(t *testing.T) {
+	knowledge := map[string]map[string]moveStats{
+		encodeStateKey(NewGame()): {"b3b4": {Visits: 12, Wins: 7.5}, "c3c4": {Visits: 3, Wins: 0}},
+		"empty":                   {},
+	}
+	var buf bytes.Buffer
+	if err := encodeKnowledgeBinary(&buf, knowledge); err != nil {
+		t.Fatalf("encodeKnowledgeBinary failed: %v", err)
+	}
+	decoded, err := decodeKnowledgeAuto(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("decodeKnowledgeAuto failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, knowledge) {
+		t.Fatalf("round trip = %v, want %v", decoded, knowledge)
+	}
+
+	if _, err := decodeKnowledgeBinary(bytes.NewReader(buf.Bytes()[:buf.Len()-3])); err == nil {
+		t.Fatalf("truncated binary knowledge should fail to decode")
+	}
+	future := append([]byte(knowledgeBinaryMagic), knowledgeBinaryVersion+1)
+	if _, err := decodeKnowledgeAuto(bytes.NewReader(future)); err == nil {
+		t.Fatalf("unknown binary version should fail to decode")
+	}
+}
+
+func TestMCTSEngineLoadsEitherKnowledgeFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []KnowledgeFormat{KnowledgeText, KnowledgeBinary} {
+		storage := filepath.Join(t.TempDir(), "mcts.json")
+		writer := newLoadedMCTSEngine(t, 32, 1, storage)
+		writer.SetKnowledgeFormat(format)
+		if _, err := writer.NextMove(NewGame()); err != nil {
+			t.Fatalf("NextMove failed: %v", err)
+		}
+		if err := writer.SaveIfNeeded(); err != nil {
+			t.Fatalf("SaveIfNeeded failed: %v", err)
+		}
+
+		// The reader keeps the default text format; loading must not depend on it.
+		reader := newLoadedMCTSEngine(t, 32, 1, storage)
+		if !reflect.DeepEqual(reader.knowledge.snapshot(), writer.knowledge.snapshot()) {
+			t.Fatalf("format %d: reloaded %d positions, want %d", format, reader.knowledge.len(), writer.knowledge.len())
+		}
+	}
+}
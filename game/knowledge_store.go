@@ -0,0 +1,190 @@
+package game
+
+import (
+	"hash/maphash"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const knowledgeShardCount = 64
+
+// knowledgeStore holds what an engine learned per position key. It is sharded by key hash
+// so parallel games sharing one engine only wait for each other when they touch positions
+// in the same shard.
+type knowledgeStore[V any] struct {
+	seed   maphash.Seed
+	shards [knowledgeShardCount]knowledgeShard[V]
+	// dirty is set by every change and cleared when the store is saved.
+	dirty atomic.Bool
+	// lruMu guards lru, which bounds the positions when set. It is taken before shard
+	// locks, never while holding one. bounded mirrors lru != nil so unbounded stores skip
+	// the lock.
+	lruMu   sync.Mutex
+	lru     *keyLRU
+	bounded atomic.Bool
+}
+
+type knowledgeShard[V any] struct {
+	mu     sync.RWMutex
+	states map[string]V
+}
+
+func newKnowledgeStore[V any]() *knowledgeStore[V] {
+	store := &knowledgeStore[V]{seed: maphash.MakeSeed()}
+	for i := range store.shards {
+		store.shards[i].states = make(map[string]V)
+	}
+	return store
+}
+
+func (s *knowledgeStore[V]) shard(key string) *knowledgeShard[V] {
+	return &s.shards[maphash.String(s.seed, key)%knowledgeShardCount]
+}
+
+// get returns the value of key without counting it as a use.
+func (s *knowledgeStore[V]) get(key string) (V, bool) {
+	shard := s.shard(key)
+	shard.mu.RLock()
+	value, ok := shard.states[key]
+	shard.mu.RUnlock()
+	return value, ok
+}
+
+// view calls fn with the value of key under the shard's read lock, so fn may read values
+// that update changes in place. A found key counts as a use.
+func (s *knowledgeStore[V]) view(key string, fn func(value V, ok bool)) {
+	shard := s.shard(key)
+	shard.mu.RLock()
+	value, ok := shard.states[key]
+	fn(value, ok)
+	shard.mu.RUnlock()
+	if ok {
+		s.touch(key)
+	}
+}
+
+// update stores fn(current value, found) for key under the shard's write lock.
+func (s *knowledgeStore[V]) update(key string, fn func(value V, ok bool) V) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	value, ok := shard.states[key]
+	shard.states[key] = fn(value, ok)
+	shard.mu.Unlock()
+	s.dirty.Store(true)
+	s.touch(key)
+}
+
+func (s *knowledgeStore[V]) set(key string, value V) {
+	s.update(key, func(V, bool) V { return value })
+}
+
+// addMissing stores the entries whose keys are not present, keeping the current values.
+func (s *knowledgeStore[V]) addMissing(entries map[string]V) {
+	for key, value := range entries {
+		shard := s.shard(key)
+		shard.mu.Lock()
+		_, ok := shard.states[key]
+		if !ok {
+			shard.states[key] = value
+		}
+		shard.mu.Unlock()
+		if !ok {
+			s.touch(key)
+		}
+	}
+}
+
+// each calls fn for every entry, holding one shard's read lock at a time.
+func (s *knowledgeStore[V]) each(fn func(key string, value V)) {
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		for key, value := range shard.states {
+			fn(key, value)
+		}
+		shard.mu.RUnlock()
+	}
+}
+
+// snapshot copies the entries into one map; the values themselves are shared.
+func (s *knowledgeStore[V]) snapshot() map[string]V {
+	entries := make(map[string]V, s.len())
+	s.each(func(key string, value V) {
+		entries[key] = value
+	})
+	return entries
+}
+
+func (s *knowledgeStore[V]) len() int {
+	n := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		n += len(shard.states)
+		shard.mu.RUnlock()
+	}
+	return n
+}
+
+// reset removes every entry, keeping the bound set by setMaxStates.
+func (s *knowledgeStore[V]) reset() {
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		shard.states = make(map[string]V)
+		shard.mu.Unlock()
+	}
+	if s.lru != nil {
+		s.lru = newKeyLRU(s.lru.limit)
+	}
+	s.dirty.Store(true)
+}
+
+// setMaxStates bounds the store to n positions, evicting the least recently used ones.
+// n <= 0 removes the bound.
+func (s *knowledgeStore[V]) setMaxStates(n int) {
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	s.bounded.Store(n > 0)
+	if n <= 0 {
+		s.lru = nil
+		return
+	}
+	s.lru = newKeyLRU(n)
+	keys := make([]string, 0, s.len())
+	s.each(func(key string, _ V) {
+		keys = append(keys, key)
+	})
+	sort.Strings(keys)
+	for _, key := range keys {
+		s.lru.touch(key)
+	}
+	s.evictLocked()
+}
+
+// touch records a use of key and evicts positions beyond the bound.
+func (s *knowledgeStore[V]) touch(key string) {
+	if !s.bounded.Load() {
+		return
+	}
+	s.lruMu.Lock()
+	defer s.lruMu.Unlock()
+	if s.lru == nil {
+		return
+	}
+	s.lru.touch(key)
+	s.evictLocked()
+}
+
+func (s *knowledgeStore[V]) evictLocked() {
+	s.lru.evict(func(key string) {
+		shard := s.shard(key)
+		shard.mu.Lock()
+		delete(shard.states, key)
+		shard.mu.Unlock()
+		s.dirty.Store(true)
+	})
+}
@@ -0,0 +1,123 @@
+package game
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestKnowledgeStoreConcurrentUpdatesAccumulate(t *testing.T) {
+	t.Parallel()
+
+	const workers, rounds = 8, 500
+	store := newKnowledgeStore[int]()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				// Every worker bumps the shared keys and a key of its own.
+				for _, key := range []string{"shared", fmt.Sprintf("shared-%d", i%10), fmt.Sprintf("own-%d", w)} {
+					store.update(key, func(n int, _ bool) int { return n + 1 })
+				}
+				store.view("shared", func(int, bool) {})
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n, _ := store.get("shared"); n != workers*rounds {
+		t.Fatalf("shared count = %d, want %d", n, workers*rounds)
+	}
+	for i := 0; i < 10; i++ {
+		if n, _ := store.get(fmt.Sprintf("shared-%d", i)); n != workers*rounds/10 {
+			t.Fatalf("shared-%d count = %d, want %d", i, n, workers*rounds/10)
+		}
+	}
+	for w := 0; w < workers; w++ {
+		if n, _ := store.get(fmt.Sprintf("own-%d", w)); n != rounds {
+			t.Fatalf("own-%d count = %d, want %d", w, n, rounds)
+		}
+	}
+	if got, want := store.len(), 1+10+workers; got != want {
+		t.Fatalf("store holds %d keys, want %d", got, want)
+	}
+}
+
+func TestKnowledgeStoreBoundHoldsUnderConcurrentUpdates(t *testing.T) {
+	t.Parallel()
+
+	store := newKnowledgeStore[int]()
+	store.setMaxStates(20)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				store.set(fmt.Sprintf("%d-%d", w, i), i)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := store.len(); n > 20 {
+		t.Fatalf("store holds %d keys, want at most 20", n)
+	}
+}
+
+func TestSharedEnginesPlayParallelGames(t *testing.T) {
+	t.Parallel()
+
+	const games = 4
+	mcts := newLoadedMCTSEngine(t, 50, 1, filepath.Join(t.TempDir(), "mcts.json"))
+	td := NewTDUCBEngine(1)
+	td.simulations = 30
+	engines := map[string]Engine{"mcts": mcts, "td-ucb": td}
+
+	for name, engine := range engines {
+		var wg sync.WaitGroup
+		errs := make(chan error, games)
+		for g := 0; g < games; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				state := NewGame()
+				for ply := 0; ply < 3; ply++ {
+					mv, err := engine.NextMove(state)
+					if err != nil {
+						errs <- err
+						return
+					}
+					ApplyMove(&state, mv)
+					state.Turn = state.Turn.Opponent()
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("%s: NextMove failed: %v", name, err)
+		}
+	}
+
+	if _, ok := mcts.knowledge.get(encodeStateKey(NewGame())); !ok {
+		t.Fatalf("mcts: the shared opening position was not learned")
+	}
+	if err := mcts.SaveIfNeeded(); err != nil {
+		t.Fatalf("mcts: SaveIfNeeded failed: %v", err)
+	}
+	// Every simulation of every game updates the opening position at least once; a lost
+	// update would leave fewer visits.
+	visits := 0
+	td.states.view(td.stateKey(NewGame()), func(st *tdState, _ bool) {
+		for _, entry := range st.moves {
+			visits += entry.visits
+		}
+	})
+	if want := games * td.simulations; visits < want {
+		t.Fatalf("td-ucb: opening position has %d visits, want at least %d", visits, want)
+	}
+}
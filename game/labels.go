@@ -0,0 +1,55 @@
+package game
+
+import "strings"
+
+// LabelSet names players and pieces in user-facing text.
+type LabelSet struct {
+	Players  [2]string
+	Pieces   map[PieceType]string
+	Promoted map[PieceType]string
+}
+
+// JapaneseLabels is the default label set.
+var JapaneseLabels = LabelSet{
+	Players:  [2]string{Bottom: "先手", Top: "後手"},
+	Pieces:   map[PieceType]string{King: "玉", Gold: "金", Silver: "銀", Pawn: "歩"},
+	Promoted: map[PieceType]string{Silver: "成銀", Pawn: "と"},
+}
+
+// EnglishLabels names players by board side and pieces by their English names.
+var EnglishLabels = LabelSet{
+	Players:  [2]string{Bottom: "Bottom", Top: "Top"},
+	Pieces:   map[PieceType]string{King: "King", Gold: "Gold", Silver: "Silver", Pawn: "Pawn"},
+	Promoted: map[PieceType]string{Silver: "Promoted Silver", Pawn: "Tokin"},
+}
+
+// LabelSetForLanguage picks English when the first language of an Accept-Language style
+// value is English and Japanese otherwise.
+func LabelSetForLanguage(language string) LabelSet {
+	first, _, _ := strings.Cut(language, ",")
+	first, _, _ = strings.Cut(first, ";")
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(first)), "en") {
+		return EnglishLabels
+	}
+	return JapaneseLabels
+}
+
+func (l LabelSet) PlayerName(p Player) string {
+	return l.Players[p]
+}
+
+func (l LabelSet) PieceName(pt PieceType, promoted bool) string {
+	if name, ok := l.Promoted[pt]; promoted && ok {
+		return name
+	}
+	return l.Pieces[pt]
+}
+
+// MoveText describes mv played in state as the moving piece's name followed by its notation.
+func (l LabelSet) MoveText(state GameState, mv Move) string {
+	if mv.Drop != nil {
+		return l.PieceName(*mv.Drop, false) + " " + FormatMove(mv)
+	}
+	p := state.Board[mv.From.Y][mv.From.X]
+	return l.PieceName(p.Kind, p.Promoted) + " " + FormatMove(mv)
+}
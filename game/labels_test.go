@@ -0,0 +1,31 @@
+package game
+
+import "testing"
+
+func TestLabelSetNamesMovedPiece(t *testing.T) {
+	state := NewGame()
+	mv, err := ParseMove("b3b4")
+	if err != nil {
+		t.Fatalf("ParseMove failed: %v", err)
+	}
+	if got := JapaneseLabels.MoveText(state, mv); got != "歩 b3b4" {
+		t.Fatalf("Japanese = %q", got)
+	}
+	if got := EnglishLabels.MoveText(state, mv); got != "Pawn b3b4" {
+		t.Fatalf("English = %q", got)
+	}
+	if got := EnglishLabels.PieceName(Pawn, true); got != "Tokin" {
+		t.Fatalf("promoted pawn = %q", got)
+	}
+}
+
+func TestLabelSetForLanguage(t *testing.T) {
+	if got := LabelSetForLanguage("en-US,en;q=0.9,ja;q=0.8").PlayerName(Bottom); got != "Bottom" {
+		t.Fatalf("English header picked %q", got)
+	}
+	for _, header := range []string{"", "ja", "fr-FR,en;q=0.5"} {
+		if got := LabelSetForLanguage(header).PlayerName(Top); got != "後手" {
+			t.Fatalf("%q picked %q", header, got)
+		}
+	}
+}
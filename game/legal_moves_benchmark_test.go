@@ -138,3 +138,21 @@ func BenchmarkGenerateLegalMovesFrom(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkKingLookup compares rescanning the board for both kings with updating cached
+// squares after a move, as search nodes do.
+func BenchmarkKingLookup(b *testing.B) {
+	state := NewGame()
+	mv := GenerateLegalMoves(state, Bottom)[0]
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			runtime.KeepAlive(findKings(state))
+		}
+	})
+	b.Run("incremental", func(b *testing.B) {
+		kings := findKings(state)
+		for i := 0; i < b.N; i++ {
+			runtime.KeepAlive(kings.after(&state.Board, mv))
+		}
+	})
+}
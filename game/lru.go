@@ -0,0 +1,34 @@
+package game
+
+import "container/list"
+
+// keyLRU orders stored position keys by last access so knowledge maps can be bounded by
+// evicting the least recently used positions.
+type keyLRU struct {
+	limit int
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newKeyLRU(limit int) *keyLRU {
+	return &keyLRU{limit: limit, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// touch marks key as the most recently used.
+func (l *keyLRU) touch(key string) {
+	if elem, ok := l.items[key]; ok {
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.order.PushFront(key)
+}
+
+// evict removes the least recently used keys beyond the limit, calling remove for each.
+func (l *keyLRU) evict(remove func(key string)) {
+	for l.order.Len() > l.limit {
+		elem := l.order.Back()
+		key := l.order.Remove(elem).(string)
+		delete(l.items, key)
+		remove(key)
+	}
+}
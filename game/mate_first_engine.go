@@ -0,0 +1,47 @@
+package game
+
+import (
+	"context"
+	"errors"
+)
+
+// mateFirstMaxNodes bounds the mate search of MateFirstEngine so a bushy position cannot
+// stall a move; an aborted search falls back to the base engine.
+const mateFirstMaxNodes = 50000
+
+// MateFirstEngine plays a forced mate found by a shallow mate search and otherwise asks the
+// base engine, so engines with noisy playouts never miss a short mate.
+type MateFirstEngine struct {
+	base  Engine
+	depth int
+}
+
+// NewMateFirstEngine wraps base with a mate search of depth plies (1 finds mate in one).
+func NewMateFirstEngine(base Engine, depth int) (*MateFirstEngine, error) {
+	if base == nil {
+		return nil, errors.New("mate-first: base engine is required")
+	}
+	if depth <= 0 {
+		return nil, errors.New("mate-first: depth must be positive")
+	}
+	if err := checkSearchDepth(depth); err != nil {
+		return nil, err
+	}
+	return &MateFirstEngine{base: base, depth: depth}, nil
+}
+
+// Base returns the wrapped engine.
+func (e *MateFirstEngine) Base() Engine {
+	return e.base
+}
+
+func (e *MateFirstEngine) NextMove(state GameState) (Move, error) {
+	found, line, err := MateSearchContext(context.Background(), state, state.Turn, e.depth, mateFirstMaxNodes)
+	if err != nil {
+		return Move{}, err
+	}
+	if found && len(line) > 0 {
+		return line[0], nil
+	}
+	return e.base.NextMove(state)
+}
@@ -0,0 +1,33 @@
+package game
+
+import "testing"
+
+func TestMateFirstEnginePlaysMateInOne(t *testing.T) {
+	t.Parallel()
+
+	// The drop mate of TestMateSearchDropMate; one MCTS iteration cannot find it alone.
+	state := newEmptyState(Bottom)
+	state.Board[5][0] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[3][1] = Piece{Kind: Silver, Owner: Bottom, Present: true}
+	state.Board[4][2] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+	state.Hands[Bottom][Pawn] = 1
+
+	engine, err := NewMateFirstEngine(NewMCTSEngine(1, 1), 1)
+	if err != nil {
+		t.Fatalf("NewMateFirstEngine failed: %v", err)
+	}
+	mv, err := engine.NextMove(state)
+	if err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+	next := CloneState(state)
+	ApplyMove(&next, mv)
+	next.Turn = Top
+	if !IsCheckmate(next, Top) {
+		t.Fatalf("mate-first engine played %s, want the mate", FormatMove(mv))
+	}
+
+	if _, err := NewMateFirstEngine(nil, 1); err == nil {
+		t.Fatalf("NewMateFirstEngine accepted a nil base engine")
+	}
+}
@@ -1,6 +1,10 @@
 package game
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+)
 
 func TestMateSearchDropMate(t *testing.T) {
 	state := newEmptyState(Bottom)
@@ -43,3 +47,89 @@ func TestMateSearchNoMateAvailable(t *testing.T) {
 		t.Fatalf("expected no mate in empty king vs king scenario")
 	}
 }
+
+func TestMateSearchTreatsRepetitionAsEscape(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Turn = Bottom
+	state.Board[5][0] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[3][1] = Piece{Kind: Silver, Owner: Bottom, Present: true}
+	state.Board[4][2] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+	state.Hands[Bottom][Pawn] = 1
+
+	// The position is already on the line, so the defender can repeat into it forever.
+	search := &mateSearcher{ctx: context.Background(), attacker: Bottom, defender: Top, path: map[string]bool{PositionKey(state): true}}
+	if mate, _ := search.search(state, 3); mate {
+		t.Fatalf("repeated position must not be reported as mate")
+	}
+
+	// A lone gold cannot mate; the king shuffles back and forth instead.
+	chase := newEmptyState(Bottom)
+	chase.Turn = Bottom
+	chase.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	chase.Board[5][2] = Piece{Kind: King, Owner: Top, Present: true}
+	chase.Board[3][2] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+	if mate, line := MateSearch(chase, Bottom, 7); mate {
+		t.Fatalf("unexpected mate %v", line)
+	}
+}
+
+func TestMateSearchContextStopsAtNodeBudget(t *testing.T) {
+	start := time.Now()
+	mate, line, _ := MateSearchContext(context.Background(), NewGame(), Bottom, 15, 100)
+	if mate || line != nil {
+		t.Fatalf("expected an aborted search to report no mate, got %v %v", mate, line)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("node budget did not stop the search quickly: %v", elapsed)
+	}
+}
+
+func TestMateSearchContextStopsAtDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if mate, _, _ := MateSearchContext(ctx, NewGame(), Bottom, 15, 0); mate {
+		t.Fatalf("expected no mate from an aborted search")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("deadline did not stop the search quickly: %v", elapsed)
+	}
+}
+
+func TestMateSearchStatsCountsNodes(t *testing.T) {
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[2][2] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+
+	previous := 0
+	for depth := 1; depth <= 3; depth++ {
+		_, _, stats, _ := MateSearchStats(context.Background(), state, Bottom, depth, 0)
+		if stats.Nodes <= previous {
+			t.Fatalf("depth %d visited %d nodes, want more than %d", depth, stats.Nodes, previous)
+		}
+		previous = stats.Nodes
+	}
+}
+
+func TestMateSearchCountsNoMovesAsMate(t *testing.T) {
+	// The gold shuts in the king on a6 from c6 without giving check.
+	state := newEmptyState(Bottom)
+	state.Board[3][1] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][0] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[5][3] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+
+	mate, line := MateSearch(state, Bottom, 1)
+	if !mate || len(line) != 1 {
+		t.Fatalf("MateSearch = %v %v, want a one-move win", mate, line)
+	}
+	ApplyMove(&state, line[0])
+	state.Turn = Top
+	if result := DetermineResult(state, nil); result.Reason != ReasonNoMoves {
+		t.Fatalf("result after %s = %+v, want %q", FormatMove(line[0]), result, ReasonNoMoves)
+	}
+
+	if mate, _ := MateSearch(newNoMovesState(), Top, 1); !mate {
+		t.Fatalf("expected a defender without moves to count as mated")
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math"
 	"math/rand"
 	"os"
@@ -17,12 +18,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
-	defaultMCTSIterations  = 800
-	defaultMCTSExploration = 1.2
-	mctsRolloutDepth       = 60
+	defaultMCTSIterations   = 800
+	defaultMCTSExploration  = 1.2
+	defaultMCTSRolloutDepth = 60
 )
 
 type moveStats struct {
@@ -34,74 +37,507 @@ type storedKnowledge struct {
 	States map[string]map[string]moveStats `json:"states"`
 }
 
+// RolloutPolicy selects how MCTS playouts pick moves.
+type RolloutPolicy int
+
+const (
+	// RolloutUniform picks playout moves uniformly at random.
+	RolloutUniform RolloutPolicy = iota
+	// RolloutCaptures picks a random capture when one exists, otherwise any move.
+	RolloutCaptures
+)
+
+// ParseRolloutPolicy maps "uniform" (or "") and "captures" to a RolloutPolicy.
+func ParseRolloutPolicy(name string) (RolloutPolicy, error) {
+	switch name {
+	case "", "uniform":
+		return RolloutUniform, nil
+	case "captures":
+		return RolloutCaptures, nil
+	default:
+		return RolloutUniform, fmt.Errorf("mcts: unknown rollout policy %q", name)
+	}
+}
+
+// SelectionCriterion chooses which root child MCTS plays once the search ends.
+type SelectionCriterion int
+
+const (
+	// SelectMostVisits plays the most visited child.
+	SelectMostVisits SelectionCriterion = iota
+	// SelectBestWinRate plays the child with the highest win rate among those with enough
+	// visits to be trusted.
+	SelectBestWinRate
+	// SelectRobust plays the most visited child among those whose win rate is within
+	// robustWinRateMargin of the best trusted win rate.
+	SelectRobust
+)
+
+const (
+	// minSelectionVisits and minSelectionShare keep a child out of win-rate selection until it
+	// has at least this many visits and this share of the most visited child's visits.
+	minSelectionVisits  = 8
+	minSelectionShare   = 0.1
+	robustWinRateMargin = 0.05
+)
+
+// ParseSelectionCriterion maps "most-visits" (or ""), "best-winrate" and "robust" to a
+// SelectionCriterion.
+func ParseSelectionCriterion(name string) (SelectionCriterion, error) {
+	switch name {
+	case "", "most-visits":
+		return SelectMostVisits, nil
+	case "best-winrate":
+		return SelectBestWinRate, nil
+	case "robust":
+		return SelectRobust, nil
+	default:
+		return SelectMostVisits, fmt.Errorf("mcts: unknown selection criterion %q", name)
+	}
+}
+
 type MCTSEngine struct {
-	iterations  int
-	exploration float64
+	iterations    int
+	exploration   float64
+	rolloutDepth  int
+	rolloutPolicy RolloutPolicy
+	// rolloutEval scores playouts that hit the depth limit; nil uses the default EvalConfig,
+	// like Evaluate.
+	rolloutEval evaluationFunc
+	// simulations counts playouts run by NextMove.
+	simulations atomic.Int64
 	rng         *rand.Rand
 	storagePath string
-	knowledge   map[string]map[string]moveStats
-	dirty       bool
-	mu          sync.Mutex
+	// knowledge maps position keys to move statistics. Stored maps are never modified,
+	// only replaced, so they can be read after the store's lock is released.
+	knowledge *knowledgeStore[map[string]moveStats]
+	// saveMu serializes writes of storagePath; see saveLocked.
+	saveMu sync.Mutex
+	// storageModTime is the mtime of storagePath when it was last loaded or saved.
+	storageModTime time.Time
+	// compressionLevel is the gzip level used when saving knowledge.
+	compressionLevel int
+	// knowledgeFormat is the encoding used when saving; loading detects either format.
+	knowledgeFormat KnowledgeFormat
+	// reuseTree keeps the subtree after the chosen move so the next search can continue it.
+	reuseTree   bool
+	reusedRoot  *mctsNode
+	reusedOwner Player
+	// expectedReply is the opponent's most visited reply in the last search; see
+	// ExpectedReply.
+	expectedReply *Move
+	// maxNodes caps the nodes of one search tree (0 means no cap); lastTreeSize is the
+	// size reached by the latest search.
+	maxNodes     int
+	lastTreeSize int
+	// priorCap limits the visits seeded from stored knowledge (0 means no limit).
+	priorCap int
+	// selection picks the move played from the finished search.
+	selection SelectionCriterion
+	// contempt lowers the reward of undecided playouts; see SetContempt.
+	contempt int
+	// warmupPending is set until Warmup has loaded the knowledge of a deferred engine.
+	warmupPending bool
+	// logger reports failures NextMove cannot return, such as saving knowledge.
+	logger *slog.Logger
+	mu     sync.Mutex
 }
 
 func NewMCTSEngine(iterations int, seed int64) *MCTSEngine {
-	return NewPersistentMCTSEngine(iterations, seed, "")
+	return newMCTSEngine(iterations, seed, "")
+}
+
+// NewPersistentMCTSEngine loads the knowledge stored at storagePath and saves what it
+// learns there. The engine is usable even when loading fails: it starts without the stored
+// knowledge, keeps saving, and the error is returned alongside it.
+func NewPersistentMCTSEngine(iterations int, seed int64, storagePath string) (*MCTSEngine, error) {
+	engine := newMCTSEngine(iterations, seed, storagePath)
+	if err := engine.loadKnowledge(); err != nil {
+		return engine, fmt.Errorf("mcts: failed to load knowledge: %w", err)
+	}
+	return engine, nil
+}
+
+// NewDeferredMCTSEngine is NewPersistentMCTSEngine without reading storagePath, which is
+// left to Warmup. Until then the engine searches without stored knowledge and does not save.
+func NewDeferredMCTSEngine(iterations int, seed int64, storagePath string) *MCTSEngine {
+	engine := newMCTSEngine(iterations, seed, storagePath)
+	engine.warmupPending = storagePath != ""
+	return engine
+}
+
+// Warmup loads the stored knowledge of a deferred engine without blocking its searches.
+// Positions learned in the meantime are kept over the stored ones.
+func (e *MCTSEngine) Warmup(ctx context.Context) error {
+	e.mu.Lock()
+	pending := e.warmupPending
+	e.mu.Unlock()
+	if !pending {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	loader := newMCTSEngine(e.iterations, 0, e.storagePath)
+	if err := loader.loadKnowledge(); err != nil {
+		// Like the eager constructor, give up on the unreadable file but keep saving, so
+		// persistence is not silently disabled for the rest of the session.
+		e.mu.Lock()
+		if e.warmupPending {
+			e.storageModTime = loader.storageModTime
+			e.warmupPending = false
+		}
+		e.mu.Unlock()
+		return err
+	}
+	// A cancelled warmup leaves the engine unsaved, since its owner is discarding it.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.warmupPending {
+		// ResetKnowledge ran while loading; the loaded knowledge is stale.
+		return nil
+	}
+	e.knowledge.addMissing(loader.knowledge.snapshot())
+	e.storageModTime = loader.storageModTime
+	e.warmupPending = false
+	return nil
 }
 
-func NewPersistentMCTSEngine(iterations int, seed int64, storagePath string) *MCTSEngine {
+func newMCTSEngine(iterations int, seed int64, storagePath string) *MCTSEngine {
 	if iterations <= 0 {
 		iterations = defaultMCTSIterations
 	}
-	engine := &MCTSEngine{
-		iterations:  iterations,
-		exploration: defaultMCTSExploration,
-		rng:         rand.New(rand.NewSource(seed)),
-		storagePath: storagePath,
-		knowledge:   make(map[string]map[string]moveStats),
+	return &MCTSEngine{
+		iterations:       iterations,
+		exploration:      defaultMCTSExploration,
+		rolloutDepth:     defaultMCTSRolloutDepth,
+		rng:              rand.New(rand.NewSource(seed)),
+		storagePath:      storagePath,
+		knowledge:        newKnowledgeStore[map[string]moveStats](),
+		compressionLevel: gzip.DefaultCompression,
+		logger:           slog.New(slog.DiscardHandler),
 	}
-	if err := engine.loadKnowledge(); err != nil {
-		log.Printf("mcts: failed to load knowledge: %v", err)
+}
+
+// SetLogger sets where the engine reports failures it cannot return, such as a knowledge
+// save after NextMove. A nil logger discards them, as does a new engine.
+func (e *MCTSEngine) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
 	}
-	return engine
+	e.mu.Lock()
+	e.logger = logger
+	e.mu.Unlock()
 }
 
-func (e *MCTSEngine) SaveIfNeeded() error {
+// SetRolloutDepth limits random playouts to depth plies before falling back to the
+// rollout evaluation. Shorter rollouts trade accuracy for more iterations per second.
+func (e *MCTSEngine) SetRolloutDepth(depth int) error {
+	if depth <= 0 {
+		return errors.New("mcts: rollout depth must be positive")
+	}
 	e.mu.Lock()
-	defer e.mu.Unlock()
+	e.rolloutDepth = depth
+	e.mu.Unlock()
+	return nil
+}
+
+// SetRolloutPolicy changes how playout moves are chosen.
+func (e *MCTSEngine) SetRolloutPolicy(policy RolloutPolicy) {
+	e.mu.Lock()
+	e.rolloutPolicy = policy
+	e.mu.Unlock()
+}
+
+// SetRolloutEvaluation scores unfinished playouts with cfg instead of the default
+// material evaluation.
+func (e *MCTSEngine) SetRolloutEvaluation(cfg EvalConfig) {
+	e.mu.Lock()
+	e.rolloutEval = cfg.function()
+	e.mu.Unlock()
+}
+
+// SetMaxNodes bounds the search tree to n nodes; once reached, iterations only revisit
+// existing nodes. n <= 0 removes the cap.
+func (e *MCTSEngine) SetMaxNodes(n int) {
+	e.mu.Lock()
+	e.maxNodes = n
+	e.mu.Unlock()
+}
+
+// SetPriorCap limits how many visits a stored move may bring into a new search. Larger
+// counts are scaled down to n keeping their win rate, so fresh playouts can override
+// outdated knowledge. n <= 0 uses the stored counts as they are.
+func (e *MCTSEngine) SetPriorCap(n int) {
+	e.mu.Lock()
+	e.priorCap = n
+	e.mu.Unlock()
+}
+
+// SetContempt makes undecided playouts count as slightly lost for the searching side, so
+// it prefers playing on over a drawish line. contempt is in evaluation units: a draw
+// scores 0.5 - contempt/(2*king value), clamped to [0, 0.5]. 0 keeps draws at 0.5.
+func (e *MCTSEngine) SetContempt(contempt int) {
+	e.mu.Lock()
+	e.contempt = contempt
+	e.mu.Unlock()
+}
+
+// drawReward converts contempt into the reward backpropagated for undecided playouts.
+func drawReward(contempt int) float64 {
+	reward := 0.5 - float64(contempt)/float64(2*pieceScores[King])
+	return min(max(reward, 0), 0.5)
+}
+
+// SetSelectionCriterion sets how the move is chosen from the finished search.
+func (e *MCTSEngine) SetSelectionCriterion(criterion SelectionCriterion) {
+	e.mu.Lock()
+	e.selection = criterion
+	e.mu.Unlock()
+}
+
+// SetMaxStates bounds the stored knowledge to n positions, evicting the least recently read
+// or written ones. n <= 0 removes the bound.
+func (e *MCTSEngine) SetMaxStates(n int) {
+	e.knowledge.setMaxStates(n)
+}
+
+// SetCompressionLevel sets the gzip level of saved knowledge, from gzip.HuffmanOnly to
+// gzip.BestCompression. gzip.BestSpeed saves faster at the cost of larger files.
+func (e *MCTSEngine) SetCompressionLevel(level int) error {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return fmt.Errorf("mcts: invalid compression level %d", level)
+	}
+	e.mu.Lock()
+	e.compressionLevel = level
+	e.mu.Unlock()
+	return nil
+}
+
+// SetKnowledgeFormat sets the encoding of saved knowledge. Files in either format load
+// regardless of this setting.
+func (e *MCTSEngine) SetKnowledgeFormat(format KnowledgeFormat) {
+	e.mu.Lock()
+	e.knowledgeFormat = format
+	e.mu.Unlock()
+}
+
+// Simulations returns the total number of playouts run so far.
+func (e *MCTSEngine) Simulations() int64 {
+	return e.simulations.Load()
+}
+
+// SetTreeReuse enables carrying the search tree over between consecutive moves of a game.
+func (e *MCTSEngine) SetTreeReuse(enabled bool) {
+	e.mu.Lock()
+	e.reuseTree = enabled
+	e.reusedRoot = nil
+	e.mu.Unlock()
+}
+
+// ResetKnowledge forgets everything the engine learned and rewrites its storage file with
+// no positions, even if another process changed the file since it was loaded. The engine
+// keeps playing, untrained.
+func (e *MCTSEngine) ResetKnowledge() error {
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+	e.mu.Lock()
+	e.knowledge.reset()
+	e.reusedRoot = nil
+	e.warmupPending = false
+	if e.storagePath == "" {
+		e.mu.Unlock()
+		return nil
+	}
+	modTime, err := storageModTime(e.storagePath)
+	if err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	e.storageModTime = modTime
+	e.mu.Unlock()
+	return e.saveLocked()
+}
+
+func (e *MCTSEngine) SaveIfNeeded() error {
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
 	return e.saveLocked()
 }
 
 func (e *MCTSEngine) NextMove(state GameState) (Move, error) {
-	legal := GenerateLegalMoves(state, state.Turn)
-	if len(legal) == 0 {
+	return e.NextMoveContext(context.Background(), state)
+}
+
+// NextMoveContext is NextMove that gives up with ctx's error once ctx is done. An abandoned
+// search learns nothing.
+func (e *MCTSEngine) NextMoveContext(ctx context.Context, state GameState) (Move, error) {
+	// Move generation changes the hands in place, so it runs on a copy: callers may share
+	// state between parallel searches.
+	rootState := CloneState(state)
+	if !HasLegalMove(rootState, rootState.Turn) {
 		return Move{}, errors.New("no legal moves to play")
 	}
+	rootPlayer := state.Turn
+	root := e.takeReusableRoot(rootState, rootPlayer)
+	stateKey, prior := e.snapshotKnowledge(rootState)
+	settings := e.searchSettings()
+	if root == nil {
+		root = newMCTSNode(rootState, nil, nil)
+		applyPriorKnowledge(root, prior, settings.priorCap)
+	}
+	best, treeSize, err := e.search(ctx, root, rootPlayer, settings, e.newWorkerRNG())
+	if err != nil {
+		return Move{}, err
+	}
+	e.mu.Lock()
+	e.lastTreeSize = treeSize
+	e.expectedReply = nil
+	if reply := best.bestChildByVisits(); reply != nil {
+		e.expectedReply = reply.move
+	}
+	logger := e.logger
+	e.mu.Unlock()
+	e.updateKnowledgeFromRoot(root, stateKey)
+	e.keepReusableRoot(best, rootPlayer)
+	// Games sharing the engine do not queue up behind a save in progress; it leaves the
+	// knowledge dirty, so a later save writes what this move learned.
+	if e.saveMu.TryLock() {
+		err := e.saveLocked()
+		e.saveMu.Unlock()
+		if err != nil {
+			logger.Warn("mcts: failed to persist knowledge", "path", e.storagePath, "err", err)
+		}
+	}
+	return *best.move, nil
+}
+
+// ExpectedReply returns the opponent's most visited reply to the move chosen by the last
+// search, if that search expanded one.
+func (e *MCTSEngine) ExpectedReply() (Move, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.expectedReply == nil {
+		return Move{}, false
+	}
+	return *e.expectedReply, true
+}
+
+// PonderSearch searches state on the opponent's time as a search of its own: it reads the
+// stored knowledge as priors but neither learns, saves nor keeps its tree, so an abandoned
+// search leaves the engine as it was. It gives up with ctx's error once ctx is done.
+func (e *MCTSEngine) PonderSearch(ctx context.Context, state GameState) (Move, error) {
 	rootState := CloneState(state)
+	if !HasLegalMove(rootState, rootState.Turn) {
+		return Move{}, errors.New("no legal moves to play")
+	}
+	_, prior := e.snapshotKnowledge(rootState)
+	settings := e.searchSettings()
 	root := newMCTSNode(rootState, nil, nil)
-	stateKey, prior := e.snapshotKnowledge(rootState)
-	applyPriorKnowledge(root, prior)
-	rootPlayer := state.Turn
-	rng := e.newWorkerRNG()
+	applyPriorKnowledge(root, prior, settings.priorCap)
+	best, _, err := e.search(ctx, root, state.Turn, settings, e.newWorkerRNG())
+	if err != nil {
+		return Move{}, err
+	}
+	return *best.move, nil
+}
+
+// mctsSettings is the configuration one search runs with, read once under the engine lock.
+type mctsSettings struct {
+	rolloutDepth int
+	policy       RolloutPolicy
+	evaluate     evaluationFunc
+	maxNodes     int
+	priorCap     int
+	selection    SelectionCriterion
+	draw         float64
+}
+
+func (e *MCTSEngine) searchSettings() mctsSettings {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	settings := mctsSettings{
+		rolloutDepth: e.rolloutDepth,
+		policy:       e.rolloutPolicy,
+		evaluate:     e.rolloutEval,
+		maxNodes:     e.maxNodes,
+		priorCap:     e.priorCap,
+		selection:    e.selection,
+		draw:         drawReward(e.contempt),
+	}
+	if settings.evaluate == nil {
+		settings.evaluate = EvalConfig{}.function()
+	}
+	return settings
+}
+
+// mctsCancelCheckInterval is how many iterations run between ctx checks.
+const mctsCancelCheckInterval = 16
+
+// search runs the iterations from root and returns the child to play and the tree size.
+func (e *MCTSEngine) search(ctx context.Context, root *mctsNode, rootPlayer Player, settings mctsSettings, rng *rand.Rand) (*mctsNode, int, error) {
+	treeSize := root.size()
 	for i := 0; i < e.iterations; i++ {
+		if i%mctsCancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				e.simulations.Add(int64(i))
+				return nil, treeSize, err
+			}
+		}
+		// The root always gets a child so a move can be chosen even under a tiny cap.
+		full := settings.maxNodes > 0 && treeSize >= settings.maxNodes && len(root.children) > 0
 		node := root
-		for len(node.untried) == 0 && len(node.children) > 0 {
+		for len(node.children) > 0 && (full || len(node.untriedMoves()) == 0) {
 			node = node.selectChild(e.exploration)
 		}
-		if len(node.untried) > 0 {
+		if !full && len(node.untriedMoves()) > 0 {
 			node = node.expand(rng)
+			treeSize++
 		}
-		winner, decided := e.rollout(node.state, rootPlayer, rng)
-		node.backpropagate(winner, rootPlayer, decided)
+		winner, decided := e.rollout(node.state, rootPlayer, settings.rolloutDepth, settings.policy, settings.evaluate, rng)
+		node.backpropagate(winner, rootPlayer, decided, settings.draw)
 	}
-	best := root.bestChildByVisits()
+	e.simulations.Add(int64(e.iterations))
+	best := root.bestChild(settings.selection)
 	if best == nil || best.move == nil {
-		return Move{}, errors.New("failed to choose move")
+		return nil, treeSize, errors.New("failed to choose move")
 	}
-	e.updateKnowledgeFromRoot(root, stateKey)
-	if err := e.SaveIfNeeded(); err != nil {
-		log.Printf("mcts: failed to persist knowledge: %v", err)
+	return best, treeSize, nil
+}
+
+// takeReusableRoot returns the node of the previous tree matching state, i.e. the position
+// after our last move and the opponent's reply. It falls back to nil on any mismatch.
+func (e *MCTSEngine) takeReusableRoot(state GameState, player Player) *mctsNode {
+	e.mu.Lock()
+	previous, owner := e.reusedRoot, e.reusedOwner
+	e.reusedRoot = nil
+	e.mu.Unlock()
+	if previous == nil || owner != player {
+		return nil
 	}
-	return *best.move, nil
+	key := encodeStateKey(state)
+	for _, child := range previous.children {
+		if encodeStateKey(child.state) == key {
+			child.parent = nil
+			return child
+		}
+	}
+	return nil
+}
+
+func (e *MCTSEngine) keepReusableRoot(best *mctsNode, player Player) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.reuseTree {
+		return
+	}
+	// Drop the link to the old root so the rest of the previous tree can be collected.
+	best.parent = nil
+	e.reusedRoot = best
+	e.reusedOwner = player
 }
 
 type mctsNode struct {
@@ -109,23 +545,38 @@ type mctsNode struct {
 	move     *Move
 	parent   *mctsNode
 	children []*mctsNode
-	untried  []Move
-	visits   int
-	wins     float64
+	// untried is generated on first use so leaf nodes do not hold move lists.
+	untried   []Move
+	generated bool
+	visits    int
+	wins      float64
 }
 
 func newMCTSNode(state GameState, move *Move, parent *mctsNode) *mctsNode {
-	available := GenerateLegalMoves(state, state.Turn)
-	untried := make([]Move, len(available))
-	copy(untried, available)
 	return &mctsNode{
-		state:   state,
-		move:    move,
-		parent:  parent,
-		untried: untried,
+		state:  state,
+		move:   move,
+		parent: parent,
 	}
 }
 
+func (n *mctsNode) untriedMoves() []Move {
+	if !n.generated {
+		n.untried = GenerateLegalMoves(n.state, n.state.Turn)
+		n.generated = true
+	}
+	return n.untried
+}
+
+// size counts n and its descendants.
+func (n *mctsNode) size() int {
+	total := 1
+	for _, child := range n.children {
+		total += child.size()
+	}
+	return total
+}
+
 func (n *mctsNode) selectChild(exploration float64) *mctsNode {
 	parentVisits := math.Max(1, float64(n.visits))
 	bestScore := math.Inf(-1)
@@ -146,7 +597,7 @@ func (n *mctsNode) selectChild(exploration float64) *mctsNode {
 }
 
 func (n *mctsNode) expand(rng *rand.Rand) *mctsNode {
-	if len(n.untried) == 0 {
+	if len(n.untriedMoves()) == 0 {
 		return n
 	}
 	idx := rng.Intn(len(n.untried))
@@ -181,8 +632,47 @@ func (n *mctsNode) bestChildByVisits() *mctsNode {
 	return best
 }
 
-func (n *mctsNode) backpropagate(winner Player, root Player, decided bool) {
-	reward := 0.5
+// bestChild returns the child to play under criterion. Win rates are those of the root
+// player, which is the player choosing among the root's children.
+func (n *mctsNode) bestChild(criterion SelectionCriterion) *mctsNode {
+	mostVisited := n.bestChildByVisits()
+	if criterion == SelectMostVisits || mostVisited == nil {
+		return mostVisited
+	}
+	minVisits := max(minSelectionVisits, int(float64(mostVisited.visits)*minSelectionShare))
+	var trusted []*mctsNode
+	bestRate := -1.0
+	for _, child := range n.children {
+		if child.visits < minVisits {
+			continue
+		}
+		trusted = append(trusted, child)
+		bestRate = max(bestRate, child.wins/float64(child.visits))
+	}
+	var best *mctsNode
+	for _, child := range trusted {
+		rate := child.wins / float64(child.visits)
+		switch criterion {
+		case SelectBestWinRate:
+			if rate == bestRate && (best == nil || child.visits > best.visits) {
+				best = child
+			}
+		case SelectRobust:
+			if rate >= bestRate-robustWinRateMargin && (best == nil || child.visits > best.visits) {
+				best = child
+			}
+		}
+	}
+	if best == nil {
+		return mostVisited
+	}
+	return best
+}
+
+// backpropagate adds one playout to n and its ancestors; draw is the reward of an
+// undecided playout.
+func (n *mctsNode) backpropagate(winner Player, root Player, decided bool, draw float64) {
+	reward := draw
 	if decided {
 		if winner == root {
 			reward = 1
@@ -196,21 +686,21 @@ func (n *mctsNode) backpropagate(winner Player, root Player, decided bool) {
 	}
 }
 
-func (e *MCTSEngine) rollout(state GameState, root Player, rng *rand.Rand) (Player, bool) {
+func (e *MCTSEngine) rollout(state GameState, root Player, maxDepth int, policy RolloutPolicy, evaluate evaluationFunc, rng *rand.Rand) (Player, bool) {
 	sim := CloneState(state)
-	for depth := 0; depth < mctsRolloutDepth; depth++ {
+	for depth := 0; depth < maxDepth; depth++ {
+		if result, over := kingCaptureResult(sim); over {
+			return result.Winner, true
+		}
 		moves := GenerateLegalMoves(sim, sim.Turn)
 		if len(moves) == 0 {
-			if InCheck(sim, sim.Turn) {
-				return sim.Turn.Opponent(), true
-			}
-			return root, false
+			return noMovesResult(sim).Winner, true
 		}
-		mv := moves[rng.Intn(len(moves))]
+		mv := pickRolloutMove(sim, moves, policy, rng)
 		ApplyMove(&sim, mv)
 		sim.Turn = sim.Turn.Opponent()
 	}
-	score := materialBalance(sim, root)
+	score := staticScore(sim, root, evaluate)
 	switch {
 	case score > 0:
 		return root, true
@@ -221,6 +711,21 @@ func (e *MCTSEngine) rollout(state GameState, root Player, rng *rand.Rand) (Play
 	}
 }
 
+func pickRolloutMove(state GameState, moves []Move, policy RolloutPolicy, rng *rand.Rand) Move {
+	if policy == RolloutCaptures {
+		var captures []Move
+		for _, mv := range moves {
+			if mv.From != nil && state.Board[mv.To.Y][mv.To.X].Present {
+				captures = append(captures, mv)
+			}
+		}
+		if len(captures) > 0 {
+			return captures[rng.Intn(len(captures))]
+		}
+	}
+	return moves[rng.Intn(len(moves))]
+}
+
 func (e *MCTSEngine) newWorkerRNG() *rand.Rand {
 	e.mu.Lock()
 	seed := e.rng.Int63()
@@ -233,25 +738,66 @@ func (e *MCTSEngine) snapshotKnowledge(state GameState) (string, map[string]move
 		return "", nil
 	}
 	key := encodeStateKey(state)
-	e.mu.Lock()
-	entries := e.knowledge[key]
-	var clone map[string]moveStats
-	if len(entries) > 0 {
-		clone = make(map[string]moveStats, len(entries))
-		for mv, stats := range entries {
-			clone[mv] = stats
+	var entries map[string]moveStats
+	e.knowledge.view(key, func(stored map[string]moveStats, _ bool) {
+		entries = stored
+	})
+	return key, entries
+}
+
+// KnowledgePosition summarises what the engine has stored for one position. Wins are
+// counted from the point of view of the side to move in State.
+type KnowledgePosition struct {
+	State      GameState
+	Visits     int
+	BestMove   Move
+	BestVisits int
+	BestWins   float64
+}
+
+// TopPositions returns up to n stored positions with the most visits, each with its most
+// visited move. Positions whose key or moves cannot be decoded are skipped.
+func (e *MCTSEngine) TopPositions(n int) []KnowledgePosition {
+	var positions []KnowledgePosition
+	for key, entries := range e.knowledge.snapshot() {
+		state, err := decodeStateKey(key)
+		if err != nil {
+			continue
+		}
+		pos := KnowledgePosition{State: state, BestVisits: -1}
+		var bestText string
+		for text, stats := range entries {
+			pos.Visits += stats.Visits
+			if stats.Visits > pos.BestVisits || (stats.Visits == pos.BestVisits && text < bestText) {
+				bestText, pos.BestVisits, pos.BestWins = text, stats.Visits, stats.Wins
+			}
+		}
+		mv, err := ParseMove(bestText)
+		if err != nil {
+			continue
 		}
+		pos.BestMove = mv
+		positions = append(positions, pos)
 	}
-	e.mu.Unlock()
-	return key, clone
+
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].Visits != positions[j].Visits {
+			return positions[i].Visits > positions[j].Visits
+		}
+		return encodeStateKey(positions[i].State) < encodeStateKey(positions[j].State)
+	})
+	if n > 0 && len(positions) > n {
+		positions = positions[:n]
+	}
+	return positions
 }
 
-func applyPriorKnowledge(root *mctsNode, entries map[string]moveStats) {
+func applyPriorKnowledge(root *mctsNode, entries map[string]moveStats, priorCap int) {
 	if len(entries) == 0 {
 		return
 	}
 	var remaining []Move
-	for _, mv := range root.untried {
+	for _, mv := range root.untriedMoves() {
 		stats, ok := entries[FormatMove(mv)]
 		if !ok {
 			remaining = append(remaining, mv)
@@ -264,6 +810,10 @@ func applyPriorKnowledge(root *mctsNode, entries map[string]moveStats) {
 		child := newMCTSNode(childState, &mvCopy, root)
 		child.visits = stats.Visits
 		child.wins = stats.Wins
+		if priorCap > 0 && child.visits > priorCap {
+			child.wins = child.wins * float64(priorCap) / float64(child.visits)
+			child.visits = priorCap
+		}
 		root.children = append(root.children, child)
 	}
 	root.untried = remaining
@@ -286,16 +836,18 @@ func (e *MCTSEngine) updateKnowledgeFromRoot(root *mctsNode, key string) {
 			Wins:   child.wins,
 		}
 	}
-	e.mu.Lock()
-	e.knowledge[key] = entries
-	e.dirty = true
-	e.mu.Unlock()
+	e.knowledge.set(key, entries)
 }
 
 func (e *MCTSEngine) loadKnowledge() error {
 	if e.storagePath == "" {
 		return nil
 	}
+	modTime, err := storageModTime(e.storagePath)
+	if err != nil {
+		return err
+	}
+	e.storageModTime = modTime
 	data, err := os.ReadFile(e.storagePath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -306,6 +858,9 @@ func (e *MCTSEngine) loadKnowledge() error {
 	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
 		return e.loadCompressedKnowledge(data)
 	}
+	if bytes.HasPrefix(data, []byte(knowledgeBinaryMagic)) {
+		return e.loadFromReader(bytes.NewReader(data))
+	}
 	trimmed := bytes.TrimSpace(data)
 	if len(trimmed) == 0 {
 		return nil
@@ -330,11 +885,11 @@ func (e *MCTSEngine) loadPlainKnowledge(data []byte) error {
 }
 
 func (e *MCTSEngine) loadFromReader(r io.Reader) error {
-	entries, err := decodeKnowledge(r)
+	entries, err := decodeKnowledgeAuto(r)
 	if err != nil {
 		return err
 	}
-	e.knowledge = entries
+	e.knowledge.addMissing(entries)
 	return nil
 }
 
@@ -343,34 +898,65 @@ func (e *MCTSEngine) loadLegacyJSON(data []byte) error {
 	if err := json.Unmarshal(data, &payload); err != nil {
 		return err
 	}
-	if payload.States != nil {
-		e.knowledge = payload.States
-	} else {
-		e.knowledge = make(map[string]map[string]moveStats)
-	}
+	e.knowledge.addMissing(payload.States)
 	return nil
 }
 
+// saveLocked writes the knowledge to storagePath if it changed. The caller holds e.saveMu;
+// e.mu is only taken briefly, so searches continue while the file is written.
 func (e *MCTSEngine) saveLocked() error {
-	if e.storagePath == "" || !e.dirty {
+	e.mu.Lock()
+	pending, expected := e.warmupPending, e.storageModTime
+	level, format := e.compressionLevel, e.knowledgeFormat
+	e.mu.Unlock()
+	if e.storagePath == "" || pending || !e.knowledge.dirty.Swap(false) {
 		return nil
 	}
+	saved := false
+	defer func() {
+		if !saved {
+			e.knowledge.dirty.Store(true)
+		}
+	}()
+	if err := checkStorageUnchanged(e.storagePath, expected); err != nil {
+		return fmt.Errorf("mcts: refusing to overwrite %s: %w", e.storagePath, err)
+	}
 	if err := os.MkdirAll(filepath.Dir(e.storagePath), 0o755); err != nil {
 		return err
 	}
 	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	if err := encodeKnowledge(gz, e.knowledge); err != nil {
+	gz, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return err
+	}
+	encode := encodeKnowledge
+	if format == KnowledgeBinary {
+		encode = encodeKnowledgeBinary
+	}
+	if err := encode(gz, e.knowledge.snapshot()); err != nil {
 		gz.Close()
 		return err
 	}
 	if err := gz.Close(); err != nil {
 		return err
 	}
-	if err := os.WriteFile(e.storagePath, buf.Bytes(), 0o644); err != nil {
+	// Write beside the file and rename it into place, so a crash mid-write never leaves a
+	// truncated knowledge file behind.
+	tmp := e.storagePath + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, e.storagePath); err != nil {
+		return err
+	}
+	modTime, err := storageModTime(e.storagePath)
+	if err != nil {
 		return err
 	}
-	e.dirty = false
+	e.mu.Lock()
+	e.storageModTime = modTime
+	e.mu.Unlock()
+	saved = true
 	return nil
 }
 
@@ -436,6 +1022,67 @@ func encodeKnowledge(w io.Writer, knowledge map[string]map[string]moveStats) err
 	return bw.Flush()
 }
 
+// decodeStateKey is the inverse of encodeStateKey.
+func decodeStateKey(key string) (GameState, error) {
+	if key == "" || key[0] < '0' || key[0] > '1' {
+		return GameState{}, fmt.Errorf("invalid state key %q", key)
+	}
+	state := GameState{
+		Hands: [2]map[PieceType]int{Bottom: make(map[PieceType]int), Top: make(map[PieceType]int)},
+		Turn:  Player(key[0] - '0'),
+	}
+	rest := key[1:]
+	for y := 0; y < BoardRows; y++ {
+		for x := 0; x < BoardCols; x++ {
+			if rest == "" {
+				return GameState{}, fmt.Errorf("state key %q is too short", key)
+			}
+			if rest[0] == '.' {
+				rest = rest[1:]
+				continue
+			}
+			if len(rest) < 3 {
+				return GameState{}, fmt.Errorf("state key %q is too short", key)
+			}
+			state.Board[y][x] = Piece{
+				Owner:    Player(rest[0] - '0'),
+				Kind:     PieceType(rest[1] - '0'),
+				Promoted: rest[2] == '1',
+				Present:  true,
+			}
+			rest = rest[3:]
+		}
+	}
+	for _, player := range []Player{Bottom, Top} {
+		hand, ok := strings.CutPrefix(rest, "|")
+		if !ok {
+			return GameState{}, fmt.Errorf("state key %q has no hand for player %d", key, player)
+		}
+		for _, piece := range []PieceType{King, Gold, Silver, Pawn} {
+			countText, tail, ok := strings.Cut(hand, ",")
+			if !ok {
+				return GameState{}, fmt.Errorf("state key %q has a short hand", key)
+			}
+			count, err := strconv.Atoi(countText)
+			if err != nil {
+				return GameState{}, fmt.Errorf("invalid hand count %q", countText)
+			}
+			if count > 0 {
+				state.Hands[player][piece] = count
+			}
+			hand = tail
+		}
+		rest = hand
+	}
+	if rest != "" {
+		return GameState{}, fmt.Errorf("state key %q has trailing data", key)
+	}
+	if err := VerifyState(state); err != nil {
+		return GameState{}, fmt.Errorf("state key %q: %w", key, err)
+	}
+	return state, nil
+}
+
 func decodeKnowledge(r io.Reader) (map[string]map[string]moveStats, error) {
 	scanner := bufio.NewScanner(r)
 	entries := make(map[string]map[string]moveStats)
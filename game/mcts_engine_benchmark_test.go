@@ -0,0 +1,29 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+)
+
+func BenchmarkMCTSRolloutDepth(b *testing.B) {
+	const iterations = 200
+	for _, depth := range []int{defaultMCTSRolloutDepth, 20, 8} {
+		b.Run(fmt.Sprintf("depth%d", depth), func(b *testing.B) {
+			engine := NewMCTSEngine(iterations, 1)
+			if err := engine.SetRolloutDepth(depth); err != nil {
+				b.Fatalf("SetRolloutDepth failed: %v", err)
+			}
+			state := NewGame()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.NextMove(state); err != nil {
+					b.Fatalf("NextMove failed: %v", err)
+				}
+			}
+			b.StopTimer()
+			if seconds := b.Elapsed().Seconds(); seconds > 0 {
+				b.ReportMetric(float64(b.N*iterations)/seconds, "iterations/s")
+			}
+		})
+	}
+}
@@ -1,17 +1,38 @@
 package game
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
+// newLoadedMCTSEngine is NewPersistentMCTSEngine failing the test when the stored knowledge
+// cannot be loaded.
+func newLoadedMCTSEngine(t testing.TB, iterations int, seed int64, storagePath string) *MCTSEngine {
+	t.Helper()
+	engine, err := NewPersistentMCTSEngine(iterations, seed, storagePath)
+	if err != nil {
+		t.Fatalf("NewPersistentMCTSEngine failed: %v", err)
+	}
+	return engine
+}
+
 func TestMCTSEnginePersistsKnowledge(t *testing.T) {
 	t.Parallel()
 
 	tempDir := t.TempDir()
 	storage := filepath.Join(tempDir, "mcts.json")
-	engine := NewPersistentMCTSEngine(32, 1, storage)
+	engine := newLoadedMCTSEngine(t, 32, 1, storage)
 	state := NewGame()
 
 	if _, err := engine.NextMove(state); err != nil {
@@ -21,14 +42,243 @@ func TestMCTSEnginePersistsKnowledge(t *testing.T) {
 		t.Fatalf("SaveIfNeeded failed: %v", err)
 	}
 
-	reloaded := NewPersistentMCTSEngine(32, 1, storage)
+	reloaded := newLoadedMCTSEngine(t, 32, 1, storage)
 	key := encodeStateKey(state)
-	entries := reloaded.knowledge[key]
+	entries, _ := reloaded.knowledge.get(key)
 	if len(entries) == 0 {
 		t.Fatalf("expected knowledge for key %q to be restored", key)
 	}
 }
 
+func TestMCTSEngineSaveFailureKeepsPreviousFile(t *testing.T) {
+	t.Parallel()
+
+	storage := filepath.Join(t.TempDir(), "mcts.json")
+	engine := newLoadedMCTSEngine(t, 32, 1, storage)
+	engine.knowledge.set("first", map[string]moveStats{"a1a2": {Visits: 1, Wins: 1}})
+	engine.knowledge.dirty.Store(true)
+	if err := engine.SaveIfNeeded(); err != nil {
+		t.Fatalf("SaveIfNeeded failed: %v", err)
+	}
+	if _, err := os.Stat(storage + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+
+	// A directory in the way of the temporary file makes the next save fail before the
+	// rename, which must leave the saved knowledge intact.
+	if err := os.Mkdir(storage+".tmp", 0o755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	engine.knowledge.set("second", map[string]moveStats{"a1a2": {Visits: 1, Wins: 0}})
+	engine.knowledge.dirty.Store(true)
+	if err := engine.SaveIfNeeded(); err == nil {
+		t.Fatalf("SaveIfNeeded succeeded with its temporary file blocked")
+	}
+	reloaded := newLoadedMCTSEngine(t, 32, 1, storage)
+	if _, ok := reloaded.knowledge.get("first"); !ok {
+		t.Fatalf("the previously saved knowledge was lost")
+	}
+	if _, ok := reloaded.knowledge.get("second"); ok {
+		t.Fatalf("the failed save reached the knowledge file")
+	}
+}
+
+func TestMCTSEngineCompressionLevel(t *testing.T) {
+	t.Parallel()
+
+	save := func(level int) (string, int64) {
+		storage := filepath.Join(t.TempDir(), "mcts.json")
+		engine := newLoadedMCTSEngine(t, 32, 1, storage)
+		if err := engine.SetCompressionLevel(level); err != nil {
+			t.Fatalf("SetCompressionLevel(%d) failed: %v", level, err)
+		}
+		rng := rand.New(rand.NewSource(3))
+		for i := 0; i < 2000; i++ {
+			key := fmt.Sprintf("state-%d", rng.Intn(1_000_000))
+			engine.knowledge.set(key, map[string]moveStats{"a1a2": {Visits: rng.Intn(500), Wins: rng.Float64() * 100}})
+		}
+		engine.knowledge.dirty.Store(true)
+		if err := engine.SaveIfNeeded(); err != nil {
+			t.Fatalf("SaveIfNeeded failed: %v", err)
+		}
+		info, err := os.Stat(storage)
+		if err != nil {
+			t.Fatalf("stat failed: %v", err)
+		}
+		return storage, info.Size()
+	}
+
+	fastPath, fastSize := save(gzip.BestSpeed)
+	_, bestSize := save(gzip.BestCompression)
+	if fastSize < bestSize {
+		t.Fatalf("BestSpeed file is %d bytes, smaller than BestCompression's %d", fastSize, bestSize)
+	}
+	reloaded := newLoadedMCTSEngine(t, 32, 1, fastPath)
+	if reloaded.knowledge.len() == 0 {
+		t.Fatalf("knowledge saved with BestSpeed did not load")
+	}
+	if err := reloaded.SetCompressionLevel(gzip.BestCompression + 1); err == nil {
+		t.Fatalf("expected an out-of-range level to be rejected")
+	}
+}
+
+func TestMCTSEngineMaxStatesEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	engine := newLoadedMCTSEngine(t, 32, 1, filepath.Join(t.TempDir(), "mcts.json"))
+	engine.SetMaxStates(3)
+	var states []GameState
+	for _, mv := range GenerateLegalMoves(NewGame(), Bottom)[:5] {
+		_, next := TryApplyMove(NewGame(), mv)
+		states = append(states, next)
+	}
+	record := func(state GameState) {
+		reply := GenerateLegalMoves(state, state.Turn)[0]
+		root := &mctsNode{state: state, children: []*mctsNode{{move: &reply, visits: 1}}}
+		engine.updateKnowledgeFromRoot(root, "")
+	}
+
+	for _, state := range states[:3] {
+		record(state)
+	}
+	// Reading the first position keeps it while the next two writes evict the others.
+	if _, entries := engine.snapshotKnowledge(states[0]); entries == nil {
+		t.Fatalf("expected knowledge for the first position")
+	}
+	for _, state := range states[3:] {
+		record(state)
+		if engine.knowledge.len() > 3 {
+			t.Fatalf("knowledge grew to %d positions, want at most 3", engine.knowledge.len())
+		}
+	}
+	for i, want := range []bool{true, false, false, true, true} {
+		if _, ok := engine.knowledge.get(encodeStateKey(states[i])); ok != want {
+			t.Fatalf("position %d kept = %v, want %v", i, ok, want)
+		}
+	}
+}
+
+func TestMCTSEngineDeferredLoadsKnowledgeOnWarmup(t *testing.T) {
+	t.Parallel()
+
+	// Dropping the gold on a5 or b5 mates; the stored knowledge favours a quiet king move.
+	state := newEmptyState(Bottom)
+	state.Board[0][4] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][0] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[3][1] = Piece{Kind: Silver, Owner: Bottom, Present: true}
+	state.Hands[Bottom][Gold] = 1
+
+	storage := filepath.Join(t.TempDir(), "mcts.json")
+	writer := newLoadedMCTSEngine(t, 32, 1, storage)
+	writer.knowledge.set(encodeStateKey(state), map[string]moveStats{"e1e2": {Visits: 1_000_000, Wins: 500_000}})
+	writer.knowledge.dirty.Store(true)
+	if err := writer.SaveIfNeeded(); err != nil {
+		t.Fatalf("SaveIfNeeded failed: %v", err)
+	}
+
+	engine := NewDeferredMCTSEngine(300, 5, storage)
+	if engine.knowledge.len() != 0 {
+		t.Fatalf("deferred engine loaded %d positions before warmup", engine.knowledge.len())
+	}
+	// The engine plays, and learns, before warmup without overwriting the stored file.
+	if _, err := engine.NextMove(NewGame()); err != nil {
+		t.Fatalf("NextMove before warmup failed: %v", err)
+	}
+	if err := engine.SaveIfNeeded(); err != nil {
+		t.Fatalf("SaveIfNeeded before warmup failed: %v", err)
+	}
+	if err := engine.Warmup(context.Background()); err != nil {
+		t.Fatalf("Warmup failed: %v", err)
+	}
+	if _, ok := engine.knowledge.get(encodeStateKey(NewGame())); !ok {
+		t.Fatalf("warmup dropped knowledge learned before it finished")
+	}
+	mv, err := engine.NextMove(state)
+	if err != nil {
+		t.Fatalf("NextMove after warmup failed: %v", err)
+	}
+	if FormatMove(mv) != "e1e2" {
+		t.Fatalf("after warmup chose %s, want the stored move", FormatMove(mv))
+	}
+}
+
+func TestPersistentEnginesReturnLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	storage := filepath.Join(t.TempDir(), "knowledge")
+	// A gzip header followed by garbage cannot be decoded.
+	if err := os.WriteFile(storage, []byte{0x1f, 0x8b, 'x', 'y', 'z'}, 0o644); err != nil {
+		t.Fatalf("failed to write corrupt knowledge: %v", err)
+	}
+
+	mcts, err := NewPersistentMCTSEngine(16, 1, storage)
+	if err == nil || mcts == nil {
+		t.Fatalf("NewPersistentMCTSEngine = %v, %v; want an engine and the load error", mcts, err)
+	}
+	if _, err := mcts.NextMove(NewGame()); err != nil {
+		t.Fatalf("MCTS NextMove after a failed load: %v", err)
+	}
+	td, err := NewPersistentTDUCBEngine(1, storage)
+	if err == nil || td == nil {
+		t.Fatalf("NewPersistentTDUCBEngine = %v, %v; want an engine and the load error", td, err)
+	}
+	td, err = NewTDUCBEngineWithParams(1, storage, TDUCBParams{})
+	if err == nil || td == nil {
+		t.Fatalf("NewTDUCBEngineWithParams = %v, %v; want an engine and the load error", td, err)
+	}
+}
+
+func TestMCTSEngineLogsSaveFailureAfterNextMove(t *testing.T) {
+	t.Parallel()
+
+	storage := filepath.Join(t.TempDir(), "mcts.json")
+	// A directory in the way of the temporary file makes every save fail.
+	if err := os.Mkdir(storage+".tmp", 0o755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	engine := newLoadedMCTSEngine(t, 16, 1, storage)
+	var logs bytes.Buffer
+	engine.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	if _, err := engine.NextMove(NewGame()); err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+	if out := logs.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, "failed to persist knowledge") {
+		t.Fatalf("expected a warn-level save failure, got %q", out)
+	}
+}
+
+func TestMCTSEngineFailedWarmupKeepsSaving(t *testing.T) {
+	t.Parallel()
+
+	storage := filepath.Join(t.TempDir(), "mcts.json")
+	// A gzip header followed by garbage cannot be decoded.
+	if err := os.WriteFile(storage, []byte{0x1f, 0x8b, 'x', 'y', 'z'}, 0o644); err != nil {
+		t.Fatalf("failed to write corrupt knowledge: %v", err)
+	}
+
+	cancelled := NewDeferredMCTSEngine(16, 1, storage)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cancelled.Warmup(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Warmup with a cancelled context = %v, want context.Canceled", err)
+	}
+
+	engine := NewDeferredMCTSEngine(16, 1, storage)
+	if err := engine.Warmup(context.Background()); err == nil {
+		t.Fatalf("Warmup of a corrupt file should fail")
+	}
+	if _, err := engine.NextMove(NewGame()); err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+	if err := engine.SaveIfNeeded(); err != nil {
+		t.Fatalf("SaveIfNeeded failed: %v", err)
+	}
+	reloaded := newLoadedMCTSEngine(t, 16, 1, storage)
+	if _, ok := reloaded.knowledge.get(encodeStateKey(NewGame())); !ok {
+		t.Fatalf("knowledge learned after a failed warmup was never saved")
+	}
+}
+
 func TestMCTSEngineAllowsParallelNextMove(t *testing.T) {
 	t.Parallel()
 
@@ -53,3 +303,331 @@ func TestMCTSEngineAllowsParallelNextMove(t *testing.T) {
 		}
 	}
 }
+
+func TestMCTSEngineRefusesToOverwriteExternalChanges(t *testing.T) {
+	t.Parallel()
+
+	storage := filepath.Join(t.TempDir(), "mcts.json")
+	engine := newLoadedMCTSEngine(t, 16, 1, storage)
+	if _, err := engine.NextMove(NewGame()); err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+
+	external := []byte("external\n")
+	if err := os.WriteFile(storage, external, 0o644); err != nil {
+		t.Fatalf("failed to simulate external write: %v", err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(storage, future, future); err != nil {
+		t.Fatalf("failed to update mtime: %v", err)
+	}
+
+	engine.mu.Lock()
+	engine.knowledge.dirty.Store(true)
+	engine.mu.Unlock()
+	if err := engine.SaveIfNeeded(); !errors.Is(err, ErrStorageModified) {
+		t.Fatalf("SaveIfNeeded error = %v, want ErrStorageModified", err)
+	}
+	data, err := os.ReadFile(storage)
+	if err != nil {
+		t.Fatalf("failed to read storage: %v", err)
+	}
+	if string(data) != string(external) {
+		t.Fatalf("external changes were overwritten: %q", data)
+	}
+}
+
+func TestMCTSEngineTinyRolloutDepthReturnsLegalMove(t *testing.T) {
+	t.Parallel()
+
+	engine := NewMCTSEngine(32, 7)
+	if err := engine.SetRolloutDepth(0); err == nil {
+		t.Fatalf("expected non-positive rollout depth to be rejected")
+	}
+	if err := engine.SetRolloutDepth(1); err != nil {
+		t.Fatalf("SetRolloutDepth failed: %v", err)
+	}
+	state := NewGame()
+	mv, err := engine.NextMove(state)
+	if err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+	if legal, _ := TryApplyMove(state, mv); !legal {
+		t.Fatalf("engine returned illegal move %s", FormatMove(mv))
+	}
+}
+
+func TestMCTSEngineRespectsNodeCap(t *testing.T) {
+	t.Parallel()
+
+	const maxNodes = 200
+	engine := NewMCTSEngine(5000, 3)
+	if err := engine.SetRolloutDepth(4); err != nil {
+		t.Fatalf("SetRolloutDepth failed: %v", err)
+	}
+	engine.SetMaxNodes(maxNodes)
+	state := NewGame()
+	mv, err := engine.NextMove(state)
+	if err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+	if legal, _ := TryApplyMove(state, mv); !legal {
+		t.Fatalf("engine returned illegal move %s", FormatMove(mv))
+	}
+	if engine.lastTreeSize > maxNodes {
+		t.Fatalf("tree grew to %d nodes, cap is %d", engine.lastTreeSize, maxNodes)
+	}
+}
+
+func TestMCTSRolloutScoresCheckBonusAtEqualMaterial(t *testing.T) {
+	t.Parallel()
+
+	// Material is equal and Top's king is in check from Bottom's gold. At depth 0 nothing
+	// is played out, so only the evaluation's check bonus decides the result.
+	state := NewGame()
+	state.Board = [BoardRows][BoardCols]Piece{}
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][2] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[4][2] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+	state.Board[3][4] = Piece{Kind: Gold, Owner: Top, Present: true}
+	state.Turn = Top
+	if materialBalance(state, Bottom, 1) != 0 {
+		t.Fatalf("test position should have equal material")
+	}
+
+	engine := NewMCTSEngine(1, 1)
+	rng := rand.New(rand.NewSource(1))
+	winner, decided := engine.rollout(state, Bottom, 0, RolloutUniform, EvalConfig{}.function(), rng)
+	if !decided || winner != Bottom {
+		t.Fatalf("rollout = (%v, %v), want a decided win for Bottom", winner, decided)
+	}
+}
+
+func TestMCTSEngineReusesSubtreeAfterOpponentReply(t *testing.T) {
+	t.Parallel()
+
+	const iterations = 64
+	engine := NewMCTSEngine(iterations, 3)
+	engine.SetTreeReuse(true)
+
+	state := NewGame()
+	first, err := engine.NextMove(state)
+	if err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+	current := childState(state, first)
+
+	kept := engine.reusedRoot
+	if kept == nil {
+		t.Fatalf("expected the chosen subtree to be kept")
+	}
+	reply := kept.bestChildByVisits()
+	if reply == nil || reply.move == nil {
+		t.Fatalf("expected an explored opponent reply")
+	}
+	carried := reply.visits
+	current = childState(current, *reply.move)
+
+	if _, err := engine.NextMove(current); err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+	if reply.parent != nil {
+		t.Fatalf("expected the reused node to become the root")
+	}
+	if reply.visits != carried+iterations {
+		t.Fatalf("root visits = %d, want %d carried plus %d new", reply.visits, carried, iterations)
+	}
+}
+
+func TestMCTSSelectionCriterion(t *testing.T) {
+	t.Parallel()
+
+	root := newMCTSNode(NewGame(), nil, nil)
+	child := func(visits int, wins float64) *mctsNode {
+		node := newMCTSNode(NewGame(), &Move{}, root)
+		node.visits, node.wins = visits, wins
+		root.children = append(root.children, node)
+		return node
+	}
+	popular := child(100, 50)
+	winning := child(40, 30)
+	child(3, 3) // A perfect win rate from too few visits to trust.
+
+	if got := root.bestChild(SelectMostVisits); got != popular {
+		t.Fatalf("most-visits chose the child with %d visits, want the most visited", got.visits)
+	}
+	if got := root.bestChild(SelectBestWinRate); got != winning {
+		t.Fatalf("best-winrate chose the child with %d visits, want the high win rate child", got.visits)
+	}
+	if got := root.bestChild(SelectRobust); got != winning {
+		t.Fatalf("robust chose the child with %d visits, want the only child near the best win rate", got.visits)
+	}
+	if _, err := ParseSelectionCriterion("lucky"); err == nil {
+		t.Fatalf("ParseSelectionCriterion accepted an unknown name")
+	}
+}
+
+func TestMCTSEnginePriorCapLetsSearchOverrideStaleKnowledge(t *testing.T) {
+	t.Parallel()
+
+	// Dropping the gold on a5 or b5 mates; the stored knowledge favours a quiet king move.
+	state := newEmptyState(Bottom)
+	state.Board[0][4] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][0] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[3][1] = Piece{Kind: Silver, Owner: Bottom, Present: true}
+	state.Hands[Bottom][Gold] = 1
+
+	choose := func(priorCap int) Move {
+		engine := newLoadedMCTSEngine(t, 300, 5, filepath.Join(t.TempDir(), "mcts.json"))
+		engine.knowledge.set(encodeStateKey(state), map[string]moveStats{
+			"e1e2": {Visits: 1_000_000, Wins: 500_000},
+		})
+		engine.SetPriorCap(priorCap)
+		mv, err := engine.NextMove(state)
+		if err != nil {
+			t.Fatalf("NextMove failed: %v", err)
+		}
+		return mv
+	}
+
+	if mv := choose(0); FormatMove(mv) != "e1e2" {
+		t.Fatalf("uncapped prior chose %s, want the stored move", FormatMove(mv))
+	}
+	mv := choose(10)
+	_, next := TryApplyMove(state, mv)
+	next.Turn = Top
+	if mate, _ := CheckmateStatus(next); !mate {
+		t.Fatalf("capped prior chose %s, want a mating drop", FormatMove(mv))
+	}
+}
+
+func TestDecodeStateKeyRoundTrips(t *testing.T) {
+	state := NewGameWithTurn(Top)
+	state.Board[2][1] = Piece{}
+	state.Board[4][1] = Piece{Kind: Pawn, Owner: Bottom, Promoted: true, Present: true}
+	state.Hands[Top][Silver] = 2
+
+	decoded, err := decodeStateKey(encodeStateKey(state))
+	if err != nil {
+		t.Fatalf("decodeStateKey failed: %v", err)
+	}
+	if encodeStateKey(decoded) != encodeStateKey(state) {
+		t.Fatalf("round trip changed the position")
+	}
+	if _, err := decodeStateKey("0..."); err == nil {
+		t.Fatalf("expected an error for a truncated key")
+	}
+}
+
+func TestMCTSContemptPrefersPlayingOnOverDraws(t *testing.T) {
+	t.Parallel()
+
+	// Dropping the silver leads to positions the evaluation scores as dead even; keeping
+	// it in hand leads to positions that are won or lost depending on where the top king
+	// stands.
+	state := newEmptyState(Bottom)
+	state.Board[0][0] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][4] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Hands[Bottom][Silver] = 1
+	evaluate := func(node *searchNode, player Player, _ int) int {
+		if node.state.Hands[Bottom][Silver] == 0 {
+			return 0
+		}
+		score := -1
+		for y := 0; y < BoardRows; y++ {
+			for x := 0; x < BoardCols; x++ {
+				if p := node.state.Board[y][x]; p.Present && p.Kind == King && p.Owner == Top && (x+y)%2 == 1 {
+					score = 1
+				}
+			}
+		}
+		if player == Top {
+			return -score
+		}
+		return score
+	}
+
+	for seed := int64(1); seed <= 3; seed++ {
+		engine := NewMCTSEngine(400, seed)
+		engine.rolloutEval = evaluate
+		if err := engine.SetRolloutDepth(1); err != nil {
+			t.Fatalf("SetRolloutDepth failed: %v", err)
+		}
+		engine.SetContempt(500)
+		mv, err := engine.NextMove(state)
+		if err != nil {
+			t.Fatalf("NextMove failed: %v", err)
+		}
+		if mv.Drop != nil {
+			t.Fatalf("seed %d: contempt chose the drawing drop %s, want a king move", seed, FormatMove(mv))
+		}
+	}
+	if got := drawReward(0); got != 0.5 {
+		t.Fatalf("drawReward(0) = %v, want 0.5", got)
+	}
+}
+
+func TestMCTSEngineNextMoveContextStopsWhenCancelled(t *testing.T) {
+	t.Parallel()
+
+	engine := NewMCTSEngine(1_000_000_000, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := engine.NextMoveContext(ctx, NewGame()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("NextMoveContext error = %v, want context.Canceled", err)
+	}
+	if _, err := engine.PonderSearch(ctx, NewGame()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("PonderSearch error = %v, want context.Canceled", err)
+	}
+}
+
+func TestMCTSEnginePonderSearchLeavesEngineUntouched(t *testing.T) {
+	t.Parallel()
+
+	storage := filepath.Join(t.TempDir(), "mcts.json")
+	engine := newLoadedMCTSEngine(t, 64, 1, storage)
+	engine.SetTreeReuse(true)
+	if _, err := engine.PonderSearch(context.Background(), NewGame()); err != nil {
+		t.Fatalf("PonderSearch failed: %v", err)
+	}
+	if n := engine.knowledge.len(); n != 0 {
+		t.Fatalf("ponder search learned %d positions, want none", n)
+	}
+	if _, ok := engine.ExpectedReply(); ok {
+		t.Fatalf("ponder search set an expected reply")
+	}
+	if _, err := os.Stat(storage); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("ponder search wrote the storage file: %v", err)
+	}
+
+	if _, err := engine.NextMove(NewGame()); err != nil {
+		t.Fatalf("NextMove failed: %v", err)
+	}
+	if _, ok := engine.ExpectedReply(); !ok {
+		t.Fatalf("NextMove left no expected reply")
+	}
+}
+
+func TestMCTSEngineRolloutStopsWhenKingCanBeCaptured(t *testing.T) {
+	t.Parallel()
+
+	engine := NewMCTSEngine(1, 1)
+	settings := engine.searchSettings()
+	for seed := int64(1); seed <= 20; seed++ {
+		winner, decided := engine.rollout(newKingCapturableState(), Top, settings.rolloutDepth, settings.policy, settings.evaluate, rand.New(rand.NewSource(seed)))
+		if !decided || winner != Bottom {
+			t.Fatalf("seed %d: rollout = %v %v, want a decided win for Bottom", seed, winner, decided)
+		}
+	}
+}
+
+func TestMCTSEngineRolloutScoresNoMovesAsLoss(t *testing.T) {
+	t.Parallel()
+
+	engine := NewMCTSEngine(1, 1)
+	settings := engine.searchSettings()
+	winner, decided := engine.rollout(newNoMovesState(), Bottom, settings.rolloutDepth, settings.policy, settings.evaluate, rand.New(rand.NewSource(1)))
+	if !decided || winner != Top {
+		t.Fatalf("rollout = %v %v, want a decided win for Top", winner, decided)
+	}
+}
@@ -0,0 +1,66 @@
+package game
+
+// Phase classifies how far a game has progressed.
+type Phase int
+
+const (
+	PhaseOpening Phase = iota
+	PhaseMidgame
+	PhaseEndgame
+)
+
+const (
+	// openingBoardMaterial is the non-king material on the board (both sides) at or above
+	// which a position still counts as the opening; the start position has 540.
+	openingBoardMaterial = 480
+	// endgameBoardMaterial is the non-king material on the board at or below which a
+	// position counts as the endgame.
+	endgameBoardMaterial = 200
+	// endgameKingAdvance is how many ranks a king must have left its back rank for the
+	// position to count as the endgame; on this board that is the opponent's half.
+	endgameKingAdvance = BoardRows / 2
+)
+
+// String returns "opening", "midgame" or "endgame".
+func (p Phase) String() string {
+	switch p {
+	case PhaseOpening:
+		return "opening"
+	case PhaseEndgame:
+		return "endgame"
+	default:
+		return "midgame"
+	}
+}
+
+// GamePhase classifies state by the non-king material left on the board and by how far
+// the kings have advanced. Captured pieces in hand do not count, since they leave the board.
+func GamePhase(state GameState) Phase {
+	material := 0
+	advance := 0
+	for y := 0; y < BoardRows; y++ {
+		for x := 0; x < BoardCols; x++ {
+			p := state.Board[y][x]
+			if !p.Present {
+				continue
+			}
+			if p.Kind != King {
+				material += pieceValue(p)
+				continue
+			}
+			ranks := y
+			if p.Owner == Top {
+				ranks = BoardRows - 1 - y
+			}
+			advance = max(advance, ranks)
+		}
+	}
+	switch {
+	case material <= endgameBoardMaterial || advance >= endgameKingAdvance:
+		return PhaseEndgame
+	case material >= openingBoardMaterial && advance <= 1:
+		return PhaseOpening
+	default:
+		return PhaseMidgame
+	}
+}
@@ -0,0 +1,27 @@
+package game
+
+import "testing"
+
+func TestGamePhase(t *testing.T) {
+	if got := GamePhase(NewGame()); got != PhaseOpening {
+		t.Fatalf("GamePhase(NewGame()) = %v, want opening", got)
+	}
+
+	state := newEmptyState(Bottom)
+	state.Board[0][2] = Piece{Kind: King, Owner: Bottom, Present: true}
+	state.Board[5][2] = Piece{Kind: King, Owner: Top, Present: true}
+	state.Board[1][1] = Piece{Kind: Gold, Owner: Bottom, Present: true}
+	state.Board[4][3] = Piece{Kind: Pawn, Owner: Top, Present: true}
+	state.Hands[Bottom][Silver] = 2
+	if got := GamePhase(state); got != PhaseEndgame {
+		t.Fatalf("GamePhase(kings and few pieces) = %v, want endgame", got)
+	}
+
+	// Trading a silver each leaves the opening but not the pieces needed for the endgame.
+	midgame := NewGame()
+	midgame.Board[0][0] = Piece{}
+	midgame.Board[5][4] = Piece{}
+	if got := GamePhase(midgame); got != PhaseMidgame {
+		t.Fatalf("GamePhase(after a silver trade) = %v, want midgame", got)
+	}
+}
@@ -2,16 +2,19 @@ package game
 
 import (
 	"errors"
-	"math/rand"
+	"math/rand/v2"
 )
 
 // RandomEngine picks a legal move uniformly at random.
 type RandomEngine struct {
+	// pcg is kept so the generator position can be saved and restored.
+	pcg *rand.PCG
 	rng *rand.Rand
 }
 
 func NewRandomEngine(seed int64) *RandomEngine {
-	return &RandomEngine{rng: rand.New(rand.NewSource(seed))}
+	pcg := rand.NewPCG(uint64(seed), 0)
+	return &RandomEngine{pcg: pcg, rng: rand.New(pcg)}
 }
 
 func (e *RandomEngine) NextMove(state GameState) (Move, error) {
@@ -19,5 +22,15 @@ func (e *RandomEngine) NextMove(state GameState) (Move, error) {
 	if len(moves) == 0 {
 		return Move{}, errors.New("no legal moves to play")
 	}
-	return moves[e.rng.Intn(len(moves))], nil
+	return moves[e.rng.IntN(len(moves))], nil
+}
+
+// MarshalState captures the generator position, so UnmarshalState can make the engine
+// continue with exactly the same moves.
+func (e *RandomEngine) MarshalState() ([]byte, error) {
+	return e.pcg.MarshalBinary()
+}
+
+func (e *RandomEngine) UnmarshalState(data []byte) error {
+	return e.pcg.UnmarshalBinary(data)
 }
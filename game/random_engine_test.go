@@ -0,0 +1,37 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRandomEngineStateRestoresMoveSequence(t *testing.T) {
+	engine := NewRandomEngine(7)
+	playMoves := func() []string {
+		var moves []string
+		state := NewGame()
+		for i := 0; i < 8; i++ {
+			mv, err := engine.NextMove(state)
+			if err != nil {
+				t.Fatalf("NextMove failed: %v", err)
+			}
+			moves = append(moves, FormatMove(mv))
+			ApplyMove(&state, mv)
+			state.Turn = state.Turn.Opponent()
+		}
+		return moves
+	}
+
+	playMoves()
+	saved, err := engine.MarshalState()
+	if err != nil {
+		t.Fatalf("MarshalState failed: %v", err)
+	}
+	first := playMoves()
+	if err := engine.UnmarshalState(saved); err != nil {
+		t.Fatalf("UnmarshalState failed: %v", err)
+	}
+	if second := playMoves(); !reflect.DeepEqual(first, second) {
+		t.Fatalf("restored engine played %v, want %v", second, first)
+	}
+}
@@ -0,0 +1,148 @@
+package game
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Reasons reported by GameResult.
+const (
+	ReasonCheckmate  = "checkmate"
+	ReasonRepetition = "repetition"
+	// ReasonNoMoves means the side to move has no legal move without being in check. Like
+	// checkmate, it loses.
+	ReasonNoMoves = "no-moves"
+	// ReasonKingCaptured ends games played under RulesKingCapture once a king is taken or
+	// can be taken by the side to move.
+	ReasonKingCaptured = "king-captured"
+)
+
+// RepetitionLimit is how many times the same position with the same side to move must
+// occur for the game to end in a draw by repetition (sennichite).
+const RepetitionLimit = 4
+
+// GameResult describes whether and how a game ended. Winner is meaningful only when Over
+// is set and Draw is not.
+type GameResult struct {
+	Over   bool
+	Draw   bool
+	Winner Player
+	Reason string
+}
+
+// PositionKey identifies a position together with the side to move.
+func PositionKey(state GameState) string {
+	return encodeStateKey(state)
+}
+
+// PositionHash is a short hex digest of PositionKey, so it depends only on the position and
+// the side to move, never on the moves that led there.
+func PositionHash(state GameState) string {
+	h := fnv.New64a()
+	h.Write([]byte(PositionKey(state)))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// DetermineResult reports the outcome of state. positions lists the PositionKey of every
+// position reached in the game so far, including state itself, and may be nil when
+// repetition should not be considered.
+func DetermineResult(state GameState, positions []string) GameResult {
+	if result, over := kingCaptureResult(state); over {
+		return result
+	}
+	if !HasLegalMove(state, state.Turn) {
+		return noMovesResult(state)
+	}
+	if len(positions) < RepetitionLimit {
+		return GameResult{}
+	}
+	key := PositionKey(state)
+	count := 0
+	for _, seen := range positions {
+		if seen == key {
+			count++
+		}
+	}
+	if count >= RepetitionLimit {
+		return GameResult{Over: true, Draw: true, Reason: ReasonRepetition}
+	}
+	return GameResult{}
+}
+
+// kingCaptureResult ends a game played under RulesKingCapture as soon as a king is missing
+// or the side to move can capture the opponent's king. Searches check it before generating
+// moves so they stop where DetermineResult does; under other rules it never ends the game.
+func kingCaptureResult(state GameState) (GameResult, bool) {
+	if state.Rules != RulesKingCapture {
+		return GameResult{}, false
+	}
+	for _, player := range []Player{Bottom, Top} {
+		if _, found := findKing(state, player); !found {
+			return GameResult{Over: true, Winner: player.Opponent(), Reason: ReasonKingCaptured}, true
+		}
+	}
+	if InCheck(state, state.Turn.Opponent()) {
+		return GameResult{Over: true, Winner: state.Turn, Reason: ReasonKingCaptured}, true
+	}
+	return GameResult{}, false
+}
+
+// noMovesResult is the result of state when its side to move has no legal move, which loses
+// whether or not it is in check. Searches that reach the end of a game use it so they score
+// it the way DetermineResult ends it.
+func noMovesResult(state GameState) GameResult {
+	reason := ReasonNoMoves
+	if InCheck(state, state.Turn) {
+		reason = ReasonCheckmate
+	}
+	return GameResult{Over: true, Winner: state.Turn.Opponent(), Reason: reason}
+}
+
+// ReasonNoProgress is the draw NoProgress adjudicates.
+const ReasonNoProgress = "no-progress"
+
+// NoProgress reports whether the last window moves of history (the positions of a game,
+// oldest first) changed nothing that matters: no capture, drop or promotion changed the
+// material, and the pieces only shuffled between at most window/2 distinct positions. It
+// is a heuristic for adjudicating blocked self-play games as draws before their move
+// limit; window <= 0 never reports no progress.
+func NoProgress(history []GameState, window int) bool {
+	if window <= 0 || len(history) <= window {
+		return false
+	}
+	recent := history[len(history)-window-1:]
+	material := materialSignature(recent[0])
+	positions := make(map[string]struct{}, len(recent))
+	for _, state := range recent {
+		if materialSignature(state) != material {
+			return false
+		}
+		positions[PositionKey(state)] = struct{}{}
+	}
+	return len(positions) <= window/2
+}
+
+// materialSignature counts the pieces of each owner and kind, on the board by promotion
+// and in hand, so it is unchanged by any move that neither captures, drops nor promotes.
+func materialSignature(state GameState) [2][3][Pawn + 1]int {
+	var counts [2][3][Pawn + 1]int
+	for y := 0; y < BoardRows; y++ {
+		for x := 0; x < BoardCols; x++ {
+			p := state.Board[y][x]
+			if !p.Present {
+				continue
+			}
+			slot := 0
+			if p.Promoted {
+				slot = 1
+			}
+			counts[p.Owner][slot][p.Kind]++
+		}
+	}
+	for player := range state.Hands {
+		for kind, n := range state.Hands[player] {
+			counts[player][2][kind] += n
+		}
+	}
+	return counts
+}
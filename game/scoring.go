@@ -1,5 +1,7 @@
 package game
 
+import "math"
+
 var pieceScores = map[PieceType]int{
 	King:   1000,
 	Gold:   70,
@@ -9,7 +11,13 @@ var pieceScores = map[PieceType]int{
 
 var orderedPieceTypes = []PieceType{King, Gold, Silver, Pawn}
 
-func materialBalance(state GameState, player Player) int {
+// materialBalance is player's material advantage, with pieces in hand worth handMultiplier
+// times their board value.
+func materialBalance(state GameState, player Player, handMultiplier float64) int {
+	return boardMaterial(state, player) + handMaterial(state, player, handMultiplier)
+}
+
+func boardMaterial(state GameState, player Player) int {
 	score := 0
 	for y := 0; y < BoardRows; y++ {
 		for x := 0; x < BoardCols; x++ {
@@ -25,18 +33,90 @@ func materialBalance(state GameState, player Player) int {
 			}
 		}
 	}
+	return score
+}
+
+func handMaterial(state GameState, player Player, multiplier float64) int {
+	score := 0
 	for pieceType, count := range state.Hands[player] {
-		score += pieceScores[pieceType] * count
+		score += handValue(pieceType, count, multiplier)
 	}
 	for pieceType, count := range state.Hands[player.Opponent()] {
-		score -= pieceScores[pieceType] * count
+		score -= handValue(pieceType, count, multiplier)
 	}
 	return score
 }
 
+func handValue(pieceType PieceType, count int, multiplier float64) int {
+	return int(math.Round(float64(pieceScores[pieceType]*count) * multiplier))
+}
+
 func pieceValue(p Piece) int {
 	if (p.Kind == Silver || p.Kind == Pawn) && p.Promoted {
 		return pieceScores[Gold]
 	}
 	return pieceScores[p.Kind]
 }
+
+// SEE estimates the net material won by move when both sides keep recapturing on its
+// destination square with their least valuable attacker. Non-captures and drops score 0.
+// Pins and promotions during the exchange are ignored.
+func SEE(state GameState, move Move) int {
+	if move.From == nil {
+		return 0
+	}
+	target := state.Board[move.To.Y][move.To.X]
+	if !target.Present {
+		return 0
+	}
+	board := state.Board
+	mover := board[move.From.Y][move.From.X]
+	board[move.From.Y][move.From.X] = Piece{}
+	if move.Promote {
+		mover.Promoted = true
+	}
+	// gains[i] is the material balance for the side making capture i if the exchange stops there.
+	gains := []int{pieceValue(target)}
+	onSquare := pieceValue(mover)
+	side := mover.Owner.Opponent()
+	for {
+		from, ok := leastValuableAttacker(&board, side, move.To)
+		if !ok {
+			break
+		}
+		gains = append(gains, onSquare-gains[len(gains)-1])
+		onSquare = pieceValue(board[from.Y][from.X])
+		board[from.Y][from.X] = Piece{}
+		side = side.Opponent()
+	}
+	// Either side may stop recapturing when continuing would lose material.
+	for i := len(gains) - 1; i > 0; i-- {
+		gains[i-1] = -max(-gains[i-1], gains[i])
+	}
+	return gains[0]
+}
+
+func leastValuableAttacker(board *[BoardRows][BoardCols]Piece, player Player, target Coord) (Coord, bool) {
+	var best Coord
+	bestValue := 0
+	found := false
+	for y := 0; y < BoardRows; y++ {
+		for x := 0; x < BoardCols; x++ {
+			p := board[y][x]
+			if !p.Present || p.Owner != player {
+				continue
+			}
+			value := pieceValue(p)
+			if found && value >= bestValue {
+				continue
+			}
+			for _, delta := range movementOffsets(p) {
+				if x+delta.X == target.X && y+delta.Y == target.Y {
+					best, bestValue, found = Coord{X: x, Y: y}, value, true
+					break
+				}
+			}
+		}
+	}
+	return best, found
+}
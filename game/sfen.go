@@ -0,0 +1,159 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatSFEN encodes state in an SFEN-style string adapted to this board: ranks from the
+// top (Top's back rank) down, files a to e left to right, uppercase for Bottom, lowercase
+// for Top, "+" before promoted pieces, then the side to move ("b" Bottom, "w" Top), the
+// pieces in hand ("-" when empty) and a move number of 1.
+func FormatSFEN(state GameState) string {
+	var b strings.Builder
+	for y := BoardRows - 1; y >= 0; y-- {
+		empty := 0
+		for x := 0; x < BoardCols; x++ {
+			p := state.Board[y][x]
+			if !p.Present {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				b.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			if p.Promoted {
+				b.WriteByte('+')
+			}
+			b.WriteString(sfenPieceCode(p.Kind, p.Owner))
+		}
+		if empty > 0 {
+			b.WriteString(strconv.Itoa(empty))
+		}
+		if y > 0 {
+			b.WriteByte('/')
+		}
+	}
+	if state.Turn == Bottom {
+		b.WriteString(" b ")
+	} else {
+		b.WriteString(" w ")
+	}
+	hands := ""
+	for _, player := range []Player{Bottom, Top} {
+		for _, pt := range orderedPieceTypes {
+			count := state.Hands[player][pt]
+			if count == 0 {
+				continue
+			}
+			if count > 1 {
+				hands += strconv.Itoa(count)
+			}
+			hands += sfenPieceCode(pt, player)
+		}
+	}
+	if hands == "" {
+		hands = "-"
+	}
+	b.WriteString(hands)
+	b.WriteString(" 1")
+	return b.String()
+}
+
+// ParseSFEN decodes a string produced by FormatSFEN. The move number is optional.
+func ParseSFEN(sfen string) (GameState, error) {
+	fields := strings.Fields(sfen)
+	if len(fields) < 3 || len(fields) > 4 {
+		return GameState{}, errors.New("sfen: expected board, side to move and hands")
+	}
+	state := GameState{
+		Hands: [2]map[PieceType]int{
+			Bottom: make(map[PieceType]int),
+			Top:    make(map[PieceType]int),
+		},
+	}
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != BoardRows {
+		return GameState{}, fmt.Errorf("sfen: expected %d ranks, got %d", BoardRows, len(ranks))
+	}
+	for i, rank := range ranks {
+		y := BoardRows - 1 - i
+		x := 0
+		promoted := false
+		for _, ch := range rank {
+			switch {
+			case ch >= '1' && ch <= '9':
+				if promoted {
+					return GameState{}, fmt.Errorf("sfen: dangling '+' in rank %q", rank)
+				}
+				x += int(ch - '0')
+			case ch == '+':
+				promoted = true
+			default:
+				kind, owner, ok := parseSFENPiece(ch)
+				if !ok {
+					return GameState{}, fmt.Errorf("sfen: unknown piece %q", ch)
+				}
+				if x >= BoardCols {
+					return GameState{}, fmt.Errorf("sfen: rank %q is too long", rank)
+				}
+				state.Board[y][x] = Piece{Kind: kind, Owner: owner, Promoted: promoted, Present: true}
+				promoted = false
+				x++
+			}
+		}
+		if x != BoardCols || promoted {
+			return GameState{}, fmt.Errorf("sfen: rank %q does not have %d files", rank, BoardCols)
+		}
+	}
+	switch fields[1] {
+	case "b":
+		state.Turn = Bottom
+	case "w":
+		state.Turn = Top
+	default:
+		return GameState{}, fmt.Errorf("sfen: unknown side to move %q", fields[1])
+	}
+	if fields[2] != "-" {
+		count := 0
+		for _, ch := range fields[2] {
+			if ch >= '0' && ch <= '9' {
+				count = count*10 + int(ch-'0')
+				continue
+			}
+			kind, owner, ok := parseSFENPiece(ch)
+			if !ok {
+				return GameState{}, fmt.Errorf("sfen: unknown hand piece %q", ch)
+			}
+			if count == 0 {
+				count = 1
+			}
+			state.Hands[owner][kind] += count
+			count = 0
+		}
+		if count != 0 {
+			return GameState{}, errors.New("sfen: hand count without a piece")
+		}
+	}
+	return state, nil
+}
+
+func sfenPieceCode(pt PieceType, owner Player) string {
+	code := PieceTypeCode(pt)
+	if owner == Top {
+		return strings.ToLower(code)
+	}
+	return code
+}
+
+func parseSFENPiece(ch rune) (PieceType, Player, bool) {
+	owner := Bottom
+	if ch >= 'a' && ch <= 'z' {
+		owner = Top
+	}
+	kind, ok := ParsePieceChar(string(ch))
+	return kind, owner, ok
+}
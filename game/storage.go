@@ -0,0 +1,35 @@
+package game
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrStorageModified is returned when a knowledge file changed on disk after it was loaded,
+// so saving would overwrite knowledge written by another process.
+var ErrStorageModified = errors.New("knowledge file changed on disk since load")
+
+// storageModTime returns the modification time of path, or the zero time if it does not exist.
+func storageModTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// checkStorageUnchanged reports ErrStorageModified when path no longer has the expected mtime.
+func checkStorageUnchanged(path string, expected time.Time) error {
+	current, err := storageModTime(path)
+	if err != nil {
+		return err
+	}
+	if !current.Equal(expected) {
+		return ErrStorageModified
+	}
+	return nil
+}
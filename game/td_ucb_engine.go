@@ -3,6 +3,7 @@ package game
 import (
 	"bufio"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -27,19 +28,38 @@ const (
 
 // TDUCBEngine learns a simple value function with TD(0) updates and uses UCB to
 // balance exploration and exploitation while sampling rollouts.
+//
+// Searches only lock the states they read or update, so parallel games sharing one engine
+// run side by side.
 type TDUCBEngine struct {
-	values      map[string]float64
-	moveStats   map[string]map[string]*tdMoveStat
+	// states holds the learned value and move statistics of each state key. A tdState is
+	// changed in place, so it is only read under the store's locks.
+	states      *knowledgeStore[*tdState]
 	alpha       float64
 	gamma       float64
 	exploration float64
+	// decayAlpha lowers the learning rate of a state as its visits grow.
+	decayAlpha  bool
 	simulations int
 	depth       int
 	rng         *rand.Rand
 	storagePath string
-	dirty       bool
-	mu          sync.Mutex
-	profiler    tdProfiler
+	// saveMu serializes writes of storagePath; see saveLocked.
+	saveMu sync.Mutex
+	// storageModTime is the mtime of storagePath when it was last loaded or saved.
+	storageModTime time.Time
+	// warmupPending is set until Warmup has loaded the values of a deferred engine.
+	warmupPending bool
+	mu            sync.Mutex
+	profiler      tdProfiler
+}
+
+// tdState is what the engine learned about one state. Only value is persisted.
+type tdState struct {
+	value float64
+	// visits counts the updates of value when the learning rate decays.
+	visits int
+	moves  map[string]*tdMoveStat
 }
 
 type tdMoveStat struct {
@@ -78,6 +98,12 @@ func (m *tdMetric) add(duration time.Duration) {
 	}
 }
 
+func (m *tdMetric) merge(other tdMetric) {
+	m.count += other.count
+	m.total += other.total
+	m.max = max(m.max, other.max)
+}
+
 func (m tdMetric) snapshot() TDProfileMetric {
 	if m.count == 0 {
 		return TDProfileMetric{}
@@ -133,6 +159,15 @@ func (p *tdProfiler) reset() {
 	*p = tdProfiler{}
 }
 
+// merge adds the metrics one search collected on its own.
+func (p *tdProfiler) merge(other *tdProfiler) {
+	p.nextMove.merge(other.nextMove)
+	p.simulation.merge(other.simulation)
+	p.moveSelection.merge(other.moveSelection)
+	p.legalGeneration.merge(other.legalGeneration)
+	p.moveApply.merge(other.moveApply)
+}
+
 func durationToMillis(d time.Duration) float64 {
 	return float64(d) / float64(time.Millisecond)
 }
@@ -148,12 +183,130 @@ func NewTDUCBEngine(seed int64) *TDUCBEngine {
 	return newTDUCBEngine(seed, "")
 }
 
-func NewPersistentTDUCBEngine(seed int64, storagePath string) *TDUCBEngine {
+// NewPersistentTDUCBEngine loads the values stored at storagePath and saves what it learns
+// there. Like NewPersistentMCTSEngine, it returns a usable engine together with any load
+// error.
+func NewPersistentTDUCBEngine(seed int64, storagePath string) (*TDUCBEngine, error) {
 	engine := newTDUCBEngine(seed, storagePath)
 	if err := engine.loadKnowledge(); err != nil {
-		fmt.Printf("td-ucb: failed to load knowledge: %v\n", err)
+		return engine, fmt.Errorf("td-ucb: failed to load knowledge: %w", err)
+	}
+	return engine, nil
+}
+
+// TDUCBParams tunes the TD learning and UCB exploration. Zero fields keep the defaults.
+type TDUCBParams struct {
+	// Alpha is the TD learning rate and Gamma the discount, both in (0, 1].
+	Alpha       float64
+	Gamma       float64
+	Exploration float64
+	// DecayAlpha uses 1/(1+visits) of each state as its learning rate, capped at Alpha, so
+	// values settle instead of oscillating. Visit counts are not persisted.
+	DecayAlpha bool
+}
+
+func (p TDUCBParams) withDefaults() TDUCBParams {
+	if p.Alpha == 0 {
+		p.Alpha = defaultTDAlpha
+	}
+	if p.Gamma == 0 {
+		p.Gamma = defaultTDGamma
+	}
+	if p.Exploration == 0 {
+		p.Exploration = defaultTDExploration
+	}
+	return p
+}
+
+// Validate reports parameters outside their ranges.
+func (p TDUCBParams) Validate() error {
+	p = p.withDefaults()
+	if p.Alpha <= 0 || p.Alpha > 1 {
+		return fmt.Errorf("td-ucb: alpha must be in (0, 1], got %g", p.Alpha)
+	}
+	if p.Gamma <= 0 || p.Gamma > 1 {
+		return fmt.Errorf("td-ucb: gamma must be in (0, 1], got %g", p.Gamma)
+	}
+	if p.Exploration < 0 {
+		return fmt.Errorf("td-ucb: exploration must not be negative, got %g", p.Exploration)
+	}
+	return nil
+}
+
+// NewTDUCBEngineWithParams builds an engine with custom learning parameters. An empty
+// storagePath keeps the engine in memory like NewTDUCBEngine. Invalid parameters return no
+// engine; a load failure returns the usable engine together with the error.
+func NewTDUCBEngineWithParams(seed int64, storagePath string, params TDUCBParams) (*TDUCBEngine, error) {
+	engine, err := newTDUCBEngineWithParams(seed, storagePath, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := engine.loadKnowledge(); err != nil {
+		return engine, fmt.Errorf("td-ucb: failed to load knowledge: %w", err)
+	}
+	return engine, nil
+}
+
+// NewDeferredTDUCBEngine is NewTDUCBEngineWithParams without reading storagePath, which is
+// left to Warmup. Until then the engine learns from scratch and does not save.
+func NewDeferredTDUCBEngine(seed int64, storagePath string, params TDUCBParams) (*TDUCBEngine, error) {
+	engine, err := newTDUCBEngineWithParams(seed, storagePath, params)
+	if err != nil {
+		return nil, err
 	}
-	return engine
+	engine.warmupPending = storagePath != ""
+	return engine, nil
+}
+
+// Warmup loads the stored values of a deferred engine without blocking its searches. States
+// learned in the meantime keep their values.
+func (e *TDUCBEngine) Warmup(ctx context.Context) error {
+	e.mu.Lock()
+	pending := e.warmupPending
+	e.mu.Unlock()
+	if !pending {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	loader := newTDUCBEngine(1, e.storagePath)
+	if err := loader.loadKnowledge(); err != nil {
+		// Like the eager constructor, give up on the unreadable file but keep saving, so
+		// persistence is not silently disabled for the rest of the session.
+		e.mu.Lock()
+		if e.warmupPending {
+			e.storageModTime = loader.storageModTime
+			e.warmupPending = false
+		}
+		e.mu.Unlock()
+		return err
+	}
+	// A cancelled warmup leaves the engine unsaved, since its owner is discarding it.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.warmupPending {
+		// ResetKnowledge ran while loading; the loaded values are stale.
+		return nil
+	}
+	e.states.addMissing(loader.states.snapshot())
+	e.storageModTime = loader.storageModTime
+	e.warmupPending = false
+	return nil
+}
+
+func newTDUCBEngineWithParams(seed int64, storagePath string, params TDUCBParams) (*TDUCBEngine, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	engine := newTDUCBEngine(seed, storagePath)
+	params = params.withDefaults()
+	engine.alpha, engine.gamma, engine.exploration = params.Alpha, params.Gamma, params.Exploration
+	engine.decayAlpha = params.DecayAlpha
+	return engine, nil
 }
 
 func newTDUCBEngine(seed int64, storagePath string) *TDUCBEngine {
@@ -161,8 +314,7 @@ func newTDUCBEngine(seed int64, storagePath string) *TDUCBEngine {
 		seed = time.Now().UnixNano()
 	}
 	return &TDUCBEngine{
-		values:      make(map[string]float64),
-		moveStats:   make(map[string]map[string]*tdMoveStat),
+		states:      newKnowledgeStore[*tdState](),
 		alpha:       defaultTDAlpha,
 		gamma:       defaultTDGamma,
 		exploration: defaultTDExploration,
@@ -188,139 +340,169 @@ func (e *TDUCBEngine) ResetProfile() {
 }
 
 func (e *TDUCBEngine) NextMove(state GameState) (Move, error) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
 	start := time.Now()
-	defer e.profiler.observeNextMove(time.Since(start))
-
-	legal := GenerateLegalMoves(state, state.Turn)
+	// Move generation changes the hands in place, so it runs on a copy: callers may share
+	// state between parallel searches.
+	root := CloneState(state)
+	legal := GenerateLegalMoves(root, root.Turn)
 	if len(legal) == 0 {
 		return Move{}, errors.New("no legal moves to play")
 	}
 
-	root := CloneState(state)
+	// Each search profiles and draws random numbers on its own and merges at the end.
+	e.mu.Lock()
+	rng := rand.New(rand.NewSource(e.rng.Int63()))
+	e.mu.Unlock()
+	var profile tdProfiler
 	for i := 0; i < e.simulations; i++ {
-		e.runSimulation(root)
+		e.runSimulation(root, rng, &profile)
 	}
 
-	e.rng.Shuffle(len(legal), func(i, j int) {
+	rng.Shuffle(len(legal), func(i, j int) {
 		legal[i], legal[j] = legal[j], legal[i]
 	})
-	key := e.stateKey(root)
-	stats := e.moveStats[key]
 	best := legal[0]
-	bestScore := math.Inf(-1)
-	for _, mv := range legal {
-		score := e.moveMean(stats, mv)
-		if state.Turn == Top {
-			score = -score
-		}
-		if score > bestScore {
-			bestScore = score
-			best = mv
+	e.states.view(e.stateKey(root), func(st *tdState, _ bool) {
+		bestScore := math.Inf(-1)
+		for _, mv := range legal {
+			score := st.moveMean(mv)
+			if state.Turn == Top {
+				score = -score
+			}
+			if score > bestScore {
+				bestScore = score
+				best = mv
+			}
 		}
-	}
+	})
+	profile.observeNextMove(time.Since(start))
+	e.mu.Lock()
+	e.profiler.merge(&profile)
+	e.mu.Unlock()
 	return best, nil
 }
 
-func (e *TDUCBEngine) runSimulation(root GameState) {
-	e.markDirty()
+func (e *TDUCBEngine) runSimulation(root GameState, rng *rand.Rand, profile *tdProfiler) {
 	simStart := time.Now()
-	defer e.profiler.observeSimulation(time.Since(simStart))
+	defer func() { profile.observeSimulation(time.Since(simStart)) }()
 	state := CloneState(root)
 	for depth := 0; depth < e.depth; depth++ {
+		if _, over := kingCaptureResult(state); over {
+			return
+		}
 		key := e.stateKey(state)
 		legalStart := time.Now()
 		legal := GenerateLegalMoves(state, state.Turn)
-		e.profiler.observeLegalGeneration(time.Since(legalStart))
+		profile.observeLegalGeneration(time.Since(legalStart))
 		if len(legal) == 0 {
-			if InCheck(state, state.Turn) {
-				e.values[key] = e.outcomeForBottom(state.Turn.Opponent())
-			} else {
-				e.values[key] = 0
-			}
+			value := e.outcomeForBottom(noMovesResult(state).Winner)
+			e.states.update(key, func(st *tdState, ok bool) *tdState {
+				if !ok {
+					st = &tdState{}
+				}
+				st.value = value
+				return st
+			})
 			return
 		}
 
-		move := e.selectSimulationMove(state, key, legal)
-		currentValue := e.stateValue(state)
+		move := e.selectSimulationMove(state, key, legal, rng, profile)
 		applyStart := time.Now()
 		mover := state.Turn
 		ApplyMove(&state, move)
 		state.Turn = mover.Opponent()
-		e.profiler.observeMoveApply(time.Since(applyStart))
+		profile.observeMoveApply(time.Since(applyStart))
 
-		reward, terminal := e.evaluateOutcome(state, mover)
+		reward, terminal := e.evaluateOutcome(state, profile)
 		target := reward
 		if !terminal {
 			target += e.gamma * e.stateValue(state)
 		}
 
-		e.values[key] = currentValue + e.alpha*(target-currentValue)
-		e.updateMoveStats(key, move, target)
+		moveKey := FormatMove(move)
+		e.states.update(key, func(st *tdState, ok bool) *tdState {
+			if !ok {
+				st = &tdState{}
+			}
+			st.value += e.learningRate(st) * (target - st.value)
+			st.addMoveStat(moveKey, target)
+			return st
+		})
 
 		if terminal {
-			doneKey := e.stateKey(state)
-			if _, ok := e.values[doneKey]; !ok {
-				e.values[doneKey] = reward
-			}
+			e.states.update(e.stateKey(state), func(st *tdState, ok bool) *tdState {
+				if !ok {
+					st = &tdState{value: reward}
+				}
+				return st
+			})
 			return
 		}
 	}
 }
 
-func (e *TDUCBEngine) selectSimulationMove(state GameState, key string, legal []Move) Move {
-	start := time.Now()
-	defer e.profiler.observeMoveSelection(time.Since(start))
-	stats := e.moveStats[key]
-	if stats == nil {
-		stats = make(map[string]*tdMoveStat)
-		e.moveStats[key] = stats
+// learningRate returns the alpha for one update of st and counts the visit.
+func (e *TDUCBEngine) learningRate(st *tdState) float64 {
+	if !e.decayAlpha {
+		return e.alpha
 	}
-	e.rng.Shuffle(len(legal), func(i, j int) {
+	visits := st.visits
+	st.visits++
+	return min(e.alpha, 1/(1+float64(visits)))
+}
+
+func (e *TDUCBEngine) selectSimulationMove(state GameState, key string, legal []Move, rng *rand.Rand, profile *tdProfiler) Move {
+	start := time.Now()
+	defer func() { profile.observeMoveSelection(time.Since(start)) }()
+	rng.Shuffle(len(legal), func(i, j int) {
 		legal[i], legal[j] = legal[j], legal[i]
 	})
-	total := 0
-	for _, st := range stats {
-		total += st.visits
-	}
-	if total == 0 {
-		total = 1
-	}
-
 	best := legal[0]
-	bestScore := math.Inf(-1)
-	for _, mv := range legal {
-		mKey := FormatMove(mv)
-		entry := stats[mKey]
-		if entry == nil || entry.visits == 0 {
-			return mv
+	e.states.view(key, func(st *tdState, ok bool) {
+		if !ok {
+			return
 		}
-
-		mean := entry.mean()
-		if state.Turn == Top {
-			mean = -mean
+		total := 0
+		for _, entry := range st.moves {
+			total += entry.visits
 		}
-		score := mean + e.exploration*math.Sqrt(math.Log(float64(total)+1)/float64(entry.visits))
-		if score > bestScore {
-			bestScore = score
-			best = mv
+		if total == 0 {
+			total = 1
 		}
-	}
+
+		bestScore := math.Inf(-1)
+		for _, mv := range legal {
+			entry := st.moves[FormatMove(mv)]
+			if entry == nil || entry.visits == 0 {
+				best = mv
+				return
+			}
+
+			mean := entry.mean()
+			if state.Turn == Top {
+				mean = -mean
+			}
+			score := mean + e.exploration*math.Sqrt(math.Log(float64(total)+1)/float64(entry.visits))
+			if score > bestScore {
+				bestScore = score
+				best = mv
+			}
+		}
+	})
 	return best
 }
 
-func (e *TDUCBEngine) evaluateOutcome(state GameState, mover Player) (float64, bool) {
+func (e *TDUCBEngine) evaluateOutcome(state GameState, profile *tdProfiler) (float64, bool) {
+	if result, over := kingCaptureResult(state); over {
+		return e.outcomeForBottom(result.Winner), true
+	}
 	start := time.Now()
 	hasMove := HasLegalMove(state, state.Turn)
-	e.profiler.observeLegalGeneration(time.Since(start))
+	profile.observeLegalGeneration(time.Since(start))
 	if hasMove {
 		return 0, false
 	}
-	if InCheck(state, state.Turn) {
-		return e.outcomeForBottom(mover), true
-	}
-	return 0, true
+	return e.outcomeForBottom(noMovesResult(state).Winner), true
 }
 
 func (e *TDUCBEngine) outcomeForBottom(winner Player) float64 {
@@ -338,51 +520,91 @@ func (e *TDUCBEngine) stateKey(state GameState) string {
 }
 
 func (e *TDUCBEngine) stateValue(state GameState) float64 {
-	if v, ok := e.values[e.stateKey(state)]; ok {
-		return v
-	}
-	return 0
+	var value float64
+	e.states.view(e.stateKey(state), func(st *tdState, ok bool) {
+		if ok {
+			value = st.value
+		}
+	})
+	return value
+}
+
+// SetMaxStates bounds the learned states to n, evicting the least recently read or
+// updated ones together with their move statistics. n <= 0 removes the bound.
+func (e *TDUCBEngine) SetMaxStates(n int) {
+	e.states.setMaxStates(n)
 }
 
-func (e *TDUCBEngine) moveMean(stats map[string]*tdMoveStat, mv Move) float64 {
-	if stats == nil {
+func (st *tdState) moveMean(mv Move) float64 {
+	if st == nil {
 		return 0
 	}
-	entry := stats[FormatMove(mv)]
+	entry := st.moves[FormatMove(mv)]
 	if entry == nil {
 		return 0
 	}
 	return entry.mean()
 }
 
-func (e *TDUCBEngine) updateMoveStats(key string, mv Move, value float64) {
-	stats := e.moveStats[key]
-	if stats == nil {
-		stats = make(map[string]*tdMoveStat)
-		e.moveStats[key] = stats
+func (st *tdState) addMoveStat(moveKey string, value float64) {
+	if st.moves == nil {
+		st.moves = make(map[string]*tdMoveStat)
 	}
-	mKey := FormatMove(mv)
-	entry := stats[mKey]
+	entry := st.moves[moveKey]
 	if entry == nil {
 		entry = &tdMoveStat{}
-		stats[mKey] = entry
+		st.moves[moveKey] = entry
 	}
 	entry.visits++
 	entry.total += value
 }
 
-func (e *TDUCBEngine) markDirty() {
-	if e.storagePath != "" {
-		e.dirty = true
+// ResetKnowledge forgets the learned values and statistics and rewrites the storage file
+// with no states, even if another process changed the file since it was loaded. The engine
+// keeps playing, untrained.
+func (e *TDUCBEngine) ResetKnowledge() error {
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+	e.mu.Lock()
+	e.states.reset()
+	e.warmupPending = false
+	if e.storagePath == "" {
+		e.mu.Unlock()
+		return nil
 	}
+	modTime, err := storageModTime(e.storagePath)
+	if err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	e.storageModTime = modTime
+	e.mu.Unlock()
+	return e.saveLocked()
 }
 
 func (e *TDUCBEngine) SaveIfNeeded() error {
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+	return e.saveLocked()
+}
+
+// saveLocked writes the values if they changed; the caller holds saveMu.
+func (e *TDUCBEngine) saveLocked() error {
 	e.mu.Lock()
-	defer e.mu.Unlock()
-	if !e.dirty || e.storagePath == "" {
+	pending, expected := e.warmupPending, e.storageModTime
+	e.mu.Unlock()
+	if e.storagePath == "" || pending || !e.states.dirty.Swap(false) {
 		return nil
 	}
+	saved := false
+	defer func() {
+		if !saved {
+			e.states.dirty.Store(true)
+		}
+	}()
+	if err := checkStorageUnchanged(e.storagePath, expected); err != nil {
+		return fmt.Errorf("td-ucb: refusing to overwrite %s: %w", e.storagePath, err)
+	}
 	if err := os.MkdirAll(filepath.Dir(e.storagePath), 0o755); err != nil {
 		return err
 	}
@@ -393,7 +615,14 @@ func (e *TDUCBEngine) SaveIfNeeded() error {
 	if err := os.Rename(tmp, e.storagePath); err != nil {
 		return err
 	}
-	e.dirty = false
+	modTime, err := storageModTime(e.storagePath)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.storageModTime = modTime
+	e.mu.Unlock()
+	saved = true
 	return nil
 }
 
@@ -404,9 +633,13 @@ func (e *TDUCBEngine) writeKnowledge(path string) error {
 	}
 	defer file.Close()
 
-	writer := bufio.NewWriter(file)
 	// Persist only the TD state values, move statistics remain in memory.
-	for key, value := range e.values {
+	values := make(map[string]float64, e.states.len())
+	e.states.each(func(key string, st *tdState) {
+		values[key] = st.value
+	})
+	writer := bufio.NewWriter(file)
+	for key, value := range values {
 		if _, err := fmt.Fprintf(writer, "%s\t%s\t%.8f\n", tdRecordState, key, value); err != nil {
 			return err
 		}
@@ -421,6 +654,11 @@ func (e *TDUCBEngine) loadKnowledge() error {
 	if e.storagePath == "" {
 		return nil
 	}
+	modTime, err := storageModTime(e.storagePath)
+	if err != nil {
+		return err
+	}
+	e.storageModTime = modTime
 	file, err := os.Open(e.storagePath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -452,6 +690,8 @@ func (e *TDUCBEngine) loadKnowledge() error {
 	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
 		return err
 	}
+	// The loaded values match the file, so there is nothing to save yet.
+	e.states.dirty.Store(false)
 	return nil
 }
 
@@ -469,7 +709,7 @@ func (e *TDUCBEngine) parseRecord(line string) error {
 		if err != nil {
 			return err
 		}
-		e.values[fields[1]] = value
+		e.states.set(fields[1], &tdState{value: value})
 	default:
 		return fmt.Errorf("td-ucb: unknown record kind %q", fields[0])
 	}
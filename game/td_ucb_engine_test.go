@@ -15,8 +15,7 @@ func TestTDUCBEngineSaveLoadPlainText(t *testing.T) {
 	path := filepath.Join(dir, "td_ucb_data")
 
 	engine := newTDUCBEngine(1, path)
-	engine.values["state"] = 0.25
-	engine.dirty = true
+	engine.states.set("state", &tdState{value: 0.25})
 
 	if err := engine.SaveIfNeeded(); err != nil {
 		t.Fatalf("SaveIfNeeded failed: %v", err)
@@ -33,12 +32,12 @@ func TestTDUCBEngineSaveLoadPlainText(t *testing.T) {
 	if err := reloaded.loadKnowledge(); err != nil {
 		t.Fatalf("loadKnowledge failed: %v", err)
 	}
-	got := reloaded.values["state"]
+	got, _ := learnedValue(reloaded, "state")
 	if math.Abs(got-0.25) > 1e-9 {
 		t.Fatalf("state value = %v, want 0.25", got)
 	}
-	if len(reloaded.moveStats) != 0 {
-		t.Fatalf("move stats should not persist, found: %+v", reloaded.moveStats)
+	if n := learnedMoveStats(reloaded); n != 0 {
+		t.Fatalf("move stats should not persist, found %d", n)
 	}
 }
 
@@ -66,11 +65,134 @@ func TestTDUCBEngineLoadLegacyGzip(t *testing.T) {
 	if err := engine.loadKnowledge(); err != nil {
 		t.Fatalf("loadKnowledge failed: %v", err)
 	}
-	got := engine.values["legacy"]
+	got, _ := learnedValue(engine, "legacy")
 	if math.Abs(got-0.125) > 1e-9 {
 		t.Fatalf("legacy value = %v, want 0.125", got)
 	}
-	if len(engine.moveStats) != 0 {
-		t.Fatalf("move stats should not persist, found: %+v", engine.moveStats)
+	if n := learnedMoveStats(engine); n != 0 {
+		t.Fatalf("move stats should not persist, found %d", n)
+	}
+}
+
+func TestTDUCBEngineCustomAlphaScalesUpdate(t *testing.T) {
+	engine, err := NewTDUCBEngineWithParams(1, "", TDUCBParams{Alpha: 0.25, Gamma: 1})
+	if err != nil {
+		t.Fatalf("NewTDUCBEngineWithParams failed: %v", err)
+	}
+	engine.depth = 1
+	root := NewGame()
+	// Whatever move the simulation picks, it leads to a position valued 0.8.
+	for _, mv := range GenerateLegalMoves(root, root.Turn) {
+		next := CloneState(root)
+		ApplyMove(&next, mv)
+		next.Turn = next.Turn.Opponent()
+		engine.states.set(engine.stateKey(next), &tdState{value: 0.8})
+	}
+
+	engine.runSimulation(root, engine.rng, &engine.profiler)
+	if got, _ := learnedValue(engine, engine.stateKey(root)); math.Abs(got-0.2) > 1e-9 {
+		t.Fatalf("root value = %v, want 0.25 of the way from 0 to 0.8", got)
+	}
+
+	if _, err := NewTDUCBEngineWithParams(1, "", TDUCBParams{Alpha: 1.5}); err == nil {
+		t.Fatalf("expected alpha above 1 to be rejected")
+	}
+}
+
+func TestTDUCBEngineDecayingAlphaSettlesValue(t *testing.T) {
+	spread := func(decay bool) float64 {
+		engine, err := NewTDUCBEngineWithParams(1, "", TDUCBParams{Gamma: 1, Exploration: 100, DecayAlpha: decay})
+		if err != nil {
+			t.Fatalf("NewTDUCBEngineWithParams failed: %v", err)
+		}
+		engine.depth = 1
+		root := NewGame()
+		// Successors alternate between winning and losing values, so targets are noisy.
+		for i, mv := range GenerateLegalMoves(root, root.Turn) {
+			next := CloneState(root)
+			ApplyMove(&next, mv)
+			next.Turn = next.Turn.Opponent()
+			engine.states.set(engine.stateKey(next), &tdState{value: float64(1 - 2*(i%2))})
+		}
+		low, high := math.Inf(1), math.Inf(-1)
+		for i := 0; i < 1000; i++ {
+			engine.runSimulation(root, engine.rng, &engine.profiler)
+			if i >= 900 {
+				value, _ := learnedValue(engine, engine.stateKey(root))
+				low, high = math.Min(low, value), math.Max(high, value)
+			}
+		}
+		return high - low
+	}
+
+	constant, decayed := spread(false), spread(true)
+	if decayed >= constant/10 {
+		t.Fatalf("value spread with decay = %v, without = %v; want decay to settle much tighter", decayed, constant)
+	}
+}
+
+func TestTDUCBEngineMaxStatesBoundsLearnedStates(t *testing.T) {
+	engine := NewTDUCBEngine(1)
+	engine.SetMaxStates(50)
+	state := NewGame()
+	for ply := 0; ply < 4; ply++ {
+		mv, err := engine.NextMove(state)
+		if err != nil {
+			t.Fatalf("NextMove failed: %v", err)
+		}
+		if n := engine.states.len(); n > 50 {
+			t.Fatalf("ply %d: %d learned states, want at most 50", ply, n)
+		}
+		// Every simulation updates the searched position, so it is never evicted.
+		if _, ok := learnedValue(engine, engine.stateKey(state)); !ok {
+			t.Fatalf("ply %d: the searched position was evicted", ply)
+		}
+		ApplyMove(&state, mv)
+		state.Turn = state.Turn.Opponent()
+	}
+}
+
+func learnedValue(engine *TDUCBEngine, key string) (float64, bool) {
+	var value float64
+	var found bool
+	engine.states.view(key, func(st *tdState, ok bool) {
+		if ok {
+			value, found = st.value, true
+		}
+	})
+	return value, found
+}
+
+func learnedMoveStats(engine *TDUCBEngine) int {
+	n := 0
+	engine.states.each(func(_ string, st *tdState) {
+		n += len(st.moves)
+	})
+	return n
+}
+
+func TestTDUCBEngineScoresNoMovesAsLoss(t *testing.T) {
+	engine := NewTDUCBEngine(1)
+	state := newNoMovesState()
+	engine.runSimulation(state, engine.rng, &engine.profiler)
+	got, _ := learnedValue(engine, engine.stateKey(state))
+	if want := engine.outcomeForBottom(Top); got != want {
+		t.Fatalf("value of a position without moves = %v, want %v", got, want)
+	}
+	if reward, terminal := engine.evaluateOutcome(state, &engine.profiler); !terminal || reward != engine.outcomeForBottom(Top) {
+		t.Fatalf("evaluateOutcome = %v %v, want a terminal loss for Bottom", reward, terminal)
+	}
+}
+
+func TestTDUCBEngineStopsWhenKingCanBeCaptured(t *testing.T) {
+	engine := NewTDUCBEngine(1)
+	state := newKingCapturableState()
+	if reward, terminal := engine.evaluateOutcome(state, &engine.profiler); !terminal || reward != engine.outcomeForBottom(Bottom) {
+		t.Fatalf("evaluateOutcome = %v %v, want a terminal win for Bottom", reward, terminal)
+	}
+	// A simulation from a finished game has nothing to play or learn.
+	engine.runSimulation(state, engine.rng, &engine.profiler)
+	if value, found := learnedValue(engine, engine.stateKey(state)); found {
+		t.Fatalf("simulation learned %v for a finished game", value)
 	}
 }
@@ -0,0 +1,65 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const transcriptSFENPrefix = "SFEN "
+
+// Transcript is a decoded game: the initial position, the moves played and every position
+// reached, where Positions[i] is the position after i moves.
+type Transcript struct {
+	Initial   GameState
+	Moves     []Move
+	Positions []GameState
+}
+
+// EncodeTranscript writes a line-based transcript: an "SFEN <position>" header for the
+// initial position followed by one FormatMove per line.
+func EncodeTranscript(initial GameState, moves []Move) string {
+	var b strings.Builder
+	b.WriteString(transcriptSFENPrefix)
+	b.WriteString(FormatSFEN(initial))
+	b.WriteByte('\n')
+	for _, mv := range moves {
+		b.WriteString(FormatMove(mv))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// DecodeTranscript parses a transcript and replays it, rejecting any illegal move. Blank
+// lines are ignored.
+func DecodeTranscript(text string) (Transcript, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], transcriptSFENPrefix) {
+		return Transcript{}, errors.New("transcript: missing SFEN header")
+	}
+	initial, err := ParseSFEN(strings.TrimPrefix(lines[0], transcriptSFENPrefix))
+	if err != nil {
+		return Transcript{}, err
+	}
+	transcript := Transcript{Initial: initial, Positions: []GameState{CloneState(initial)}}
+	state := CloneState(initial)
+	for i, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		mv, err := ParseMove(line)
+		if err != nil {
+			return Transcript{}, fmt.Errorf("transcript: line %d: %w", i+2, err)
+		}
+		legal, next := TryApplyMove(state, mv)
+		if !legal {
+			return Transcript{}, fmt.Errorf("transcript: line %d: illegal move %s", i+2, line)
+		}
+		next.Turn = next.Turn.Opponent()
+		state = next
+		transcript.Moves = append(transcript.Moves, mv)
+		transcript.Positions = append(transcript.Positions, CloneState(state))
+	}
+	return transcript, nil
+}
@@ -0,0 +1,76 @@
+package game
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSFENRoundTrip(t *testing.T) {
+	state := NewShuffledGame(7)
+	state.Board[2][1] = Piece{}
+	state.Board[4][1] = Piece{Kind: Pawn, Owner: Bottom, Promoted: true, Present: true}
+	state.Hands[Top][Pawn] = 2
+	state.Hands[Bottom][Silver] = 1
+	state.Turn = Top
+
+	parsed, err := ParseSFEN(FormatSFEN(state))
+	if err != nil {
+		t.Fatalf("ParseSFEN failed: %v", err)
+	}
+	if parsed.Board != state.Board || parsed.Turn != state.Turn || !sameHands(parsed, state) {
+		t.Fatalf("round trip changed the position:\n%s\n%s", FormatSFEN(state), FormatSFEN(parsed))
+	}
+}
+
+func TestTranscriptRoundTrip(t *testing.T) {
+	initial := NewGame()
+	state := CloneState(initial)
+	engine := NewRandomEngine(3)
+	var moves []Move
+	for i := 0; i < 30; i++ {
+		if mate, _ := CheckmateStatus(state); mate {
+			break
+		}
+		mv, err := engine.NextMove(state)
+		if err != nil {
+			t.Fatalf("NextMove failed: %v", err)
+		}
+		moves = append(moves, mv)
+		ApplyMove(&state, mv)
+		state.Turn = state.Turn.Opponent()
+	}
+
+	decoded, err := DecodeTranscript(EncodeTranscript(initial, moves))
+	if err != nil {
+		t.Fatalf("DecodeTranscript failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Moves, moves) {
+		t.Fatalf("moves changed in round trip")
+	}
+	if len(decoded.Positions) != len(moves)+1 {
+		t.Fatalf("got %d positions, want %d", len(decoded.Positions), len(moves)+1)
+	}
+	final := decoded.Positions[len(moves)]
+	if final.Board != state.Board || final.Turn != state.Turn || !sameHands(final, state) {
+		t.Fatalf("final position differs after replay")
+	}
+}
+
+func TestDecodeTranscriptRejectsIllegalMove(t *testing.T) {
+	text := EncodeTranscript(NewGame(), nil) + "a1a6\n"
+	if _, err := DecodeTranscript(text); err == nil || !strings.Contains(err.Error(), "illegal move") {
+		t.Fatalf("expected an illegal move error, got %v", err)
+	}
+}
+
+func sameHands(a, b GameState) bool {
+	for _, player := range []Player{Bottom, Top} {
+		for _, pt := range orderedPieceTypes {
+			if a.Hands[player][pt] != b.Hands[player][pt] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -5,8 +5,13 @@ import (
 	"flag"
 	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"gorogoro/game"
 	"gorogoro/server"
 )
 
@@ -15,17 +20,76 @@ var webFS embed.FS
 
 func main() {
 	dataDir := flag.String("data-dir", "data", "directory for persistent engine data")
+	namespace := flag.String("namespace", "", "prefix for engine data files when several servers share data-dir")
+	autosave := flag.Duration("autosave", 0, "interval for periodic engine data saves (0 disables)")
+	ponder := flag.Bool("ponder", false, "let MCTS engines think on the human's time")
+	evalHistory := flag.Bool("eval-history", false, "record a shallow evaluation after every move")
+	engineDelay := flag.Duration("engine-delay", 0, "delay before engine replies to human moves")
+	moveHints := flag.Bool("move-hints", false, "mark pieces of the side to move that have a legal move")
+	analysisEngine := flag.String("analysis-engine", "alpha-beta", "engine mode used for /api/hint")
+	analysisDepth := flag.Int("analysis-depth", 3, "search depth of the analysis engine")
+	moveTimeout := flag.Duration("move-timeout", 0, "time a human may take per move (0 disables)")
+	timeoutAction := flag.String("timeout-action", "resign", "action when a human times out: resign or random")
+	knowledgeCompression := flag.String("knowledge-compression", "default", "gzip level of saved MCTS knowledge: default, speed or best")
+	knowledgeFormat := flag.String("knowledge-format", "text", "encoding of saved MCTS knowledge: text or binary")
+	maxKnowledgeStates := flag.Int("max-knowledge-states", 0, "positions kept by MCTS and TD engines, evicting the least recently used (0 means no limit)")
+	debug := flag.Bool("debug", false, "enable test-only endpoints such as /api/force-move (never in production)")
+	language := flag.String("lang", "ja", "language of player names in messages (ja or en)")
+	logLevel := flag.String("log-level", "info", "minimum log level: debug, info, warn or error")
+	evaluation := flag.String("evaluation", "material", "static evaluation shared by the analysis endpoints and MCTS rollouts: material or mobility")
+	handMultiplier := flag.Float64("hand-multiplier", 1, "value of pieces in hand relative to pieces on the board in every evaluation")
 	flag.Parse()
 
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		log.Fatalf("invalid -log-level: %v", err)
+	}
+	ev, err := game.ParseEvaluation(*evaluation)
+	if err != nil {
+		log.Fatalf("invalid -evaluation: %v", err)
+	}
+	if err := server.ValidateNamespace(*namespace); err != nil {
+		log.Fatalf("invalid -namespace: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+
 	webRoot, err := fs.Sub(webFS, "web")
 	if err != nil {
 		log.Fatalf("failed to load web assets: %v", err)
 	}
 
-	srv := server.New(http.FS(webRoot), server.Config{DataDir: *dataDir})
+	srv := server.New(http.FS(webRoot), server.Config{
+		DataDir:              *dataDir,
+		Namespace:            *namespace,
+		AutosaveInterval:     *autosave,
+		Ponder:               *ponder,
+		Evaluation:           game.EvalConfig{Evaluation: ev, HandMultiplier: *handMultiplier},
+		EvalHistory:          *evalHistory,
+		EngineMoveDelay:      *engineDelay,
+		MoveHints:            *moveHints,
+		Debug:                *debug,
+		Logger:               logger,
+		Language:             *language,
+		AnalysisEngine:       *analysisEngine,
+		AnalysisDepth:        *analysisDepth,
+		MoveTimeout:          *moveTimeout,
+		TimeoutAction:        *timeoutAction,
+		KnowledgeCompression: *knowledgeCompression,
+		KnowledgeFormat:      *knowledgeFormat,
+		MaxKnowledgeStates:   *maxKnowledgeStates,
+	})
+
+	// Flush engine knowledge before exiting on interrupt.
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		srv.Shutdown()
+		os.Exit(0)
+	}()
 
 	addr := ":8080"
-	log.Printf("Serving Gorogoro Shogi UI at http://localhost%s\n", addr)
+	logger.Info("serving Gorogoro Shogi UI", "url", "http://localhost"+addr)
 	if err := http.ListenAndServe(addr, srv.Handler()); err != nil {
 		log.Fatalf("server error: %v", err)
 	}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorogoro/game"
+)
+
+const (
+	defaultMateDepth = 3
+	maxMateDepth     = 7
+	mateSearchLimit  = 5 * time.Second
+)
+
+// analysisState returns the position named by the sfen query parameter, or a copy of the
+// current game when it is absent, with its result. SFEN positions have no history, so
+// repetition is not considered for them. ok is false after an error response was written.
+func (s *Server) analysisState(w http.ResponseWriter, r *http.Request) (game.GameState, game.GameResult, bool) {
+	if text := strings.TrimSpace(r.URL.Query().Get("sfen")); text != "" {
+		state, err := game.ParseSFEN(text)
+		if err == nil {
+			// Searches assume a position reachable in a game, e.g. one king per side.
+			err = game.VerifyState(state)
+		}
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+			return game.GameState{}, game.GameResult{}, false
+		}
+		return state, game.DetermineResult(state, nil), true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneGameState(s.game), s.resultLocked(), true
+}
+
+type hintResponse struct {
+	Player string `json:"player"`
+	Move   string `json:"move"`
+	Engine string `json:"engine"`
+	// Breakdown explains the static evaluation of the position for Player.
+	Breakdown game.EvalBreakdown `json:"breakdown"`
+}
+
+// handleHint suggests a move for the side to move. Every call builds a fresh, non-persistent
+// analysis engine so hints never touch the knowledge of the engines playing the game.
+func (s *Server) handleHint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	state, result, ok := s.analysisState(w, r)
+	if !ok {
+		return
+	}
+	if result.Over {
+		s.writeError(w, http.StatusConflict, errCodeGameOver, "game is over")
+		return
+	}
+
+	params := defaultEngineParams(state.Turn, time.Now().UnixNano())
+	params.Depth = s.analysisDepth
+	params.Evaluation = s.evaluation
+	engine, err := newEngineForMode(s.analysisMode, params)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	mv, err := engine.NextMove(state)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	s.writeJSON(w, http.StatusOK, hintResponse{
+		Player:    playerKey(state.Turn),
+		Move:      game.FormatMove(mv),
+		Engine:    s.analysisMode,
+		Breakdown: s.evaluation.Explain(state, state.Turn),
+	})
+}
+
+type analyzeRequest struct {
+	// Moves are played in order from the current position, e.g. ["b3b4", "P@c4"].
+	Moves []string `json:"moves"`
+}
+
+type analyzeResponse struct {
+	boardPayload
+	// Evaluation is Bottom's shallow evaluation of the resulting position.
+	Evaluation int `json:"evaluation"`
+	// Breakdown explains Bottom's static evaluation, before any search.
+	Breakdown  game.EvalBreakdown `json:"breakdown"`
+	LegalMoves []string           `json:"legalMoves"`
+}
+
+// handleAnalyze replays a line on a copy of the current game and describes the result.
+// The game itself is never changed.
+func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req analyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, errCodeBadJSON, "invalid JSON body")
+		return
+	}
+	state, _, ok := s.analysisState(w, r)
+	if !ok {
+		return
+	}
+
+	for idx, text := range req.Moves {
+		mv, err := game.ParseMove(text)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, fmt.Sprintf("move %d (%s): %v", idx, text, err))
+			return
+		}
+		legal, next := game.TryApplyMove(state, mv)
+		if !legal {
+			s.writeError(w, http.StatusBadRequest, errCodeIllegalMove, fmt.Sprintf("move %d (%s): illegal move", idx, text))
+			return
+		}
+		state = next
+		state.Turn = state.Turn.Opponent()
+	}
+
+	resp := analyzeResponse{
+		boardPayload: s.makeBoardPayload(state),
+		Evaluation:   s.evaluation.Evaluate(state, evalHistoryDepth),
+		Breakdown:    s.evaluation.Explain(state, game.Bottom),
+		LegalMoves:   []string{},
+	}
+	for _, mv := range game.GenerateLegalMoves(state, state.Turn) {
+		resp.LegalMoves = append(resp.LegalMoves, game.FormatMove(mv))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+type evalMoveResponse struct {
+	Player string `json:"player"`
+	Move   string `json:"move"`
+	// Evaluation is the shallow evaluation after the move, from Player's point of view.
+	Evaluation int `json:"evaluation"`
+}
+
+// handleEvalMove evaluates one candidate move for the side to move on a copy of the
+// position, so the UI can compare moves before playing one.
+func (s *Server) handleEvalMove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	query := r.URL.Query()
+	req := moveRequest{
+		From: strings.TrimSpace(query.Get("from")),
+		To:   strings.TrimSpace(query.Get("to")),
+		Drop: strings.TrimSpace(query.Get("drop")),
+	}
+	if text := strings.TrimSpace(query.Get("promote")); text != "" {
+		promote, err := strconv.ParseBool(text)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "promote must be true or false")
+			return
+		}
+		req.Promote = &promote
+	}
+	state, _, ok := s.analysisState(w, r)
+	if !ok {
+		return
+	}
+	mv, err := s.moveFromRequest(state, req)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+		return
+	}
+	legal, next := game.TryApplyMove(state, mv)
+	if !legal {
+		s.writeError(w, http.StatusBadRequest, errCodeIllegalMove, "illegal move")
+		return
+	}
+	mover := state.Turn
+	next.Turn = mover.Opponent()
+	evaluation := s.evaluation.Evaluate(next, evalHistoryDepth)
+	if mover == game.Top {
+		evaluation = -evaluation
+	}
+	s.writeJSON(w, http.StatusOK, evalMoveResponse{
+		Player:     playerKey(mover),
+		Move:       game.FormatMove(mv),
+		Evaluation: evaluation,
+	})
+}
+
+type mateResponse struct {
+	Player string   `json:"player"`
+	Found  bool     `json:"found"`
+	Moves  []string `json:"moves"`
+}
+
+// handleMate searches for a forced mate by the side to move within depth plies.
+func (s *Server) handleMate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	depth := defaultMateDepth
+	if text := strings.TrimSpace(r.URL.Query().Get("depth")); text != "" {
+		value, err := strconv.Atoi(text)
+		if err != nil || value <= 0 || value > maxMateDepth {
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, fmt.Sprintf("depth must be between 1 and %d", maxMateDepth))
+			return
+		}
+		depth = value
+	}
+	state, _, ok := s.analysisState(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), mateSearchLimit)
+	defer cancel()
+	found, line, err := game.MateSearchContext(ctx, state, state.Turn, depth, 0)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+		return
+	}
+	resp := mateResponse{Player: playerKey(state.Turn), Found: found, Moves: []string{}}
+	for _, mv := range line {
+		resp.Moves = append(resp.Moves, game.FormatMove(mv))
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+type attackMapResponse struct {
+	Player string `json:"player"`
+	// Squares is indexed [y][x] like the board, with y = 0 being rank 1.
+	Squares [game.BoardRows][game.BoardCols]int `json:"squares"`
+}
+
+// handleAttackMap reports how many pieces of player (default: the side to move) attack
+// each square, for board overlays.
+func (s *Server) handleAttackMap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	state, _, ok := s.analysisState(w, r)
+	if !ok {
+		return
+	}
+	player := state.Turn
+	if text := strings.TrimSpace(r.URL.Query().Get("player")); text != "" {
+		if player, ok = parsePlayer(text); !ok {
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "unknown player for attack map")
+			return
+		}
+	}
+	s.writeJSON(w, http.StatusOK, attackMapResponse{Player: playerKey(player), Squares: game.AttackMap(state, player)})
+}
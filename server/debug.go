@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"gorogoro/game"
+)
+
+const forceMoveWarning = "move applied without legality checks"
+
+type forceMoveResponse struct {
+	Success bool      `json:"success"`
+	Error   *apiError `json:"error,omitempty"`
+	Move    string    `json:"move,omitempty"`
+	// Forced is always true on success so callers cannot mistake the result for normal play.
+	Forced  bool   `json:"forced"`
+	Warning string `json:"warning,omitempty"`
+	// Legal reports whether the move would also have been accepted by /api/move.
+	Legal bool         `json:"legal"`
+	State statePayload `json:"state"`
+}
+
+// handleForceMove plays a move for the side to move without legality checks, so test
+// harnesses can reach arbitrary positions quickly. It only exists when Config.Debug is set
+// and never triggers engine replies.
+func (s *Server) handleForceMove(w http.ResponseWriter, r *http.Request) {
+	if !s.debug {
+		s.writeError(w, http.StatusForbidden, errCodeForbidden, "force-move requires debug mode")
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req moveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, errCodeBadJSON, "invalid JSON body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.auto.active {
+		s.writeError(w, http.StatusConflict, errCodeAutoRunning, "auto play is running")
+		return
+	}
+	mv, err := s.moveFromRequest(s.game, req)
+	if err == nil && mv.From != nil {
+		// Even a forced move needs a piece of the side to move, or the board would hold
+		// a piece of no kind.
+		if piece := s.game.Board[mv.From.Y][mv.From.X]; !piece.Present || piece.Owner != s.game.Turn {
+			err = errors.New("no piece of the side to move on 'from'")
+		}
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+		return
+	}
+
+	legal, _ := game.TryApplyMove(s.game, mv)
+	movingPlayer := s.game.Turn
+	next := game.CloneState(s.game)
+	game.ApplyMove(&next, mv)
+	next.Turn = movingPlayer.Opponent()
+	s.game = next
+	s.recordMove(movingPlayer, mv, s.makeBoardPayload(s.game))
+	s.engineEpoch++
+	s.logger.Warn("forced move", "move", game.FormatMove(mv), "legal", legal)
+
+	s.armMoveTimeoutLocked()
+	s.writeJSON(w, http.StatusOK, forceMoveResponse{
+		Success: true,
+		Move:    game.FormatMove(mv),
+		Forced:  true,
+		Warning: forceMoveWarning,
+		Legal:   legal,
+		State:   s.serializeState(s.game),
+	})
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"gorogoro/game"
+)
+
+// EngineParams carries the settings an engine factory may use. StoragePath is empty when
+// the engine should not persist knowledge (e.g. training games).
+type EngineParams struct {
+	Depth       int
+	Iterations  int
+	Seed        int64
+	StoragePath string
+	Player      game.Player
+	// RolloutPolicy is used by MCTS playouts.
+	RolloutPolicy game.RolloutPolicy
+	// Selection picks the move MCTS plays from its finished search.
+	Selection game.SelectionCriterion
+	// Contempt lowers how MCTS scores undecided playouts; see game.MCTSEngine.SetContempt.
+	Contempt int
+	// TD tunes TD-UCB engines; zero fields keep the defaults.
+	TD game.TDUCBParams
+	// CompressionLevel is the gzip level of saved MCTS knowledge; 0 keeps gzip's default.
+	CompressionLevel int
+	// KnowledgeFormat is the encoding of saved MCTS knowledge.
+	KnowledgeFormat game.KnowledgeFormat
+	// MaxStates bounds the positions MCTS and TD-UCB engines keep; 0 means no bound.
+	MaxStates int
+	// Evaluation scores MCTS rollouts; the alpha-beta engines take its hand multiplier.
+	Evaluation game.EvalConfig
+	// DeferLoad builds persistent engines without reading StoragePath; the caller must run
+	// their game.Warmer Warmup.
+	DeferLoad bool
+	// Logger receives failures the engine cannot return, such as unreadable stored
+	// knowledge; nil discards them.
+	Logger *slog.Logger
+}
+
+func (p EngineParams) logger() *slog.Logger {
+	if p.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return p.Logger
+}
+
+// EngineFactory builds an engine from params.
+type EngineFactory func(EngineParams) (game.Engine, error)
+
+type engineParamInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     int64  `json:"default,omitempty"`
+}
+
+type engineModeInfo struct {
+	Mode   string            `json:"mode"`
+	Label  string            `json:"label"`
+	Params []engineParamInfo `json:"params"`
+}
+
+type enginesResponse struct {
+	Engines []engineModeInfo `json:"engines"`
+}
+
+// engineModeSpec describes a selectable engine. dataFile, when set, names the knowledge
+// file of persistent engines and is formatted with the player key.
+type engineModeSpec struct {
+	info     engineModeInfo
+	factory  EngineFactory
+	dataFile string
+}
+
+var (
+	seedParam       = engineParamInfo{Name: "seed", Description: "random seed (defaults to the current time)"}
+	depthParam      = engineParamInfo{Name: "depth", Description: "search depth in plies", Default: 3}
+	iterationsParam = engineParamInfo{Name: "iterations", Description: "MCTS iterations per move", Default: 800}
+)
+
+// engineRegistry is the single list of engine modes used for construction and listing.
+var engineRegistry = struct {
+	sync.RWMutex
+	order []string
+	specs map[string]engineModeSpec
+}{specs: make(map[string]engineModeSpec)}
+
+// RegisterEngine makes an engine selectable by name. It panics if name is already taken.
+// The returned func removes the engine again, e.g. from a test's Cleanup.
+func RegisterEngine(name string, factory EngineFactory) (unregister func()) {
+	registerEngineMode(engineModeSpec{
+		info:    engineModeInfo{Mode: name, Label: name, Params: []engineParamInfo{}},
+		factory: factory,
+	})
+	return func() { unregisterEngineMode(name) }
+}
+
+func registerEngineMode(spec engineModeSpec) {
+	engineRegistry.Lock()
+	defer engineRegistry.Unlock()
+	name := spec.info.Mode
+	if name == engineHuman {
+		panic("server: engine name is reserved: " + name)
+	}
+	if _, exists := engineRegistry.specs[name]; exists {
+		panic("server: engine registered twice: " + name)
+	}
+	engineRegistry.specs[name] = spec
+	engineRegistry.order = append(engineRegistry.order, name)
+}
+
+func unregisterEngineMode(name string) {
+	engineRegistry.Lock()
+	defer engineRegistry.Unlock()
+	if _, exists := engineRegistry.specs[name]; !exists {
+		return
+	}
+	delete(engineRegistry.specs, name)
+	for i, registered := range engineRegistry.order {
+		if registered == name {
+			engineRegistry.order = append(engineRegistry.order[:i], engineRegistry.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func init() {
+	registerEngineMode(engineModeSpec{
+		info: engineModeInfo{Mode: engineRandom, Label: "ランダム", Params: []engineParamInfo{seedParam}},
+		factory: func(p EngineParams) (game.Engine, error) {
+			return game.NewRandomEngine(p.Seed), nil
+		},
+	})
+	registerEngineMode(engineModeSpec{
+		info: engineModeInfo{Mode: engineAlphaBeta, Label: "αβ探索", Params: []engineParamInfo{depthParam}},
+		factory: func(p EngineParams) (game.Engine, error) {
+			engine, err := game.NewAlphaBetaEngine(p.Depth)
+			if err != nil {
+				return nil, err
+			}
+			engine.SetHandMultiplier(p.Evaluation.HandMultiplier)
+			return engine, nil
+		},
+	})
+	registerEngineMode(engineModeSpec{
+		info: engineModeInfo{Mode: engineAlphaBetaMobility, Label: "αβ探索(機動性)", Params: []engineParamInfo{depthParam}},
+		factory: func(p EngineParams) (game.Engine, error) {
+			engine, err := game.NewMobilityAlphaBetaEngine(p.Depth)
+			if err != nil {
+				return nil, err
+			}
+			engine.SetHandMultiplier(p.Evaluation.HandMultiplier)
+			return engine, nil
+		},
+	})
+	registerEngineMode(engineModeSpec{
+		info: engineModeInfo{Mode: engineTDUCB, Label: "TD(UCB)", Params: []engineParamInfo{seedParam}},
+		factory: func(p EngineParams) (game.Engine, error) {
+			newEngine := game.NewTDUCBEngineWithParams
+			if p.DeferLoad {
+				newEngine = game.NewDeferredTDUCBEngine
+			}
+			engine, err := newEngine(p.Seed, p.StoragePath, p.TD)
+			if engine == nil {
+				return nil, err
+			}
+			if err != nil {
+				// The engine starts without the unreadable knowledge and keeps saving.
+				p.logger().Warn("failed to load engine knowledge", "path", p.StoragePath, "err", err)
+			}
+			engine.SetMaxStates(p.MaxStates)
+			return engine, nil
+		},
+		dataFile: "td_ucb_%s.gz",
+	})
+	registerEngineMode(engineModeSpec{
+		info: engineModeInfo{Mode: engineMCTS, Label: "MCTS", Params: []engineParamInfo{iterationsParam, seedParam}},
+		factory: func(p EngineParams) (game.Engine, error) {
+			var engine *game.MCTSEngine
+			if p.DeferLoad {
+				engine = game.NewDeferredMCTSEngine(p.Iterations, p.Seed, p.StoragePath)
+			} else {
+				var err error
+				if engine, err = game.NewPersistentMCTSEngine(p.Iterations, p.Seed, p.StoragePath); err != nil {
+					p.logger().Warn("failed to load engine knowledge", "path", p.StoragePath, "err", err)
+				}
+			}
+			engine.SetLogger(p.Logger)
+			engine.SetRolloutPolicy(p.RolloutPolicy)
+			engine.SetSelectionCriterion(p.Selection)
+			engine.SetContempt(p.Contempt)
+			engine.SetRolloutEvaluation(p.Evaluation)
+			engine.SetMaxStates(p.MaxStates)
+			engine.SetKnowledgeFormat(p.KnowledgeFormat)
+			if p.CompressionLevel != 0 {
+				if err := engine.SetCompressionLevel(p.CompressionLevel); err != nil {
+					return nil, err
+				}
+			}
+			return engine, nil
+		},
+		dataFile: "mcts_%s.json",
+	})
+}
+
+func lookupEngineMode(mode string) (engineModeSpec, error) {
+	engineRegistry.RLock()
+	defer engineRegistry.RUnlock()
+	spec, ok := engineRegistry.specs[mode]
+	if !ok {
+		return engineModeSpec{}, fmt.Errorf("%w: %s", errUnknownEngine, mode)
+	}
+	return spec, nil
+}
+
+// defaultEngineParams fills the listed defaults and a time-based seed.
+func defaultEngineParams(player game.Player, seed int64) EngineParams {
+	return EngineParams{
+		Depth:      int(depthParam.Default),
+		Iterations: int(iterationsParam.Default),
+		Seed:       seed,
+		Player:     player,
+	}
+}
+
+func listEngineModes() []engineModeInfo {
+	engineRegistry.RLock()
+	defer engineRegistry.RUnlock()
+	modes := []engineModeInfo{{Mode: engineHuman, Label: "人間", Params: []engineParamInfo{}}}
+	for _, name := range engineRegistry.order {
+		modes = append(modes, engineRegistry.specs[name].info)
+	}
+	return modes
+}
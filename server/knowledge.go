@@ -0,0 +1,136 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gorogoro/game"
+)
+
+const defaultKnowledgeReportSize = 20
+
+type knowledgePositionPayload struct {
+	boardPayload
+	PositionHash string `json:"positionHash"`
+	SFEN         string `json:"sfen"`
+	Visits       int    `json:"visits"`
+	BestMove     string `json:"bestMove"`
+	// BestMoveText names the moving piece, e.g. "金 b1a2".
+	BestMoveText string `json:"bestMoveText"`
+	BestVisits   int    `json:"bestVisits"`
+	// WinRate is the best move's win rate for the side to move.
+	WinRate float64 `json:"winRate"`
+}
+
+type knowledgeReport struct {
+	Player    string                     `json:"player"`
+	Positions []knowledgePositionPayload `json:"positions"`
+}
+
+// handleKnowledgeTopMoves lists the most visited positions stored by an MCTS engine of the
+// current game. player selects the engine; without it the first MCTS side is used.
+func (s *Server) handleKnowledgeTopMoves(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	query := r.URL.Query()
+	n := defaultKnowledgeReportSize
+	if text := strings.TrimSpace(query.Get("n")); text != "" {
+		value, err := strconv.Atoi(text)
+		if err != nil || value <= 0 {
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "n must be a positive integer")
+			return
+		}
+		n = value
+	}
+	players := []game.Player{game.Bottom, game.Top}
+	if text := strings.TrimSpace(query.Get("player")); text != "" {
+		player, ok := parsePlayer(text)
+		if !ok {
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "unknown player")
+			return
+		}
+		players = []game.Player{player}
+	}
+
+	s.mu.Lock()
+	var engine *game.MCTSEngine
+	var owner game.Player
+	for _, player := range players {
+		if mcts, ok := s.engines[player].(*game.MCTSEngine); ok {
+			engine, owner = mcts, player
+			break
+		}
+	}
+	s.mu.Unlock()
+	if engine == nil {
+		s.writeError(w, http.StatusNotFound, errCodeNotFound, "no MCTS engine is playing")
+		return
+	}
+
+	report := knowledgeReport{Player: playerKey(owner), Positions: []knowledgePositionPayload{}}
+	for _, pos := range engine.TopPositions(n) {
+		entry := knowledgePositionPayload{
+			boardPayload: s.makeBoardPayload(pos.State),
+			PositionHash: game.PositionHash(pos.State),
+			SFEN:         game.FormatSFEN(pos.State),
+			Visits:       pos.Visits,
+			BestMove:     game.FormatMove(pos.BestMove),
+			BestMoveText: s.labels.MoveText(pos.State, pos.BestMove),
+			BestVisits:   pos.BestVisits,
+		}
+		if pos.BestVisits > 0 {
+			entry.WinRate = pos.BestWins / float64(pos.BestVisits)
+		}
+		report.Positions = append(report.Positions, entry)
+	}
+	s.writeJSON(w, http.StatusOK, report)
+}
+
+// knowledgeResetter is implemented by engines that can forget what they learned.
+type knowledgeResetter interface {
+	ResetKnowledge() error
+}
+
+type knowledgeResetResponse struct {
+	Success bool   `json:"success"`
+	Player  string `json:"player"`
+}
+
+// handleKnowledgeReset wipes the learned knowledge of player's engine, in memory and in its
+// data file. Like force-move it only exists in debug mode, since the data cannot be restored.
+func (s *Server) handleKnowledgeReset(w http.ResponseWriter, r *http.Request) {
+	if !s.debug {
+		s.writeError(w, http.StatusForbidden, errCodeForbidden, "knowledge reset requires debug mode")
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	player, ok := parsePlayer(strings.TrimSpace(r.URL.Query().Get("player")))
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "unknown player")
+		return
+	}
+
+	s.mu.Lock()
+	resetter, ok := s.engines[player].(knowledgeResetter)
+	if ok {
+		// A ponder search would keep using the knowledge being forgotten.
+		s.stopPonderLocked()
+	}
+	s.mu.Unlock()
+	if !ok {
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "selected engine has no learned knowledge")
+		return
+	}
+	if err := resetter.ResetKnowledge(); err != nil {
+		s.writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	s.logger.Warn("engine knowledge reset", "player", playerKey(player))
+	s.writeJSON(w, http.StatusOK, knowledgeResetResponse{Success: true, Player: playerKey(player)})
+}
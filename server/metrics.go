@@ -0,0 +1,42 @@
+package server
+
+import "net/http"
+
+// serverMetrics holds usage counters. Fields are guarded by Server.mu.
+type serverMetrics struct {
+	movesPlayed        int
+	engineMoves        map[string]int
+	illegalMoves       int
+	autoGamesCompleted int
+}
+
+type metricsResponse struct {
+	MovesPlayed        int            `json:"movesPlayed"`
+	EngineMoves        map[string]int `json:"engineMoves"`
+	IllegalMoves       int            `json:"illegalMoves"`
+	AutoGamesCompleted int            `json:"autoGamesCompleted"`
+	TrainingGames      int            `json:"trainingGames"`
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.mu.Lock()
+	resp := metricsResponse{
+		MovesPlayed:        s.metrics.movesPlayed,
+		EngineMoves:        make(map[string]int, len(s.metrics.engineMoves)),
+		IllegalMoves:       s.metrics.illegalMoves,
+		AutoGamesCompleted: s.metrics.autoGamesCompleted,
+	}
+	for mode, count := range s.metrics.engineMoves {
+		resp.EngineMoves[mode] = count
+	}
+	s.mu.Unlock()
+
+	s.training.mu.Lock()
+	resp.TrainingGames = s.training.gamesRun
+	s.training.mu.Unlock()
+	s.writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// openingRecord counts results from the point of view of the player who made the move.
+type openingRecord struct {
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Draws  int `json:"draws"`
+}
+
+type openingEntry struct {
+	Move   string `json:"move"`
+	Player string `json:"player"`
+	openingRecord
+	Games int `json:"games"`
+	// WinRate is Wins divided by Games.
+	WinRate float64 `json:"winRate"`
+}
+
+type openingsPayload struct {
+	Openings []openingEntry `json:"openings"`
+}
+
+type openingKey struct {
+	move   string
+	player string
+}
+
+// openingStats aggregates the first move of every completed game, whether interactive,
+// auto or training, across server restarts.
+type openingStats struct {
+	mu      sync.Mutex
+	path    string
+	records map[openingKey]openingRecord
+	dirty   bool
+}
+
+func loadOpeningStats(path string) (*openingStats, error) {
+	stats := &openingStats{path: path, records: make(map[openingKey]openingRecord)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return stats, nil
+		}
+		return stats, err
+	}
+	var payload openingsPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return stats, err
+	}
+	for _, entry := range payload.Openings {
+		stats.records[openingKey{move: entry.Move, player: entry.Player}] = entry.openingRecord
+	}
+	return stats, nil
+}
+
+// recordGame stores a finished game that opened with move by player. winner is "bottom",
+// "top", or empty for a draw.
+func (st *openingStats) recordGame(move, player, winner string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	key := openingKey{move: move, player: player}
+	record := st.records[key]
+	switch winner {
+	case player:
+		record.Wins++
+	case "":
+		record.Draws++
+	default:
+		record.Losses++
+	}
+	st.records[key] = record
+	st.dirty = true
+}
+
+func (st *openingStats) Snapshot() openingsPayload {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.snapshotLocked()
+}
+
+// snapshotLocked lists the openings with the most played first.
+func (st *openingStats) snapshotLocked() openingsPayload {
+	entries := make([]openingEntry, 0, len(st.records))
+	for key, record := range st.records {
+		games := record.Wins + record.Losses + record.Draws
+		entry := openingEntry{Move: key.move, Player: key.player, openingRecord: record, Games: games}
+		if games > 0 {
+			entry.WinRate = float64(record.Wins) / float64(games)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Games != entries[j].Games {
+			return entries[i].Games > entries[j].Games
+		}
+		if entries[i].Player != entries[j].Player {
+			return entries[i].Player < entries[j].Player
+		}
+		return entries[i].Move < entries[j].Move
+	})
+	return openingsPayload{Openings: entries}
+}
+
+// SaveIfNeeded writes the statistics through a temporary file so readers never see partial data.
+func (st *openingStats) SaveIfNeeded() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(st.snapshotLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.path), 0o755); err != nil {
+		return err
+	}
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, st.path); err != nil {
+		return err
+	}
+	st.dirty = false
+	return nil
+}
+
+func (s *Server) handleOpenings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, s.openings.Snapshot())
+}
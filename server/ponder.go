@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+
+	"gorogoro/game"
+)
+
+// ponderEngine is an engine that can think on the opponent's time: it predicts the reply
+// to its last move and searches the resulting position apart from its game, leaving no
+// trace if the search is abandoned.
+type ponderEngine interface {
+	game.Engine
+	ExpectedReply() (game.Move, bool)
+	PonderSearch(ctx context.Context, state game.GameState) (game.Move, error)
+}
+
+// ponderState holds a background search run on the human's time. The engine predicts the
+// human reply from its last search and prepares its answer to that position.
+type ponderState struct {
+	player game.Player
+	engine ponderEngine
+	cancel context.CancelFunc
+	done   chan struct{}
+	// reply is the predicted human move and state the position after it.
+	reply game.Move
+	state game.GameState
+	// Fields below are written by the ponder goroutine before done is closed.
+	move game.Move
+	err  error
+}
+
+// startPonderLocked begins pondering for the engine that just moved when the side to move
+// is human, replacing any pending search.
+func (s *Server) startPonderLocked() {
+	s.stopPonderLocked()
+	if !s.ponderEnabled || s.auto.active || s.engines[s.game.Turn] != nil {
+		return
+	}
+	if s.resultLocked().Over {
+		return
+	}
+	player := s.game.Turn.Opponent()
+	engine, ok := s.engines[player].(ponderEngine)
+	if !ok {
+		return
+	}
+	reply, ok := engine.ExpectedReply()
+	if !ok {
+		return
+	}
+	next, err := applyEngineMove(s.game, reply)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &ponderState{player: player, engine: engine, cancel: cancel, done: make(chan struct{}), reply: reply, state: next}
+	s.ponder = p
+	s.ponderWG.Add(1)
+	go func() {
+		defer s.ponderWG.Done()
+		p.run(ctx)
+	}()
+}
+
+func (p *ponderState) run(ctx context.Context) {
+	defer close(p.done)
+	p.move, p.err = p.engine.PonderSearch(ctx, cloneGameState(p.state))
+}
+
+// stopPonderLocked cancels the pending search, if any. Shutdown waits for it to return.
+func (s *Server) stopPonderLocked() {
+	if s.ponder != nil {
+		s.ponder.cancel()
+		s.ponder = nil
+	}
+}
+
+// takePonderLocked consumes the pending ponder result. It returns the pondered move when
+// the search has finished for exactly this engine and position; otherwise the search is
+// cancelled because the human played something else or it is still running.
+func (s *Server) takePonderLocked(player game.Player, engine game.Engine, state game.GameState) (game.Move, bool) {
+	p := s.ponder
+	s.stopPonderLocked()
+	if p == nil || p.player != player || p.engine != engine {
+		return game.Move{}, false
+	}
+	select {
+	case <-p.done:
+	default:
+		return game.Move{}, false
+	}
+	if p.err != nil || !sameGameState(p.state, state) {
+		return game.Move{}, false
+	}
+	return p.move, true
+}
+
+func sameGameState(a, b game.GameState) bool {
+	if a.Board != b.Board || a.Turn != b.Turn {
+		return false
+	}
+	for _, player := range []game.Player{game.Bottom, game.Top} {
+		for _, pt := range []game.PieceType{game.King, game.Gold, game.Silver, game.Pawn} {
+			if a.Hands[player][pt] != b.Hands[player][pt] {
+				return false
+			}
+		}
+	}
+	return true
+}
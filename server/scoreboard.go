@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// scoreRecord counts results from the bottom engine's point of view.
+type scoreRecord struct {
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Draws  int `json:"draws"`
+}
+
+type scoreboardEntry struct {
+	BottomEngine string `json:"bottomEngine"`
+	TopEngine    string `json:"topEngine"`
+	scoreRecord
+}
+
+type scoreboardPayload struct {
+	Entries []scoreboardEntry `json:"entries"`
+}
+
+type matchup struct {
+	bottom string
+	top    string
+}
+
+// scoreboard keeps cumulative head-to-head results across training runs.
+type scoreboard struct {
+	mu      sync.Mutex
+	path    string
+	records map[matchup]scoreRecord
+	dirty   bool
+}
+
+func loadScoreboard(path string) (*scoreboard, error) {
+	sb := &scoreboard{path: path, records: make(map[matchup]scoreRecord)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return sb, nil
+		}
+		return sb, err
+	}
+	var payload scoreboardPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return sb, err
+	}
+	for _, entry := range payload.Entries {
+		sb.records[matchup{bottom: entry.BottomEngine, top: entry.TopEngine}] = entry.scoreRecord
+	}
+	return sb, nil
+}
+
+// recordResult stores a finished game. winner is "bottom", "top", or empty for a draw.
+func (sb *scoreboard) recordResult(bottom, top, winner string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	key := matchup{bottom: bottom, top: top}
+	record := sb.records[key]
+	switch winner {
+	case "bottom":
+		record.Wins++
+	case "top":
+		record.Losses++
+	default:
+		record.Draws++
+	}
+	sb.records[key] = record
+	sb.dirty = true
+}
+
+func (sb *scoreboard) Snapshot() scoreboardPayload {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.snapshotLocked()
+}
+
+func (sb *scoreboard) snapshotLocked() scoreboardPayload {
+	entries := make([]scoreboardEntry, 0, len(sb.records))
+	for key, record := range sb.records {
+		entries = append(entries, scoreboardEntry{
+			BottomEngine: key.bottom,
+			TopEngine:    key.top,
+			scoreRecord:  record,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].BottomEngine != entries[j].BottomEngine {
+			return entries[i].BottomEngine < entries[j].BottomEngine
+		}
+		return entries[i].TopEngine < entries[j].TopEngine
+	})
+	return scoreboardPayload{Entries: entries}
+}
+
+// SaveIfNeeded writes the scoreboard through a temporary file so readers never see partial data.
+func (sb *scoreboard) SaveIfNeeded() error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	if !sb.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(sb.snapshotLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(sb.path), 0o755); err != nil {
+		return err
+	}
+	tmp := sb.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, sb.path); err != nil {
+		return err
+	}
+	sb.dirty = false
+	return nil
+}
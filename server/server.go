@@ -1,13 +1,18 @@
 package server
 
 import (
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
@@ -21,17 +26,67 @@ type Server struct {
 	mu      sync.Mutex
 	game    game.GameState
 	history []historyEntry
-	initial boardPayload
-	static  http.Handler
-	engines map[game.Player]game.Engine
-	modes   map[game.Player]string
-	dataDir string
-	auto    struct {
+	// positions holds game.PositionKey of every position in the game for repetition checks.
+	positions []string
+	initial   boardPayload
+	static    http.Handler
+	engines   map[game.Player]game.Engine
+	modes     map[game.Player]string
+	// warmCancel stops the background knowledge load of each side's engine.
+	warmCancel [2]context.CancelFunc
+	// engineEpoch changes whenever an engine is assigned or the game changes outside the
+	// engine loop, so a move computed before that is dropped even if the same engine
+	// instance is back in place.
+	engineEpoch int
+	dataDir     string
+	namespace   string
+	auto        struct {
 		active   bool
 		stopCh   chan struct{}
 		interval time.Duration
 	}
-	training *trainingManager
+	training     *trainingManager
+	autosaveStop chan struct{}
+	initErr      error
+	maxParallel  int
+	scoreboard   *scoreboard
+	openings     *openingStats
+	// openingRecorded is set once the current game's first move has been counted.
+	openingRecorded bool
+	// ponderEnabled lets MCTS engines think on the human's time; ponder is the pending search
+	// and ponderWG tracks the ponder goroutines so Shutdown can wait for them.
+	ponderEnabled bool
+	ponder        *ponderState
+	ponderWG      sync.WaitGroup
+	// evalHistory holds a shallow evaluation after each ply when evalHistoryEnabled.
+	evalHistoryEnabled bool
+	evalHistory        []int
+	// engineMoveDelay is waited before each engine reply outside auto play.
+	engineMoveDelay time.Duration
+	// moveHints marks whether each piece of the side to move has a legal move.
+	moveHints bool
+	logger    *slog.Logger
+	metrics   serverMetrics
+	labels    game.LabelSet
+	// analysisMode and analysisDepth configure the engine behind /api/hint.
+	analysisMode  string
+	analysisDepth int
+	// evaluation is Config.Evaluation.
+	evaluation game.EvalConfig
+	// moveTimeout and timeoutAction handle idle humans; see armMoveTimeoutLocked.
+	moveTimeout   time.Duration
+	timeoutAction string
+	timeout       moveTimeoutState
+	// compressionLevel is the gzip level of saved engine knowledge.
+	compressionLevel int
+	// knowledgeFormat is the encoding of saved MCTS knowledge.
+	knowledgeFormat game.KnowledgeFormat
+	// maxKnowledgeStates bounds the positions persistent engines keep (0 means no bound).
+	maxKnowledgeStates int
+	// debug enables test-only endpoints such as /api/force-move.
+	debug bool
+	// seed is the seed requested for the current game (0 when none was given).
+	seed int64
 }
 
 const (
@@ -43,11 +98,56 @@ const (
 	engineHuman             = "human"
 	defaultAutoInterval     = 1500 * time.Millisecond
 	defaultTrainingMaxMoves = 300
+	evalHistoryDepth        = 2
+	drawReasonAgreement     = "agreement"
+	maxIntervalMS           = 60_000
+	maxTrainingIterations   = 100_000
 	defaultDataDir          = "data"
 )
 
 type Config struct {
 	DataDir string
+	// Namespace prefixes engine data file names so several servers can share DataDir. It
+	// must not contain path separators; see ValidateNamespace.
+	Namespace string
+	// AutosaveInterval periodically flushes engine knowledge when positive.
+	AutosaveInterval time.Duration
+	// MaxTrainingParallel caps concurrent training games (default: runtime.NumCPU()*2).
+	MaxTrainingParallel int
+	// Ponder lets MCTS engines search the expected human reply in the background.
+	Ponder bool
+	// EvalHistory records a shallow evaluation after every ply in the state payload.
+	EvalHistory bool
+	// EngineMoveDelay makes engine replies to human moves wait so the opponent appears to think.
+	EngineMoveDelay time.Duration
+	// MoveHints annotates board cells of the side to move with hasLegalMove.
+	MoveHints bool
+	// Debug enables /api/force-move, which plays moves without legality checks. It is meant
+	// for test harnesses only and stays off unless set explicitly.
+	Debug bool
+	// Logger receives operational messages (default: info level to stdout).
+	Logger *slog.Logger
+	// Language selects player names in messages ("en" for English, Japanese otherwise).
+	Language string
+	// AnalysisEngine is the engine mode used for hints (default: alpha-beta).
+	AnalysisEngine string
+	// AnalysisDepth is the search depth of the analysis engine (default: 3).
+	AnalysisDepth int
+	// MoveTimeout, when positive, limits how long a human may take on their turn.
+	MoveTimeout time.Duration
+	// TimeoutAction is "resign" (default) or "random", which plays a random move instead.
+	TimeoutAction string
+	// KnowledgeCompression is "default", "speed" (faster saves, larger files) or "best".
+	KnowledgeCompression string
+	// KnowledgeFormat is "text" (default, human-readable) or "binary" (faster for large files).
+	KnowledgeFormat string
+	// MaxKnowledgeStates, when positive, bounds the positions persistent engines keep by
+	// evicting the least recently used ones.
+	MaxKnowledgeStates int
+	// Evaluation is the static evaluation of the alpha-beta engines, MCTS rollouts, the
+	// analysis endpoints and the evaluation history. The alpha-beta engines keep their own
+	// evaluation kind and only take its hand multiplier.
+	Evaluation game.EvalConfig
 }
 
 func New(staticFS http.FileSystem, cfg Config) *Server {
@@ -55,8 +155,21 @@ func New(staticFS http.FileSystem, cfg Config) *Server {
 	if dataDir == "" {
 		dataDir = defaultDataDir
 	}
-	if err := os.MkdirAll(dataDir, 0o755); err != nil {
-		log.Printf("failed to create data directory %q: %v", dataDir, err)
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+	analysisMode := strings.TrimSpace(cfg.AnalysisEngine)
+	if analysisMode == "" {
+		analysisMode = engineAlphaBeta
+	}
+	analysisDepth := cfg.AnalysisDepth
+	if analysisDepth <= 0 {
+		analysisDepth = int(depthParam.Default)
+	}
+	maxParallel := cfg.MaxTrainingParallel
+	if maxParallel <= 0 {
+		maxParallel = runtime.NumCPU() * 2
 	}
 	s := &Server{
 		game:   game.NewGame(),
@@ -69,38 +182,198 @@ func New(staticFS http.FileSystem, cfg Config) *Server {
 			game.Bottom: engineHuman,
 			game.Top:    engineRandom,
 		},
-		dataDir: dataDir,
+		dataDir:            dataDir,
+		namespace:          strings.TrimSpace(cfg.Namespace),
+		maxParallel:        maxParallel,
+		ponderEnabled:      cfg.Ponder,
+		evalHistoryEnabled: cfg.EvalHistory,
+		engineMoveDelay:    cfg.EngineMoveDelay,
+		moveHints:          cfg.MoveHints,
+		debug:              cfg.Debug,
+		logger:             logger,
+		metrics:            serverMetrics{engineMoves: make(map[string]int)},
+		labels:             game.LabelSetForLanguage(cfg.Language),
+		analysisMode:       analysisMode,
+		analysisDepth:      analysisDepth,
+		evaluation:         cfg.Evaluation,
+		moveTimeout:        cfg.MoveTimeout,
+		timeoutAction:      timeoutResign,
+		compressionLevel:   gzip.DefaultCompression,
+		maxKnowledgeStates: max(cfg.MaxKnowledgeStates, 0),
 	}
-	s.training = newTrainingManager(func(mode string, player game.Player) (game.Engine, error) {
-		return s.buildEngine(mode, player)
-	})
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		s.logger.Error("failed to create data directory", "dir", dataDir, "err", err)
+		s.initErr = fmt.Errorf("create data directory: %w", err)
+	}
+	if err := ValidateNamespace(s.namespace); err != nil {
+		// Keep the files inside dataDir and apart from the unprefixed ones; readyz reports
+		// the error.
+		s.logger.Error("invalid namespace", "namespace", s.namespace, "err", err)
+		s.initErr = errors.Join(s.initErr, err)
+		s.namespace = strings.Map(func(r rune) rune {
+			if isPathSeparator(r) {
+				return '_'
+			}
+			return r
+		}, s.namespace)
+	}
+	board, err := loadScoreboard(s.engineDataPath("scoreboard.json"))
+	if err != nil {
+		s.logger.Warn("failed to load scoreboard", "err", err)
+	}
+	s.scoreboard = board
+	openings, err := loadOpeningStats(s.engineDataPath("openings.json"))
+	if err != nil {
+		s.logger.Warn("failed to load opening statistics", "err", err)
+	}
+	s.openings = openings
+	s.training = newTrainingManager(s.buildEngine)
+	s.training.scoreboard = board
+	s.training.openings = openings
+	s.training.logger = logger
+	s.positions = []string{game.PositionKey(s.game)}
 	s.initial = s.makeBoardPayload(s.game)
 	if err := s.setEngine(game.Top, engineRandom); err != nil {
-		log.Printf("failed to initialize engine: %v", err)
+		s.logger.Error("failed to initialize engine", "err", err)
+		s.initErr = errors.Join(s.initErr, fmt.Errorf("initialize engine: %w", err))
+	}
+	if _, err := lookupEngineMode(analysisMode); err != nil {
+		s.logger.Error("invalid analysis engine", "err", err)
+		s.initErr = errors.Join(s.initErr, fmt.Errorf("analysis engine: %w", err))
+	}
+	switch action := strings.TrimSpace(cfg.TimeoutAction); action {
+	case "", timeoutResign:
+	case timeoutRandom:
+		s.timeoutAction = timeoutRandom
+	default:
+		s.logger.Error("invalid timeout action", "action", action)
+		s.initErr = errors.Join(s.initErr, fmt.Errorf("unknown timeout action %q", action))
+	}
+	switch compression := strings.TrimSpace(cfg.KnowledgeCompression); compression {
+	case "", "default":
+	case "speed":
+		s.compressionLevel = gzip.BestSpeed
+	case "best":
+		s.compressionLevel = gzip.BestCompression
+	default:
+		s.logger.Error("invalid knowledge compression", "compression", compression)
+		s.initErr = errors.Join(s.initErr, fmt.Errorf("unknown knowledge compression %q", compression))
+	}
+	switch format := strings.TrimSpace(cfg.KnowledgeFormat); format {
+	case "", "text":
+	case "binary":
+		s.knowledgeFormat = game.KnowledgeBinary
+	default:
+		s.logger.Error("invalid knowledge format", "format", format)
+		s.initErr = errors.Join(s.initErr, fmt.Errorf("unknown knowledge format %q", format))
+	}
+	s.armMoveTimeoutLocked()
+	if cfg.AutosaveInterval > 0 {
+		s.autosaveStop = make(chan struct{})
+		go s.runAutosave(s.autosaveStop, cfg.AutosaveInterval)
 	}
 	return s
 }
 
+// Shutdown stops background work and flushes engine knowledge to disk.
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopAutoPlayLocked()
+	s.timeout.generation++
+	if s.timeout.timer != nil {
+		s.timeout.timer.Stop()
+	}
+	if s.autosaveStop != nil {
+		close(s.autosaveStop)
+		s.autosaveStop = nil
+	}
+	for _, player := range []game.Player{game.Bottom, game.Top} {
+		s.cancelWarmupLocked(player)
+	}
+	// Ponder searches only read engine state, so they finish without the server lock.
+	s.stopPonderLocked()
+	s.ponderWG.Wait()
+	s.flushEngineDataLocked()
+}
+
+func (s *Server) runAutosave(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.flushEngineDataLocked()
+			s.mu.Unlock()
+		}
+	}
+}
+
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.Handle("/", s.static)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
 	mux.HandleFunc("/api/state", s.handleState)
 	mux.HandleFunc("/api/legal", s.handleLegal)
 	mux.HandleFunc("/api/move", s.handleMove)
+	mux.HandleFunc("/api/moves", s.handleMoves)
+	mux.HandleFunc("/api/force-move", s.handleForceMove)
+	mux.HandleFunc("/api/step", s.handleStep)
 	mux.HandleFunc("/api/reset", s.handleReset)
+	mux.HandleFunc("/api/newgame", s.handleNewGame)
 	mux.HandleFunc("/api/engine", s.handleEngine)
 	mux.HandleFunc("/api/engine/profile", s.handleEngineProfile)
+	mux.HandleFunc("/api/engines", s.handleEngines)
 	mux.HandleFunc("/api/auto", s.handleAuto)
 	mux.HandleFunc("/api/training", s.handleTraining)
 	mux.HandleFunc("/api/training/game", s.handleTrainingGame)
+	mux.HandleFunc("/api/scoreboard", s.handleScoreboard)
+	mux.HandleFunc("/api/openings", s.handleOpenings)
+	mux.HandleFunc("/api/metrics", s.handleMetrics)
+	mux.HandleFunc("/api/hint", s.handleHint)
+	mux.HandleFunc("/api/analyze", s.handleAnalyze)
+	mux.HandleFunc("/api/mate", s.handleMate)
+	mux.HandleFunc("/api/eval-move", s.handleEvalMove)
+	mux.HandleFunc("/api/attackmap", s.handleAttackMap)
+	mux.HandleFunc("/api/knowledge/top-moves", s.handleKnowledgeTopMoves)
+	mux.HandleFunc("/api/knowledge/reset", s.handleKnowledgeReset)
 	return mux
 }
 
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz reports whether New finished its setup without errors.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.initErr != nil {
+		s.writeError(w, http.StatusServiceUnavailable, errCodeNotReady, "not ready: "+s.initErr.Error())
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
 type piecePayload struct {
 	Kind     string `json:"kind"`
 	Owner    string `json:"owner,omitempty"`
 	Promoted bool   `json:"promoted"`
 	Present  bool   `json:"present"`
+	// HasLegalMove is set on pieces of the side to move when move hints are enabled.
+	HasLegalMove *bool `json:"hasLegalMove,omitempty"`
 }
 
 type boardPayload struct {
@@ -119,43 +392,120 @@ type statePayload struct {
 	AutoPlaying bool              `json:"autoPlaying"`
 	History     []historyEntry    `json:"history"`
 	Initial     boardPayload      `json:"initial"`
+	// GameOver and Reason ("checkmate", "no-moves", "repetition" or "timeout") describe how
+	// the game ended.
+	GameOver bool   `json:"gameOver"`
+	Reason   string `json:"reason,omitempty"`
+	// PositionHash identifies the position and side to move, independent of move order.
+	PositionHash string `json:"positionHash"`
+	// EvalHistory lists Bottom's evaluation after each ply when enabled.
+	EvalHistory []int `json:"evalHistory,omitempty"`
+	// Ply counts half-moves played; MoveNumber is the 1-based full move being played.
+	Ply        int `json:"ply"`
+	MoveNumber int `json:"moveNumber"`
+	// Droppable lists the hand pieces the side to move can drop somewhere.
+	Droppable []string `json:"droppable"`
+	// Phase is "opening", "midgame" or "endgame"; see game.GamePhase.
+	Phase string `json:"phase"`
+	// Seed is the seed the game was started with, if any.
+	Seed int64 `json:"seed,omitempty"`
 }
 
 type historyEntry struct {
 	Player   string       `json:"player"`
 	Move     string       `json:"move"`
 	Snapshot boardPayload `json:"snapshot"`
+	// Assessment is the engine's own view of its move; it is nil for human moves and for
+	// engines that do not report their search.
+	Assessment *engineAssessment `json:"assessment,omitempty"`
+}
+
+// engineAssessment is game.SearchInfo as reported in history. Eval is from the mover's side.
+type engineAssessment struct {
+	Depth int `json:"depth"`
+	Eval  int `json:"eval"`
 }
 
 type moveRequest struct {
-	From    string `json:"from,omitempty"`
-	To      string `json:"to"`
-	Drop    string `json:"drop,omitempty"`
-	Promote bool   `json:"promote"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+	Drop string `json:"drop,omitempty"`
+	// Promote may be omitted; an optional promotion then asks the client to choose.
+	Promote *bool `json:"promote,omitempty"`
 }
 
 type moveResponse struct {
+	Success  bool         `json:"success"`
+	Error    *apiError    `json:"error,omitempty"`
+	State    statePayload `json:"state"`
+	Message  string       `json:"message,omitempty"`
+	Winner   string       `json:"winner,omitempty"`
+	GameOver bool         `json:"gameOver"`
+	Reason   string       `json:"reason,omitempty"`
+	// RequiresPromotionChoice is set, with both options, when the move was sent without
+	// promote but may be played either way. Nothing is applied until the client chooses.
+	RequiresPromotionChoice bool               `json:"requiresPromotionChoice,omitempty"`
+	PromotionOptions        []legalMovePayload `json:"promotionOptions,omitempty"`
+	// Applied is set when an engine reply fails after the move was accepted. It lists the
+	// moves that stay on the board, the requested move first and then the engine replies
+	// played before the failure, so State is never advanced without saying how far.
+	Applied []string `json:"applied,omitempty"`
+}
+
+type movesRequest struct {
+	// Moves are applied in order, e.g. ["b3b4", "c4c3", "P@c4"].
+	Moves []string `json:"moves"`
+}
+
+type moveResult struct {
+	Move    string    `json:"move"`
+	Success bool      `json:"success"`
+	Error   *apiError `json:"error,omitempty"`
+}
+
+type movesResponse struct {
 	Success bool         `json:"success"`
-	Error   string       `json:"error,omitempty"`
-	State   statePayload `json:"state"`
-	Message string       `json:"message,omitempty"`
-	Winner  string       `json:"winner,omitempty"`
+	Results []moveResult `json:"results"`
+	// FailedIndex is the index of the first rejected move; later moves are not tried.
+	FailedIndex *int         `json:"failedIndex,omitempty"`
+	State       statePayload `json:"state"`
 }
 
 type legalMovePayload struct {
-	To      string `json:"to"`
-	Promote bool   `json:"promote"`
+	From       string `json:"from,omitempty"`
+	Drop       string `json:"drop,omitempty"`
+	To         string `json:"to"`
+	Promote    bool   `json:"promote"`
+	GivesCheck bool   `json:"givesCheck"`
 }
 
 type legalResponse struct {
 	Moves []legalMovePayload `json:"moves"`
+	// InCheck reports whether the side to move is in check; Checkers lists the squares of
+	// the pieces giving it.
+	InCheck  bool     `json:"inCheck"`
+	Checkers []string `json:"checkers,omitempty"`
+}
+
+func newLegalMovePayload(state game.GameState, mv game.Move) legalMovePayload {
+	payload := legalMovePayload{
+		To:         game.CoordToString(mv.To),
+		Promote:    mv.Promote,
+		GivesCheck: game.GivesCheck(state, mv),
+	}
+	if mv.Drop != nil {
+		payload.Drop = game.PieceTypeCode(*mv.Drop)
+	} else {
+		payload.From = game.CoordToString(*mv.From)
+	}
+	return payload
 }
 
 func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	payload := s.serializeState(s.game)
 	s.mu.Unlock()
-	writeJSON(w, http.StatusOK, payload)
+	s.writeJSON(w, http.StatusOK, payload)
 }
 
 func (s *Server) handleLegal(w http.ResponseWriter, r *http.Request) {
@@ -164,8 +514,9 @@ func (s *Server) handleLegal(w http.ResponseWriter, r *http.Request) {
 
 	from := strings.TrimSpace(r.URL.Query().Get("from"))
 	dropCode := strings.TrimSpace(r.URL.Query().Get("drop"))
-	if from == "" && dropCode == "" {
-		http.Error(w, "query 'from' or 'drop' is required", http.StatusBadRequest)
+	to := strings.TrimSpace(r.URL.Query().Get("to"))
+	if from == "" && dropCode == "" && to == "" {
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "query 'from', 'drop' or 'to' is required")
 		return
 	}
 
@@ -174,52 +525,75 @@ func (s *Server) handleLegal(w http.ResponseWriter, r *http.Request) {
 	if from != "" {
 		coord, err := game.ParseCoord(strings.ToLower(from))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
 			return
 		}
 		filtered = game.GenerateLegalMovesFrom(s.game, s.game.Turn, coord)
+	} else if dropCode == "" {
+		coord, err := game.ParseCoord(strings.ToLower(to))
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+			return
+		}
+		for _, mv := range game.GenerateLegalMoves(s.game, s.game.Turn) {
+			if mv.To == coord {
+				filtered = append(filtered, mv)
+			}
+		}
 	} else {
 		pt, ok := game.ParsePieceChar(strings.ToUpper(dropCode))
 		if !ok {
-			http.Error(w, "unknown piece type for drop", http.StatusBadRequest)
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "unknown piece type for drop")
 			return
 		}
-		if s.game.Hands[s.game.Turn][pt] == 0 {
-			writeJSON(w, http.StatusOK, legalResponse{Moves: []legalMovePayload{}})
-			return
+		if assume, _ := strconv.ParseBool(r.URL.Query().Get("assumeHand")); assume {
+			filtered = game.GenerateLegalDropsAssumingHand(s.game, s.game.Turn, pt)
+		} else if s.game.Hands[s.game.Turn][pt] > 0 {
+			filtered = game.GenerateLegalDrops(s.game, s.game.Turn, pt)
 		}
-		filtered = game.GenerateLegalDrops(s.game, s.game.Turn, pt)
 	}
 
 	resp := legalResponse{Moves: make([]legalMovePayload, 0, len(filtered))}
+	for _, sq := range game.CheckingPieces(s.game, s.game.Turn) {
+		resp.InCheck = true
+		resp.Checkers = append(resp.Checkers, game.CoordToString(sq))
+	}
 	for _, mv := range filtered {
-		resp.Moves = append(resp.Moves, legalMovePayload{
-			To:      game.CoordToString(mv.To),
-			Promote: mv.Promote,
-		})
+		resp.Moves = append(resp.Moves, newLegalMovePayload(s.game, mv))
 	}
-	writeJSON(w, http.StatusOK, resp)
+	s.writeJSON(w, http.StatusOK, resp)
 }
 
 func (s *Server) handleMove(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
 		return
 	}
 
 	var req moveRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		s.writeError(w, http.StatusBadRequest, errCodeBadJSON, "invalid JSON body")
 		return
 	}
 
 	s.mu.Lock()
+	s.armMoveTimeoutLocked()
 	if s.auto.active {
 		payload := s.serializeState(s.game)
 		s.mu.Unlock()
-		writeJSON(w, http.StatusConflict, moveResponse{
+		s.writeJSON(w, http.StatusConflict, moveResponse{
 			Success: false,
-			Error:   "auto play is running",
+			Error:   &apiError{Code: errCodeAutoRunning, Message: "auto play is running"},
+			State:   payload,
+		})
+		return
+	}
+	if s.resultLocked().Over {
+		payload := s.serializeState(s.game)
+		s.mu.Unlock()
+		s.writeJSON(w, http.StatusConflict, moveResponse{
+			Success: false,
+			Error:   &apiError{Code: errCodeGameOver, Message: "game is over"},
 			State:   payload,
 		})
 		return
@@ -229,27 +603,48 @@ func (s *Server) handleMove(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		payload := s.serializeState(s.game)
 		s.mu.Unlock()
-		writeJSON(w, http.StatusBadRequest, moveResponse{
+		s.writeJSON(w, http.StatusBadRequest, moveResponse{
 			Success: false,
-			Error:   err.Error(),
+			Error:   &apiError{Code: errCodeBadRequest, Message: err.Error()},
 			State:   payload,
 		})
 		return
 	}
 
+	if req.Promote == nil && mv.Drop == nil {
+		promoted := mv
+		promoted.Promote = true
+		canPromote, _ := game.TryApplyMove(s.game, promoted)
+		canStay, _ := game.TryApplyMove(s.game, mv)
+		if canPromote && canStay {
+			resp := moveResponse{RequiresPromotionChoice: true, State: s.serializeState(s.game)}
+			for _, option := range []game.Move{promoted, mv} {
+				resp.PromotionOptions = append(resp.PromotionOptions, newLegalMovePayload(s.game, option))
+			}
+			s.mu.Unlock()
+			s.writeJSON(w, http.StatusOK, resp)
+			return
+		}
+		if canPromote {
+			mv = promoted
+		}
+	}
+
 	legal, applied := game.TryApplyMove(s.game, mv)
 	if !legal {
+		s.metrics.illegalMoves++
 		payload := s.serializeState(s.game)
 		s.mu.Unlock()
-		writeJSON(w, http.StatusBadRequest, moveResponse{
+		s.writeJSON(w, http.StatusBadRequest, moveResponse{
 			Success: false,
-			Error:   "illegal move",
+			Error:   &apiError{Code: errCodeIllegalMove, Message: "illegal move"},
 			State:   payload,
 		})
 		return
 	}
 
 	movingPlayer := s.game.Turn
+	firstApplied := len(s.history)
 	s.game = applied
 	s.game.Turn = s.game.Turn.Opponent()
 	s.recordMove(movingPlayer, mv, s.makeBoardPayload(s.game))
@@ -259,60 +654,328 @@ func (s *Server) handleMove(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		s.mu.Lock()
 		payload := s.serializeState(s.game)
+		var appliedMoves []string
+		// A reset during the engine's think leaves nothing of this request on the board.
+		if firstApplied < len(s.history) {
+			for _, entry := range s.history[firstApplied:] {
+				appliedMoves = append(appliedMoves, entry.Move)
+			}
+		}
+		s.armMoveTimeoutLocked()
 		s.mu.Unlock()
-		writeJSON(w, http.StatusInternalServerError, moveResponse{
+		s.writeJSON(w, http.StatusInternalServerError, moveResponse{
 			Success: false,
-			Error:   err.Error(),
+			Error:   &apiError{Code: errCodeEngineFailed, Message: err.Error()},
 			State:   payload,
+			Applied: appliedMoves,
 		})
 		return
 	}
 
 	s.mu.Lock()
 	payload := s.serializeState(s.game)
-	checkmate := payload.Checkmate
 	check := payload.Check
-	if checkmate {
-		s.flushEngineDataLocked()
+	if payload.GameOver {
+		s.finishGameLocked()
 	}
+	s.startPonderLocked()
+	s.armMoveTimeoutLocked()
 	s.mu.Unlock()
 	resp := moveResponse{
-		Success: true,
-		State:   payload,
+		Success:  true,
+		State:    payload,
+		GameOver: payload.GameOver,
+		Reason:   payload.Reason,
 	}
 	var notes []string
 	if len(responses) > 0 {
 		notes = append(notes, responses...)
 	}
-	if checkmate {
-		notes = append(notes, "Checkmate")
+	if payload.Winner != "" {
 		resp.Winner = payload.Winner
-	} else if check {
+	} else if check && !payload.GameOver {
 		notes = append(notes, "Check")
 	}
 	if len(notes) > 0 {
 		resp.Message = strings.Join(notes, " / ")
 	}
 
-	writeJSON(w, http.StatusOK, resp)
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// handleMoves applies a sequence of moves for whichever side is to move, without engine
+// replies in between, so a human-vs-human transcript can be replayed in one request.
+func (s *Server) handleMoves(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req movesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, errCodeBadJSON, "invalid JSON body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.auto.active {
+		s.writeError(w, http.StatusConflict, errCodeAutoRunning, "auto play is running")
+		return
+	}
+
+	resp := movesResponse{Success: true, Results: []moveResult{}}
+	for idx, text := range req.Moves {
+		result := moveResult{Move: text}
+		if s.resultLocked().Over {
+			result.Error = &apiError{Code: errCodeGameOver, Message: "game is over"}
+		} else if mv, err := game.ParseMove(text); err != nil {
+			result.Error = &apiError{Code: errCodeBadRequest, Message: err.Error()}
+		} else if legal, applied := game.TryApplyMove(s.game, mv); !legal {
+			s.metrics.illegalMoves++
+			result.Error = &apiError{Code: errCodeIllegalMove, Message: "illegal move"}
+		} else {
+			movingPlayer := s.game.Turn
+			s.game = applied
+			s.game.Turn = s.game.Turn.Opponent()
+			s.recordMove(movingPlayer, mv, s.makeBoardPayload(s.game))
+			s.engineEpoch++
+			result.Success = true
+		}
+		resp.Results = append(resp.Results, result)
+		if !result.Success {
+			resp.Success = false
+			resp.FailedIndex = &idx
+			break
+		}
+	}
+
+	resp.State = s.serializeState(s.game)
+	if resp.State.GameOver {
+		s.finishGameLocked()
+	}
+	s.armMoveTimeoutLocked()
+	status := http.StatusOK
+	if !resp.Success {
+		status = http.StatusBadRequest
+	}
+	s.writeJSON(w, status, resp)
+}
+
+// handleStep plays exactly one engine move for the side to move, so engine-vs-engine games
+// can be followed one ply at a time without auto play.
+func (s *Server) handleStep(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.auto.active:
+		s.writeError(w, http.StatusConflict, errCodeAutoRunning, "auto play is running")
+		return
+	case s.resultLocked().Over:
+		s.writeError(w, http.StatusConflict, errCodeGameOver, "game is over")
+		return
+	case s.engines[s.game.Turn] == nil:
+		s.writeError(w, http.StatusConflict, errCodeHumanToMove, "a human is to move")
+		return
+	}
+
+	message, moved, err := s.advanceEngineMoveLocked(false)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, errCodeEngineFailed, err.Error())
+		return
+	}
+	if !moved {
+		s.writeError(w, http.StatusConflict, errCodeBadRequest, "the game changed while the engine was thinking")
+		return
+	}
+	payload := s.serializeState(s.game)
+	s.startPonderLocked()
+	s.armMoveTimeoutLocked()
+	s.writeJSON(w, http.StatusOK, moveResponse{
+		Success:  true,
+		State:    payload,
+		Message:  message,
+		Winner:   payload.Winner,
+		GameOver: payload.GameOver,
+		Reason:   payload.Reason,
+	})
 }
 
 func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	// The body is optional; an empty one resets to the standard position.
+	var req resetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.writeError(w, http.StatusBadRequest, errCodeBadJSON, "invalid JSON body")
+		return
+	}
+
+	modes := engineModes(req.Bottom, req.Top)
+	if err := validateEngineModes(modes); err != nil {
+		s.writeError(w, http.StatusBadRequest, errCodeUnknownEngine, err.Error())
 		return
 	}
 
+	// Engines change under the same lock as the reset so a stale engine cannot move first.
 	s.mu.Lock()
+	if err := s.applyEngineModesLocked(modes, req.Seed); err != nil {
+		s.mu.Unlock()
+		s.writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	s.resetGameLocked(req.Shuffled, req.Seed)
+	s.armMoveTimeoutLocked()
+	payload := s.serializeState(s.game)
+	s.mu.Unlock()
+
+	s.writeJSON(w, http.StatusOK, payload)
+}
+
+// resetGameLocked stops auto play and starts a new game with the current engines. A non-zero
+// seed is reported in the state and also fixes the shuffled opening.
+func (s *Server) resetGameLocked(shuffled bool, seed int64) {
 	s.stopAutoPlayLocked()
 	s.flushEngineDataLocked()
+	s.stopPonderLocked()
 	s.game = game.NewGame()
+	s.seed = seed
+	if shuffled {
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		s.game = game.NewShuffledGame(seed)
+	}
 	s.history = nil
+	s.openingRecorded = false
+	s.evalHistory = nil
+	s.timeout.forfeited = false
+	s.engineEpoch++
+	s.positions = []string{game.PositionKey(s.game)}
 	s.initial = s.makeBoardPayload(s.game)
+}
+
+func engineModes(bottom, top string) map[game.Player]string {
+	return map[game.Player]string{
+		game.Bottom: strings.TrimSpace(bottom),
+		game.Top:    strings.TrimSpace(top),
+	}
+}
+
+// validateEngineModes checks every side first so a bad request leaves the setup untouched.
+func validateEngineModes(modes map[game.Player]string) error {
+	for _, mode := range modes {
+		if mode == "" || mode == engineHuman {
+			continue
+		}
+		if _, err := lookupEngineMode(mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEngineModesLocked sets each side whose mode is non-empty. A non-zero seed rebuilds
+// every engine, keeping the current mode where none is given, from seeds derived from it so
+// the game can be replayed. Both engines are built before either is installed, so an error
+// leaves both sides unchanged.
+func (s *Server) applyEngineModesLocked(modes map[game.Player]string, seed int64) error {
+	choices := make(map[game.Player]engineChoice, 2)
+	for _, player := range []game.Player{game.Bottom, game.Top} {
+		mode := modes[player]
+		if seed != 0 && mode == "" {
+			mode = s.modes[player]
+		}
+		if mode == "" {
+			continue
+		}
+		engineSeed := time.Now().UnixNano()
+		if seed != 0 {
+			engineSeed = seed*2 + int64(player)
+		}
+		choice, err := s.prepareEngine(player, mode, engineSeed)
+		if err != nil {
+			return err
+		}
+		choices[player] = choice
+	}
+	for _, player := range []game.Player{game.Bottom, game.Top} {
+		if choice, ok := choices[player]; ok {
+			s.installEngineLocked(player, choice)
+		}
+	}
+	return nil
+}
+
+type newGameRequest struct {
+	// Bottom and Top are "human" or an engine mode; empty keeps the current setting.
+	Bottom   string `json:"bottom"`
+	Top      string `json:"top"`
+	Shuffled bool   `json:"shuffled"`
+	// Seed, when non-zero, seeds every engine and the shuffled opening so the game can be
+	// replayed with the same seed.
+	Seed int64 `json:"seed"`
+}
+
+// handleNewGame sets up both sides and starts a new game. When only Bottom is an engine it
+// opens immediately, so a human playing Top receives the first move in the response.
+func (s *Server) handleNewGame(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req newGameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, errCodeBadJSON, "invalid JSON body")
+		return
+	}
+	modes := engineModes(req.Bottom, req.Top)
+	if err := validateEngineModes(modes); err != nil {
+		s.writeError(w, http.StatusBadRequest, errCodeUnknownEngine, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	if err := s.applyEngineModesLocked(modes, req.Seed); err != nil {
+		s.mu.Unlock()
+		s.writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	s.resetGameLocked(req.Shuffled, req.Seed)
+	engineOpens := s.engines[game.Bottom] != nil && s.engines[game.Top] == nil
+	s.mu.Unlock()
+
+	if engineOpens {
+		if _, err := s.respondWithEngines(); err != nil {
+			s.writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+			return
+		}
+	}
+
+	s.mu.Lock()
+	if engineOpens {
+		s.startPonderLocked()
+	}
+	s.armMoveTimeoutLocked()
 	payload := s.serializeState(s.game)
 	s.mu.Unlock()
+	s.writeJSON(w, http.StatusOK, payload)
+}
 
-	writeJSON(w, http.StatusOK, payload)
+type resetRequest struct {
+	Shuffled bool `json:"shuffled"`
+	// Bottom and Top optionally replace the engines as part of the reset.
+	Bottom string `json:"bottom,omitempty"`
+	Top    string `json:"top,omitempty"`
+	// Seed works as in newGameRequest.
+	Seed int64 `json:"seed,omitempty"`
 }
 
 type engineResponse struct {
@@ -336,7 +999,8 @@ type autoRequest struct {
 }
 
 type autoResponse struct {
-	Running bool `json:"running"`
+	Running    bool `json:"running"`
+	IntervalMS int  `json:"interval_ms,omitempty"`
 }
 
 type trainingRequest struct {
@@ -348,6 +1012,41 @@ type trainingRequest struct {
 	IntervalMS   int    `json:"interval_ms"`
 	MaxMoves     int    `json:"max_moves"`
 	BatchSize    int    `json:"batch_size"`
+	// NoProgressWindow adjudicates a draw once that many moves made no progress (see
+	// game.NoProgress); 0 disables it.
+	NoProgressWindow int `json:"no_progress_window"`
+	// ShuffledOpenings starts each game from a shuffled back rank for variety.
+	ShuffledOpenings bool `json:"shuffled_openings"`
+	// BottomIterations/TopIterations override the MCTS iteration count; 0 keeps the default.
+	BottomIterations int `json:"bottom_iterations"`
+	TopIterations    int `json:"top_iterations"`
+	// RolloutPolicy is "uniform" (default) or "captures" for MCTS playouts.
+	RolloutPolicy string `json:"rollout_policy"`
+	// Selection is "most-visits" (default), "best-winrate" or "robust" for the move MCTS plays.
+	Selection string `json:"selection"`
+	// Contempt makes MCTS treat undecided playouts as slightly lost; 0 scores them as draws.
+	Contempt int `json:"contempt"`
+	// TDAlpha, TDGamma and TDExploration tune TD-UCB engines; 0 keeps the default.
+	TDAlpha       float64 `json:"td_alpha"`
+	TDGamma       float64 `json:"td_gamma"`
+	TDExploration float64 `json:"td_exploration"`
+	// TDDecayAlpha lowers the TD learning rate as states are revisited.
+	TDDecayAlpha bool `json:"td_decay_alpha"`
+	// Seed derives the seed of every game; 0 picks one from the clock.
+	Seed int64 `json:"seed"`
+	// BranchGame and BranchPly select the position for the "branch" action: the position
+	// of game BranchGame of the last run after BranchPly moves.
+	BranchGame int `json:"branch_game"`
+	BranchPly  int `json:"branch_ply"`
+	// GameSeed selects the game the "replay" action plays again: the seed reported in the
+	// game's status.
+	GameSeed int64 `json:"game_seed"`
+}
+
+// trainingReplayResponse lists the moves of a replayed training game.
+type trainingReplayResponse struct {
+	Seed  int64    `json:"seed"`
+	Moves []string `json:"moves"`
 }
 
 type trainingStatePayload struct {
@@ -364,34 +1063,66 @@ type trainingGameDetailPayload struct {
 }
 
 type trainingConfigPayload struct {
-	Total        int    `json:"total"`
-	Parallel     int    `json:"parallel"`
-	BottomEngine string `json:"bottomEngine"`
-	TopEngine    string `json:"topEngine"`
-	IntervalMS   int    `json:"intervalMs"`
-	MaxMoves     int    `json:"maxMoves"`
-	BatchSize    int    `json:"batchSize"`
+	Total            int     `json:"total"`
+	Parallel         int     `json:"parallel"`
+	BottomEngine     string  `json:"bottomEngine"`
+	TopEngine        string  `json:"topEngine"`
+	IntervalMS       int     `json:"intervalMs"`
+	MaxMoves         int     `json:"maxMoves"`
+	BatchSize        int     `json:"batchSize"`
+	NoProgressWindow int     `json:"noProgressWindow,omitempty"`
+	ShuffledOpenings bool    `json:"shuffledOpenings"`
+	BottomIterations int     `json:"bottomIterations,omitempty"`
+	TopIterations    int     `json:"topIterations,omitempty"`
+	RolloutPolicy    string  `json:"rolloutPolicy,omitempty"`
+	Selection        string  `json:"selection,omitempty"`
+	Contempt         int     `json:"contempt,omitempty"`
+	TDAlpha          float64 `json:"tdAlpha,omitempty"`
+	TDGamma          float64 `json:"tdGamma,omitempty"`
+	TDExploration    float64 `json:"tdExploration,omitempty"`
+	TDDecayAlpha     bool    `json:"tdDecayAlpha,omitempty"`
+	Seed             int64   `json:"seed"`
+	StartSFEN        string  `json:"startSfen,omitempty"`
 }
 
 type trainingSummary struct {
-	Total      int  `json:"total"`
-	Completed  int  `json:"completed"`
-	BottomWins int  `json:"bottomWins"`
-	TopWins    int  `json:"topWins"`
-	Draws      int  `json:"draws"`
-	Errors     int  `json:"errors"`
-	Aborted    bool `json:"aborted"`
+	Total       int  `json:"total"`
+	Completed   int  `json:"completed"`
+	BottomWins  int  `json:"bottomWins"`
+	TopWins     int  `json:"topWins"`
+	Draws       int  `json:"draws"`
+	MoveLimits  int  `json:"moveLimits"`
+	Repetitions int  `json:"repetitions"`
+	Errors      int  `json:"errors"`
+	Aborted     bool `json:"aborted"`
+	// Reasons counts completed games by trainingGameStatus.Reason.
+	Reasons map[string]int `json:"reasons"`
+	// GamesPerSecond and ETASeconds are computed by Snapshot from StartedAt; ETASeconds is
+	// only set while training runs.
+	StartedAt      time.Time `json:"startedAt,omitempty"`
+	GamesPerSecond float64   `json:"gamesPerSecond"`
+	ETASeconds     float64   `json:"etaSeconds,omitempty"`
 }
 
 type trainingGameStatus struct {
-	ID       int    `json:"id"`
-	Moves    int    `json:"moves"`
-	Winner   string `json:"winner,omitempty"`
-	Result   string `json:"result,omitempty"`
+	ID     int    `json:"id"`
+	Moves  int    `json:"moves"`
+	Winner string `json:"winner,omitempty"`
+	Result string `json:"result,omitempty"`
+	// Reason tells how a completed game ended: "checkmate", "no-moves", "repetition",
+	// "no-progress", "move-limit" or "agreement".
+	Reason   string `json:"reason,omitempty"`
 	State    string `json:"state"`
 	LastMove string `json:"lastMove,omitempty"`
 	Turn     string `json:"turn,omitempty"`
 	Error    string `json:"error,omitempty"`
+	// *ThinkMS is the total NextMove time of each side; *AvgMoveMS divides it by its moves.
+	BottomThinkMS   float64 `json:"bottomThinkMs"`
+	TopThinkMS      float64 `json:"topThinkMs"`
+	BottomAvgMoveMS float64 `json:"bottomAvgMoveMs"`
+	TopAvgMoveMS    float64 `json:"topAvgMoveMs"`
+	// Seed recreates the game with replayGame.
+	Seed int64 `json:"seed"`
 }
 
 type trainingHistoryEntry struct {
@@ -405,44 +1136,49 @@ func (s *Server) handleEngine(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		writeJSON(w, http.StatusOK, s.engineStatus())
+		s.writeJSON(w, http.StatusOK, s.engineStatus())
 		return
 	case http.MethodPost:
 		var payload engineRequest
 		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			s.writeError(w, http.StatusBadRequest, errCodeBadJSON, "invalid JSON body")
 			return
 		}
 		player := game.Top
 		if payload.Player != "" {
 			mapped, ok := parsePlayer(payload.Player)
 			if !ok {
-				http.Error(w, "unknown player for engine", http.StatusBadRequest)
+				s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "unknown player for engine")
 				return
 			}
 			player = mapped
 		}
 		if err := s.setEngine(player, payload.Engine); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			code := errCodeBadRequest
+			if errors.Is(err, errUnknownEngine) {
+				code = errCodeUnknownEngine
+			}
+			s.writeError(w, http.StatusBadRequest, code, err.Error())
 			return
 		}
-		writeJSON(w, http.StatusOK, s.engineStatus())
+		s.armMoveTimeoutLocked()
+		s.writeJSON(w, http.StatusOK, s.engineStatus())
 		return
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
 		return
 	}
 }
 
 func (s *Server) handleEngineProfile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	playerParam := strings.TrimSpace(r.URL.Query().Get("player"))
 	player, ok := parsePlayer(playerParam)
 	if !ok {
-		http.Error(w, "unknown player for profile", http.StatusBadRequest)
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "unknown player for profile")
 		return
 	}
 
@@ -451,19 +1187,19 @@ func (s *Server) handleEngineProfile(w http.ResponseWriter, r *http.Request) {
 	s.mu.Unlock()
 
 	if eng == nil {
-		http.Error(w, "no engine configured for player", http.StatusBadRequest)
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "no engine configured for player")
 		return
 	}
 	profilable, ok := eng.(tdProfilableEngine)
 	if !ok {
-		http.Error(w, "selected engine has no TD profiling data", http.StatusBadRequest)
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "selected engine has no TD profiling data")
 		return
 	}
 	profile := profilable.ProfileSnapshot()
 	if shouldResetProfile(r.URL.Query().Get("reset")) {
 		profilable.ResetProfile()
 	}
-	writeJSON(w, http.StatusOK, engineProfileResponse{
+	s.writeJSON(w, http.StatusOK, engineProfileResponse{
 		Player:  playerKey(player),
 		Profile: profile,
 	})
@@ -471,13 +1207,13 @@ func (s *Server) handleEngineProfile(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleAuto(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
 		return
 	}
 
 	var payload autoRequest
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		s.writeError(w, http.StatusBadRequest, errCodeBadJSON, "invalid JSON body")
 		return
 	}
 
@@ -485,27 +1221,36 @@ func (s *Server) handleAuto(w http.ResponseWriter, r *http.Request) {
 	defer s.mu.Unlock()
 
 	if payload.Running {
+		if err := validateIntervalMS(payload.IntervalMS); err != nil {
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+			return
+		}
 		interval := time.Duration(payload.IntervalMS) * time.Millisecond
 		if err := s.startAutoPlayLocked(interval); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
 			return
 		}
 	} else {
 		s.stopAutoPlayLocked()
 	}
 
-	writeJSON(w, http.StatusOK, autoResponse{Running: s.auto.active})
+	s.armMoveTimeoutLocked()
+	resp := autoResponse{Running: s.auto.active}
+	if s.auto.active {
+		resp.IntervalMS = int(s.auto.interval / time.Millisecond)
+	}
+	s.writeJSON(w, http.StatusOK, resp)
 }
 
 func (s *Server) handleTraining(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		writeJSON(w, http.StatusOK, s.training.Snapshot())
+		s.writeJSON(w, http.StatusOK, s.training.Snapshot())
 		return
 	case http.MethodPost:
 		var payload trainingRequest
 		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			s.writeError(w, http.StatusBadRequest, errCodeBadJSON, "invalid JSON body")
 			return
 		}
 		action := strings.ToLower(strings.TrimSpace(payload.Action))
@@ -513,55 +1258,90 @@ func (s *Server) handleTraining(w http.ResponseWriter, r *http.Request) {
 		case "start":
 			cfg, err := s.buildTrainingConfig(payload)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+				return
+			}
+			if err := s.training.Start(cfg); err != nil {
+				s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+				return
+			}
+			s.writeJSON(w, http.StatusOK, s.training.Snapshot())
+			return
+		case "branch":
+			cfg, err := s.buildTrainingConfig(payload)
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
 				return
 			}
+			start, err := s.training.BranchPosition(payload.BranchGame, payload.BranchPly)
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+				return
+			}
+			cfg.Start = &start
 			if err := s.training.Start(cfg); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+				return
+			}
+			s.writeJSON(w, http.StatusOK, s.training.Snapshot())
+			return
+		case "replay":
+			// A replay plays one game; the other fields must match the run's request.
+			if payload.Games <= 0 {
+				payload.Games = 1
+			}
+			cfg, err := s.buildTrainingConfig(payload)
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
 				return
 			}
-			writeJSON(w, http.StatusOK, s.training.Snapshot())
+			moves, err := replayGame(payload.GameSeed, cfg)
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
+				return
+			}
+			s.writeJSON(w, http.StatusOK, trainingReplayResponse{Seed: payload.GameSeed, Moves: moves})
 			return
 		case "stop":
 			if err := s.training.Stop(); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				s.writeError(w, http.StatusBadRequest, errCodeBadRequest, err.Error())
 				return
 			}
-			writeJSON(w, http.StatusOK, s.training.Snapshot())
+			s.writeJSON(w, http.StatusOK, s.training.Snapshot())
 			return
 		default:
-			http.Error(w, "unknown action for training", http.StatusBadRequest)
+			s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "unknown action for training")
 			return
 		}
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
 		return
 	}
 }
 
 func (s *Server) handleTrainingGame(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
 		return
 	}
 	idStr := strings.TrimSpace(r.URL.Query().Get("id"))
 	if idStr == "" {
-		http.Error(w, "query 'id' is required", http.StatusBadRequest)
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "query 'id' is required")
 		return
 	}
 	id, err := strconv.Atoi(idStr)
 	if err != nil || id <= 0 {
-		http.Error(w, "invalid training game id", http.StatusBadRequest)
+		s.writeError(w, http.StatusBadRequest, errCodeBadRequest, "invalid training game id")
 		return
 	}
 	status, ok := s.training.GameStatus(id)
 	if !ok {
-		http.Error(w, "training game not found", http.StatusNotFound)
+		s.writeError(w, http.StatusNotFound, errCodeNotFound, "training game not found")
 		return
 	}
 	state, ok := s.training.GameState(id)
 	if !ok {
-		http.Error(w, "training game snapshot not available", http.StatusNotFound)
+		s.writeError(w, http.StatusNotFound, errCodeNotFound, "training game snapshot not available")
 		return
 	}
 	history := s.training.GameHistory(id)
@@ -570,34 +1350,72 @@ func (s *Server) handleTrainingGame(w http.ResponseWriter, r *http.Request) {
 		Snapshot: s.makeBoardPayload(state),
 		History:  history,
 	}
-	writeJSON(w, http.StatusOK, payload)
+	s.writeJSON(w, http.StatusOK, payload)
+}
+
+func (s *Server) handleScoreboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, s.scoreboard.Snapshot())
+}
+
+func (s *Server) handleEngines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, enginesResponse{Engines: listEngineModes()})
 }
 
 func (s *Server) buildTrainingConfig(req trainingRequest) (trainingConfig, error) {
 	cfg := trainingConfig{
-		Total:        req.Games,
-		Parallel:     req.Parallel,
-		BottomEngine: strings.TrimSpace(req.EngineBottom),
-		TopEngine:    strings.TrimSpace(req.EngineTop),
-		IntervalMS:   req.IntervalMS,
-		MaxMoves:     req.MaxMoves,
-		BatchSize:    req.BatchSize,
+		Total:            req.Games,
+		Parallel:         req.Parallel,
+		BottomEngine:     strings.TrimSpace(req.EngineBottom),
+		TopEngine:        strings.TrimSpace(req.EngineTop),
+		IntervalMS:       req.IntervalMS,
+		MaxMoves:         req.MaxMoves,
+		NoProgressWindow: req.NoProgressWindow,
+		BatchSize:        req.BatchSize,
+		ShuffledOpenings: req.ShuffledOpenings,
+		BottomIterations: req.BottomIterations,
+		TopIterations:    req.TopIterations,
+		RolloutPolicy:    strings.TrimSpace(req.RolloutPolicy),
+		Selection:        strings.TrimSpace(req.Selection),
+		Contempt:         req.Contempt,
+		TD:               game.TDUCBParams{Alpha: req.TDAlpha, Gamma: req.TDGamma, Exploration: req.TDExploration, DecayAlpha: req.TDDecayAlpha},
+		Seed:             req.Seed,
+		Evaluation:       s.evaluation,
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
 	}
 	if cfg.Total <= 0 {
 		return trainingConfig{}, errors.New("games must be greater than zero")
 	}
+	if err := validateIntervalMS(cfg.IntervalMS); err != nil {
+		return trainingConfig{}, err
+	}
 	if cfg.Parallel <= 0 {
 		cfg.Parallel = 1
 	}
 	if cfg.Parallel > cfg.Total {
 		cfg.Parallel = cfg.Total
 	}
+	if cfg.Parallel > s.maxParallel {
+		cfg.Parallel = s.maxParallel
+	}
 	if cfg.IntervalMS > 0 {
 		cfg.Interval = time.Duration(cfg.IntervalMS) * time.Millisecond
 	}
 	if cfg.MaxMoves <= 0 {
 		cfg.MaxMoves = defaultTrainingMaxMoves
 	}
+	if cfg.NoProgressWindow < 0 {
+		return trainingConfig{}, errors.New("no_progress_window must not be negative")
+	}
 	if cfg.BatchSize <= 0 || cfg.BatchSize > cfg.Total {
 		cfg.BatchSize = cfg.Total
 	}
@@ -607,9 +1425,56 @@ func (s *Server) buildTrainingConfig(req trainingRequest) (trainingConfig, error
 	if cfg.TopEngine == "" || cfg.TopEngine == engineHuman {
 		return trainingConfig{}, errors.New("top engine must be an AI engine")
 	}
+	if err := validateIterationsOverride("bottom_iterations", cfg.BottomEngine, cfg.BottomIterations); err != nil {
+		return trainingConfig{}, err
+	}
+	if err := validateIterationsOverride("top_iterations", cfg.TopEngine, cfg.TopIterations); err != nil {
+		return trainingConfig{}, err
+	}
+	if _, err := game.ParseRolloutPolicy(cfg.RolloutPolicy); err != nil {
+		return trainingConfig{}, err
+	}
+	if _, err := game.ParseSelectionCriterion(cfg.Selection); err != nil {
+		return trainingConfig{}, err
+	}
+	if cfg.Contempt < 0 {
+		return trainingConfig{}, errors.New("contempt must not be negative")
+	}
+	if err := cfg.TD.Validate(); err != nil {
+		return trainingConfig{}, err
+	}
 	return cfg, nil
 }
 
+// validateIterationsOverride accepts 0 (default) or 1..maxTrainingIterations for engines
+// that take an iterations parameter.
+func validateIterationsOverride(field, mode string, iterations int) error {
+	if iterations == 0 {
+		return nil
+	}
+	if iterations < 0 || iterations > maxTrainingIterations {
+		return fmt.Errorf("%s must be between 0 and %d", field, maxTrainingIterations)
+	}
+	spec, err := lookupEngineMode(mode)
+	if err != nil {
+		return err
+	}
+	for _, param := range spec.info.Params {
+		if param.Name == iterationsParam.Name {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not supported by engine %q", field, mode)
+}
+
+// validateIntervalMS rejects intervals outside [0, maxIntervalMS]; zero selects the default.
+func validateIntervalMS(ms int) error {
+	if ms < 0 || ms > maxIntervalMS {
+		return fmt.Errorf("interval_ms must be between 0 and %d", maxIntervalMS)
+	}
+	return nil
+}
+
 func (s *Server) moveFromRequest(state game.GameState, req moveRequest) (game.Move, error) {
 	if req.Drop != "" && req.From != "" {
 		return game.Move{}, errors.New("specify either 'from' or 'drop', not both")
@@ -617,6 +1482,9 @@ func (s *Server) moveFromRequest(state game.GameState, req moveRequest) (game.Mo
 	if req.Drop == "" && req.From == "" {
 		return game.Move{}, errors.New("'from' or 'drop' is required")
 	}
+	if req.Drop != "" && req.Promote != nil && *req.Promote {
+		return game.Move{}, errors.New("dropped pieces cannot promote")
+	}
 	if req.To == "" {
 		return game.Move{}, errors.New("'to' is required")
 	}
@@ -642,18 +1510,35 @@ func (s *Server) moveFromRequest(state game.GameState, req moveRequest) (game.Mo
 		return game.Move{}, err
 	}
 
-	return game.Move{From: &from, To: to, Promote: req.Promote}, nil
+	return game.Move{From: &from, To: to, Promote: req.Promote != nil && *req.Promote}, nil
 }
 
 func (s *Server) serializeState(state game.GameState) statePayload {
-	return statePayload{
+	result := s.resultOf(state)
+	payload := statePayload{
 		boardPayload: s.makeBoardPayload(state),
 		Engine:       s.modes[game.Top],
 		Engines:      map[string]string{"bottom": s.modes[game.Bottom], "top": s.modes[game.Top]},
 		AutoPlaying:  s.auto.active,
 		History:      append([]historyEntry(nil), s.history...),
 		Initial:      s.initial,
+		GameOver:     result.Over,
+		Reason:       result.Reason,
+		EvalHistory:  append([]int(nil), s.evalHistory...),
+		Ply:          len(s.history),
+		MoveNumber:   len(s.history)/2 + 1,
+		Droppable:    []string{},
+		PositionHash: game.PositionHash(state),
+		Phase:        game.GamePhase(state).String(),
+		Seed:         s.seed,
+	}
+	if result.Over && !result.Draw {
+		payload.Winner = playerKey(result.Winner)
+	}
+	for _, pt := range game.DroppablePieces(state, state.Turn) {
+		payload.Droppable = append(payload.Droppable, game.PieceTypeCode(pt))
 	}
+	return payload
 }
 
 func (s *Server) makeBoardPayload(state game.GameState) boardPayload {
@@ -677,6 +1562,10 @@ func (s *Server) makeBoardPayload(state game.GameState) boardPayload {
 			if p.Present {
 				cell.Kind = game.PieceTypeCode(p.Kind)
 				cell.Owner = playerKey(p.Owner)
+				if s.moveHints && p.Owner == state.Turn {
+					movable := len(game.GenerateLegalMovesFrom(state, state.Turn, game.Coord{X: x, Y: y})) > 0
+					cell.HasLegalMove = &movable
+				}
 			}
 			payload.Board[y][x] = cell
 		}
@@ -708,13 +1597,6 @@ func playerKey(p game.Player) string {
 	return "top"
 }
 
-func playerLabel(p game.Player) string {
-	if p == game.Bottom {
-		return "先手"
-	}
-	return "後手"
-}
-
 func parsePlayer(value string) (game.Player, bool) {
 	switch strings.ToLower(value) {
 	case "", "top":
@@ -762,7 +1644,7 @@ func (s *Server) respondWithEngines() ([]string, error) {
 // and remains held on return. The method temporarily releases the lock while asking the
 // engine for a move so slow engines do not block other requests.
 func (s *Server) advanceEngineMoveLocked(allowAuto bool) (string, bool, error) {
-	if mate, _ := game.CheckmateStatus(s.game); mate {
+	if s.resultLocked().Over {
 		return "", false, nil
 	}
 	engine := s.engines[s.game.Turn]
@@ -770,24 +1652,56 @@ func (s *Server) advanceEngineMoveLocked(allowAuto bool) (string, bool, error) {
 		return "", false, nil
 	}
 	currentPlayer := s.game.Turn
+	epoch := s.engineEpoch
 	stateCopy := cloneGameState(s.game)
+	mv, pondered := s.takePonderLocked(currentPlayer, engine, stateCopy)
 	s.mu.Unlock()
 
-	mv, err := engine.NextMove(stateCopy)
+	var err error
+	var assessment *engineAssessment
+	if !pondered {
+		mv, err = engine.NextMove(stateCopy)
+		if reporter, ok := engine.(game.SearchReporter); ok && err == nil {
+			info := reporter.LastSearch()
+			assessment = &engineAssessment{Depth: info.Depth, Eval: info.Score}
+		}
+	}
+	if !allowAuto && s.engineMoveDelay > 0 {
+		time.Sleep(s.engineMoveDelay)
+	}
 	s.mu.Lock()
+	if (!allowAuto && s.auto.active) || s.game.Turn != currentPlayer || s.engineEpoch != epoch {
+		return "", false, nil
+	}
 	if err != nil {
-		return "", false, errors.New("failed to generate move for " + playerLabel(currentPlayer))
+		return "", false, errors.New("failed to generate move for " + s.labels.PlayerName(currentPlayer))
 	}
-	if (!allowAuto && s.auto.active) || s.game.Turn != currentPlayer || s.engines[currentPlayer] != engine {
-		return "", false, nil
+	next, err := applyEngineMove(s.game, mv)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", s.labels.PlayerName(currentPlayer), err)
 	}
-	game.ApplyMove(&s.game, mv)
-	s.game.Turn = s.game.Turn.Opponent()
+	s.game = next
 	s.recordMove(currentPlayer, mv, s.makeBoardPayload(s.game))
-	if mate, _ := game.CheckmateStatus(s.game); mate {
-		s.flushEngineDataLocked()
+	s.history[len(s.history)-1].Assessment = assessment
+	s.metrics.engineMoves[s.modes[currentPlayer]]++
+	if s.resultLocked().Over {
+		s.finishGameLocked()
 	}
-	return playerLabel(currentPlayer) + ": " + game.FormatMove(mv), true, nil
+	return s.labels.PlayerName(currentPlayer) + ": " + game.FormatMove(mv), true, nil
+}
+
+// applyEngineMove plays an engine's move and passes the turn, refusing illegal moves so a
+// faulty engine cannot corrupt the board.
+func applyEngineMove(state game.GameState, mv game.Move) (game.GameState, error) {
+	if mv.From == nil && mv.Drop == nil {
+		return state, errors.New("engine produced an empty move")
+	}
+	legal, next := game.TryApplyMove(state, mv)
+	if !legal {
+		return state, fmt.Errorf("engine produced illegal move %s", game.FormatMove(mv))
+	}
+	next.Turn = next.Turn.Opponent()
+	return next, nil
 }
 
 func cloneGameState(state game.GameState) game.GameState {
@@ -805,12 +1719,31 @@ func cloneGameState(state game.GameState) game.GameState {
 	}
 	return clone
 }
+
+// resultLocked reports whether the current game has ended by checkmate, repetition or a
+// human running out of time.
+func (s *Server) resultLocked() game.GameResult {
+	return s.resultOf(s.game)
+}
+
+func (s *Server) resultOf(state game.GameState) game.GameResult {
+	if s.timeout.forfeited {
+		return game.GameResult{Over: true, Winner: s.timeout.loser.Opponent(), Reason: reasonTimeout}
+	}
+	return game.DetermineResult(state, s.positions)
+}
+
 func (s *Server) recordMove(player game.Player, mv game.Move, snapshot boardPayload) {
 	s.history = append(s.history, historyEntry{
 		Player:   playerKey(player),
 		Move:     game.FormatMove(mv),
 		Snapshot: snapshot,
 	})
+	s.positions = append(s.positions, game.PositionKey(s.game))
+	s.metrics.movesPlayed++
+	if s.evalHistoryEnabled {
+		s.evalHistory = append(s.evalHistory, s.evaluation.Evaluate(s.game, evalHistoryDepth))
+	}
 }
 
 type tdProfilableEngine interface {
@@ -822,28 +1755,80 @@ type savableEngine interface {
 	SaveIfNeeded() error
 }
 
-func saveEngineData(eng game.Engine) {
+func saveEngineData(logger *slog.Logger, eng game.Engine) {
 	if eng == nil {
 		return
 	}
 	if saver, ok := eng.(savableEngine); ok {
 		if err := saver.SaveIfNeeded(); err != nil {
-			log.Printf("failed to save engine data: %v", err)
+			logger.Warn("failed to save engine data", "err", err)
 		}
 	}
 }
 
+// finishGameLocked saves engine knowledge and counts the opening once the current game is over.
+func (s *Server) finishGameLocked() {
+	s.flushEngineDataLocked()
+	if s.openingRecorded || len(s.history) == 0 {
+		return
+	}
+	s.openingRecorded = true
+	result := s.resultLocked()
+	winner := ""
+	if !result.Draw {
+		winner = playerKey(result.Winner)
+	}
+	s.openings.recordGame(s.history[0].Move, s.history[0].Player, winner)
+	if err := s.openings.SaveIfNeeded(); err != nil {
+		s.logger.Warn("failed to save opening statistics", "err", err)
+	}
+}
+
 func (s *Server) flushEngineDataLocked() {
 	for _, eng := range s.engines {
-		saveEngineData(eng)
+		saveEngineData(s.logger, eng)
 	}
 }
 
-func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+type errorCode string
+
+const (
+	errCodeBadJSON          errorCode = "bad-json"
+	errCodeBadRequest       errorCode = "bad-request"
+	errCodeIllegalMove      errorCode = "illegal-move"
+	errCodeUnknownEngine    errorCode = "unknown-engine"
+	errCodeAutoRunning      errorCode = "auto-running"
+	errCodeNotFound         errorCode = "not-found"
+	errCodeMethodNotAllowed errorCode = "method-not-allowed"
+	errCodeNotReady         errorCode = "not-ready"
+	errCodeInternal         errorCode = "internal"
+	errCodeGameOver         errorCode = "game-over"
+	errCodeEngineFailed     errorCode = "engine-failed"
+	errCodeForbidden        errorCode = "forbidden"
+	errCodeHumanToMove      errorCode = "human-to-move"
+)
+
+var errUnknownEngine = errors.New("unknown engine requested")
+
+type apiError struct {
+	Code    errorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+// writeError emits the shared {"error":{"code":...,"message":...}} shape.
+func (s *Server) writeError(w http.ResponseWriter, status int, code errorCode, message string) {
+	s.writeJSON(w, status, errorResponse{Error: apiError{Code: code, Message: message}})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(v); err != nil {
-		log.Printf("failed to write JSON response: %v", err)
+		s.logger.Debug("failed to write JSON response", "err", err)
 	}
 }
 
@@ -858,51 +1843,125 @@ func (s *Server) engineStatus() engineResponse {
 }
 
 func (s *Server) setEngine(player game.Player, kind string) error {
+	return s.setEngineWithSeed(player, kind, time.Now().UnixNano())
+}
+
+func (s *Server) setEngineWithSeed(player game.Player, kind string, seed int64) error {
+	choice, err := s.prepareEngine(player, kind, seed)
+	if err != nil {
+		return err
+	}
+	s.installEngineLocked(player, choice)
+	return nil
+}
+
+// engineChoice is a built engine waiting to be installed; eng is nil for a human side.
+type engineChoice struct {
+	mode string
+	eng  game.Engine
+}
+
+// prepareEngine builds the engine of kind for player without touching the current one, so
+// a failure leaves the session as it was.
+func (s *Server) prepareEngine(player game.Player, kind string, seed int64) (engineChoice, error) {
 	mode := strings.TrimSpace(kind)
 	if mode == "" || mode == engineHuman {
-		saveEngineData(s.engines[player])
-		s.engines[player] = nil
-		s.modes[player] = engineHuman
-		return nil
+		return engineChoice{mode: engineHuman}, nil
 	}
-	saveEngineData(s.engines[player])
-	eng, err := s.buildEngine(mode, player)
+	// Knowledge files can be large, so they are loaded in the background instead of under s.mu.
+	params := defaultEngineParams(player, seed)
+	params.DeferLoad = true
+	eng, err := s.buildEngine(mode, params)
 	if err != nil {
-		return err
+		return engineChoice{}, err
+	}
+	return engineChoice{mode: mode, eng: eng}, nil
+}
+
+// installEngineLocked replaces player's engine with choice, saving the outgoing engine's
+// knowledge before the new one starts loading it.
+func (s *Server) installEngineLocked(player game.Player, choice engineChoice) {
+	s.stopPonderLocked()
+	s.engineEpoch++
+	s.cancelWarmupLocked(player)
+	saveEngineData(s.logger, s.engines[player])
+	s.engines[player] = choice.eng
+	s.modes[player] = choice.mode
+	if choice.eng != nil {
+		s.warmEngineLocked(player, choice.eng)
+	}
+}
+
+// warmEngineLocked runs the Warmup of player's deferred engine in the background. The
+// engine plays without its stored knowledge until the load finishes; replacing the engine
+// or shutting down cancels the load.
+func (s *Server) warmEngineLocked(player game.Player, eng game.Engine) {
+	warmer, ok := eng.(game.Warmer)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.warmCancel[player] = cancel
+	go func() {
+		defer cancel()
+		if err := warmer.Warmup(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			s.logger.Warn("failed to load engine knowledge", "player", playerKey(player), "err", err)
+		}
+	}()
+}
+
+func (s *Server) cancelWarmupLocked(player game.Player) {
+	if cancel := s.warmCancel[player]; cancel != nil {
+		cancel()
+		s.warmCancel[player] = nil
+	}
+}
+
+// buildEngine builds a persistent engine, filling params.StoragePath from the mode's data file.
+func (s *Server) buildEngine(mode string, params EngineParams) (game.Engine, error) {
+	spec, err := lookupEngineMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	params.Evaluation = s.evaluation
+	params.Logger = s.logger
+	if spec.dataFile != "" {
+		params.StoragePath = s.engineDataPath(fmt.Sprintf(spec.dataFile, playerKey(params.Player)))
+		params.CompressionLevel = s.compressionLevel
+		params.KnowledgeFormat = s.knowledgeFormat
+		params.MaxStates = s.maxKnowledgeStates
+	}
+	return spec.factory(params)
+}
+
+// ValidateNamespace reports whether name can prefix the engine data files in DataDir:
+// a path separator would place them elsewhere.
+func ValidateNamespace(name string) error {
+	if strings.IndexFunc(name, isPathSeparator) >= 0 {
+		return fmt.Errorf("namespace %q must not contain path separators", name)
 	}
-	s.engines[player] = eng
-	s.modes[player] = mode
 	return nil
 }
 
-func (s *Server) buildEngine(mode string, player game.Player) (game.Engine, error) {
-	switch mode {
-	case engineMCTS:
-		path := filepath.Join(s.dataDir, fmt.Sprintf("mcts_%s.json", playerKey(player)))
-		return game.NewPersistentMCTSEngine(800, time.Now().UnixNano(), path), nil
-	case engineTDUCB:
-		path := filepath.Join(s.dataDir, fmt.Sprintf("td_ucb_%s.gz", playerKey(player)))
-		return game.NewPersistentTDUCBEngine(time.Now().UnixNano(), path), nil
-	default:
-		return newEngineForMode(mode)
-	}
-}
-
-func newEngineForMode(mode string) (game.Engine, error) {
-	switch mode {
-	case engineRandom:
-		return game.NewRandomEngine(time.Now().UnixNano()), nil
-	case engineAlphaBeta:
-		return game.NewAlphaBetaEngine(3), nil
-	case engineAlphaBetaMobility:
-		return game.NewMobilityAlphaBetaEngine(3), nil
-	case engineTDUCB:
-		return game.NewTDUCBEngine(time.Now().UnixNano()), nil
-	case engineMCTS:
-		return game.NewMCTSEngine(800, time.Now().UnixNano()), nil
-	default:
-		return nil, errors.New("unknown engine requested: " + mode)
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+// engineDataPath returns the data file path for name, prefixed with the configured namespace.
+func (s *Server) engineDataPath(name string) string {
+	if s.namespace != "" {
+		name = s.namespace + "_" + name
 	}
+	return filepath.Join(s.dataDir, name)
+}
+
+// newEngineForMode builds a non-persistent engine, as used by training games.
+func newEngineForMode(mode string, params EngineParams) (game.Engine, error) {
+	spec, err := lookupEngineMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	return spec.factory(params)
 }
 
 func (s *Server) startAutoPlayLocked(interval time.Duration) error {
@@ -915,6 +1974,7 @@ func (s *Server) startAutoPlayLocked(interval time.Duration) error {
 	if interval <= 0 {
 		interval = defaultAutoInterval
 	}
+	s.stopPonderLocked()
 	stop := make(chan struct{})
 	s.auto.active = true
 	s.auto.stopCh = stop
@@ -947,15 +2007,16 @@ func (s *Server) runAutoPlay(stop <-chan struct{}, interval time.Duration) {
 				s.mu.Unlock()
 				return
 			}
-			if mate, _ := game.CheckmateStatus(s.game); mate {
-				s.flushEngineDataLocked()
+			if s.resultLocked().Over {
+				s.metrics.autoGamesCompleted++
+				s.finishGameLocked()
 				s.auto.active = false
 				s.auto.stopCh = nil
 				s.mu.Unlock()
 				return
 			}
 			if _, moved, err := s.advanceEngineMoveLocked(true); err != nil {
-				log.Printf("auto play failed: %v", err)
+				s.logger.Error("auto play failed", "err", err)
 				s.auto.active = false
 				s.auto.stopCh = nil
 				s.mu.Unlock()
@@ -972,34 +2033,61 @@ func (s *Server) runAutoPlay(stop <-chan struct{}, interval time.Duration) {
 }
 
 type trainingConfig struct {
-	Total        int
-	Parallel     int
-	BottomEngine string
-	TopEngine    string
-	Interval     time.Duration
-	IntervalMS   int
-	MaxMoves     int
-	BatchSize    int
+	Total            int
+	Parallel         int
+	BottomEngine     string
+	TopEngine        string
+	Interval         time.Duration
+	IntervalMS       int
+	MaxMoves         int
+	BatchSize        int
+	NoProgressWindow int
+	ShuffledOpenings bool
+	// BottomIterations and TopIterations override MCTS iterations when positive.
+	BottomIterations int
+	TopIterations    int
+	RolloutPolicy    string
+	Selection        string
+	Contempt         int
+	TD               game.TDUCBParams
+	Seed             int64
+	// Start, when set, is the position every game begins from instead of the opening.
+	Start *game.GameState
+	// Evaluation is passed to the engines; see Config.Evaluation.
+	Evaluation game.EvalConfig
 }
 
 type trainingManager struct {
-	mu          sync.Mutex
-	running     bool
-	config      trainingConfig
-	summary     trainingSummary
-	games       map[int]*trainingGameStatus
-	states      map[int]game.GameState
+	mu      sync.Mutex
+	running bool
+	config  trainingConfig
+	summary trainingSummary
+	games   map[int]*trainingGameStatus
+	states  map[int]game.GameState
+	// starts holds the position each game began from, so branches can replay its history.
+	starts      map[int]game.GameState
 	history     map[int][]trainingHistoryEntry
 	stopCh      chan struct{}
-	buildEngine func(mode string, player game.Player) (game.Engine, error)
-}
-
-func newTrainingManager(builder func(mode string, player game.Player) (game.Engine, error)) *trainingManager {
+	buildEngine func(mode string, params EngineParams) (game.Engine, error)
+	scoreboard  *scoreboard
+	openings    *openingStats
+	logger      *slog.Logger
+	// gamesRun counts finished training games across all runs.
+	gamesRun int
+	// finishedAt freezes the throughput once a run ends.
+	finishedAt time.Time
+}
+
+// newTrainingManager builds training engines with builder, or with non-persistent engines
+// from newEngineForMode when builder is nil. Tests pass builders that return fakes.
+func newTrainingManager(builder func(mode string, params EngineParams) (game.Engine, error)) *trainingManager {
 	return &trainingManager{
 		games:       make(map[int]*trainingGameStatus),
 		states:      make(map[int]game.GameState),
+		starts:      make(map[int]game.GameState),
 		history:     make(map[int][]trainingHistoryEntry),
 		buildEngine: builder,
+		logger:      slog.Default(),
 	}
 }
 
@@ -1008,7 +2096,7 @@ func newTrainingManager(builder func(mode string, player game.Player) (game.Engi
 type trainingEngineFactory struct {
 	shared  bool
 	engine  game.Engine
-	builder func() (game.Engine, error)
+	builder func(seed int64) (game.Engine, error)
 }
 
 func (f *trainingEngineFactory) initShared() error {
@@ -1018,19 +2106,20 @@ func (f *trainingEngineFactory) initShared() error {
 	return f.reload()
 }
 
-func (f *trainingEngineFactory) acquire() (game.Engine, error) {
+// acquire returns the shared engine, or a new one built with seed.
+func (f *trainingEngineFactory) acquire(seed int64) (game.Engine, error) {
 	if f.shared {
 		if f.engine == nil {
 			return nil, errors.New("shared engine not initialized")
 		}
 		return f.engine, nil
 	}
-	return f.builder()
+	return f.builder(seed)
 }
 
-func (f *trainingEngineFactory) save() {
+func (f *trainingEngineFactory) save(logger *slog.Logger) {
 	if f.shared && f.engine != nil {
-		saveEngineData(f.engine)
+		saveEngineData(logger, f.engine)
 	}
 }
 
@@ -1038,7 +2127,7 @@ func (f *trainingEngineFactory) reload() error {
 	if !f.shared {
 		return nil
 	}
-	eng, err := f.builder()
+	eng, err := f.builder(time.Now().UnixNano())
 	if err != nil {
 		return err
 	}
@@ -1052,25 +2141,25 @@ type batchEngineSet struct {
 	top    *trainingEngineFactory
 }
 
-func (set *batchEngineSet) acquire(player game.Player) (game.Engine, error) {
+func (set *batchEngineSet) acquire(player game.Player, seed int64) (game.Engine, error) {
 	if set == nil {
 		return nil, errors.New("engine set not initialized")
 	}
 	if player == game.Bottom {
-		return set.bottom.acquire()
+		return set.bottom.acquire(seed)
 	}
-	return set.top.acquire()
+	return set.top.acquire(seed)
 }
 
-func (set *batchEngineSet) save() {
+func (set *batchEngineSet) save(logger *slog.Logger) {
 	if set == nil {
 		return
 	}
 	if set.bottom != nil {
-		set.bottom.save()
+		set.bottom.save(logger)
 	}
 	if set.top != nil {
-		set.top.save()
+		set.top.save(logger)
 	}
 }
 
@@ -1099,9 +2188,11 @@ func (tm *trainingManager) Start(cfg trainingConfig) error {
 	}
 	tm.running = true
 	tm.config = cfg
-	tm.summary = trainingSummary{Total: cfg.Total}
+	tm.summary = trainingSummary{Total: cfg.Total, StartedAt: time.Now(), Reasons: make(map[string]int)}
+	tm.finishedAt = time.Time{}
 	tm.games = make(map[int]*trainingGameStatus)
 	tm.states = make(map[int]game.GameState)
+	tm.starts = make(map[int]game.GameState)
 	tm.history = make(map[int][]trainingHistoryEntry)
 	stop := make(chan struct{})
 	tm.stopCh = stop
@@ -1122,6 +2213,7 @@ func (tm *trainingManager) Stop() error {
 	}
 	tm.summary.Aborted = true
 	tm.running = false
+	tm.finishedAt = time.Now()
 	return nil
 }
 
@@ -1147,15 +2239,43 @@ func (tm *trainingManager) Snapshot() trainingStatePayload {
 		Summary: tm.summary,
 		Games:   games,
 	}
+	payload.Summary.Reasons = maps.Clone(tm.summary.Reasons)
+	if !tm.summary.StartedAt.IsZero() && tm.summary.Completed > 0 {
+		end := tm.finishedAt
+		if tm.running || end.IsZero() {
+			end = time.Now()
+		}
+		if elapsed := end.Sub(tm.summary.StartedAt).Seconds(); elapsed > 0 {
+			payload.Summary.GamesPerSecond = float64(tm.summary.Completed) / elapsed
+			if tm.running {
+				payload.Summary.ETASeconds = float64(tm.summary.Total-tm.summary.Completed) / payload.Summary.GamesPerSecond
+			}
+		}
+	}
 	if tm.config.Total > 0 {
 		payload.Config = trainingConfigPayload{
-			Total:        tm.config.Total,
-			Parallel:     tm.config.Parallel,
-			BottomEngine: tm.config.BottomEngine,
-			TopEngine:    tm.config.TopEngine,
-			IntervalMS:   tm.config.IntervalMS,
-			MaxMoves:     tm.config.MaxMoves,
-			BatchSize:    tm.config.BatchSize,
+			Total:            tm.config.Total,
+			Parallel:         tm.config.Parallel,
+			BottomEngine:     tm.config.BottomEngine,
+			TopEngine:        tm.config.TopEngine,
+			IntervalMS:       tm.config.IntervalMS,
+			MaxMoves:         tm.config.MaxMoves,
+			BatchSize:        tm.config.BatchSize,
+			NoProgressWindow: tm.config.NoProgressWindow,
+			ShuffledOpenings: tm.config.ShuffledOpenings,
+			BottomIterations: tm.config.BottomIterations,
+			TopIterations:    tm.config.TopIterations,
+			RolloutPolicy:    tm.config.RolloutPolicy,
+			Selection:        tm.config.Selection,
+			Contempt:         tm.config.Contempt,
+			TDAlpha:          tm.config.TD.Alpha,
+			TDGamma:          tm.config.TD.Gamma,
+			TDExploration:    tm.config.TD.Exploration,
+			TDDecayAlpha:     tm.config.TD.DecayAlpha,
+			Seed:             tm.config.Seed,
+		}
+		if tm.config.Start != nil {
+			payload.Config.StartSFEN = game.FormatSFEN(*tm.config.Start)
 		}
 	}
 	return payload
@@ -1182,6 +2302,35 @@ func (tm *trainingManager) GameState(id int) (game.GameState, bool) {
 	return game.CloneState(state), true
 }
 
+// BranchPosition rebuilds the position of game id after ply moves by replaying its history
+// from the position it started from.
+func (tm *trainingManager) BranchPosition(id, ply int) (game.GameState, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	start, ok := tm.starts[id]
+	if !ok {
+		return game.GameState{}, fmt.Errorf("training game %d not found", id)
+	}
+	entries := tm.history[id]
+	if ply < 0 || ply > len(entries) {
+		return game.GameState{}, fmt.Errorf("ply must be between 0 and %d", len(entries))
+	}
+	state := game.CloneState(start)
+	for _, entry := range entries[:ply] {
+		mv, err := game.ParseMove(entry.Move)
+		if err != nil {
+			return game.GameState{}, err
+		}
+		if state, err = applyEngineMove(state, mv); err != nil {
+			return game.GameState{}, err
+		}
+	}
+	if err := game.VerifyState(state); err != nil {
+		return game.GameState{}, err
+	}
+	return state, nil
+}
+
 func (tm *trainingManager) GameHistory(id int) []trainingHistoryEntry {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
@@ -1197,11 +2346,12 @@ func (tm *trainingManager) GameHistory(id int) []trainingHistoryEntry {
 func (tm *trainingManager) run(cfg trainingConfig, stop <-chan struct{}) {
 	engines, err := tm.newBatchEngineSet(cfg)
 	if err != nil {
-		log.Printf("training: failed to initialize engines: %v", err)
+		tm.logger.Error("training: failed to initialize engines", "err", err)
 		tm.mu.Lock()
 		tm.summary.Aborted = true
 		tm.stopCh = nil
 		tm.running = false
+		tm.finishedAt = time.Now()
 		tm.mu.Unlock()
 		return
 	}
@@ -1214,7 +2364,9 @@ func (tm *trainingManager) run(cfg trainingConfig, stop <-chan struct{}) {
 			batchSize = remaining
 		}
 		batchAborted := tm.runBatch(cfg, stop, engines, batchSize, &nextID)
-		engines.save()
+		engines.save(tm.logger)
+		tm.saveScoreboard()
+		tm.saveOpenings()
 		remaining -= batchSize
 		if batchAborted {
 			aborted = true
@@ -1224,7 +2376,7 @@ func (tm *trainingManager) run(cfg trainingConfig, stop <-chan struct{}) {
 			break
 		}
 		if err := engines.reload(); err != nil {
-			log.Printf("training: failed to reload engine state: %v", err)
+			tm.logger.Error("training: failed to reload engine state", "err", err)
 			aborted = true
 			break
 		}
@@ -1234,19 +2386,53 @@ func (tm *trainingManager) run(cfg trainingConfig, stop <-chan struct{}) {
 		tm.summary.Aborted = true
 	}
 	tm.stopCh = nil
-	tm.running = false
+	if tm.running {
+		tm.running = false
+		tm.finishedAt = time.Now()
+	}
 	tm.mu.Unlock()
 }
 
+func (tm *trainingManager) saveScoreboard() {
+	if tm.scoreboard == nil {
+		return
+	}
+	if err := tm.scoreboard.SaveIfNeeded(); err != nil {
+		tm.logger.Warn("training: failed to save scoreboard", "err", err)
+	}
+}
+
+func (tm *trainingManager) saveOpenings() {
+	if tm.openings == nil {
+		return
+	}
+	if err := tm.openings.SaveIfNeeded(); err != nil {
+		tm.logger.Warn("training: failed to save opening statistics", "err", err)
+	}
+}
+
+// recordOpeningLocked counts the first move of game id; tm.mu must be held.
+func (tm *trainingManager) recordOpeningLocked(id int, winner string) {
+	if entries := tm.history[id]; tm.openings != nil && len(entries) > 0 {
+		tm.openings.recordGame(entries[0].Move, entries[0].Player, winner)
+	}
+}
+
+func (tm *trainingManager) recordScore(winner string) {
+	if tm.scoreboard != nil {
+		tm.scoreboard.recordResult(tm.config.BottomEngine, tm.config.TopEngine, winner)
+	}
+}
+
 func (tm *trainingManager) newBatchEngineSet(cfg trainingConfig) (*batchEngineSet, error) {
-	bottomFactory, err := tm.makeEngineFactory(cfg.BottomEngine, game.Bottom)
+	bottomFactory, err := tm.makeEngineFactory(cfg.BottomEngine, game.Bottom, cfg.BottomIterations, cfg)
 	if err != nil {
 		return nil, err
 	}
 	if err := bottomFactory.initShared(); err != nil {
 		return nil, err
 	}
-	topFactory, err := tm.makeEngineFactory(cfg.TopEngine, game.Top)
+	topFactory, err := tm.makeEngineFactory(cfg.TopEngine, game.Top, cfg.TopIterations, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -1256,19 +2442,40 @@ func (tm *trainingManager) newBatchEngineSet(cfg trainingConfig) (*batchEngineSe
 	return &batchEngineSet{bottom: bottomFactory, top: topFactory}, nil
 }
 
-func (tm *trainingManager) makeEngineFactory(mode string, player game.Player) (*trainingEngineFactory, error) {
+// makeEngineFactory builds engines for one side; iterations overrides the default when positive.
+// The rollout policy, selection criterion, contempt and TD parameters come from cfg.
+func (tm *trainingManager) makeEngineFactory(mode string, player game.Player, iterations int, cfg trainingConfig) (*trainingEngineFactory, error) {
 	builder := tm.buildEngine
 	usePersistent := builder != nil
 	if builder == nil {
-		builder = func(kind string, _ game.Player) (game.Engine, error) {
-			return newEngineForMode(kind)
-		}
+		builder = newEngineForMode
+	}
+	spec, err := lookupEngineMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := game.ParseRolloutPolicy(cfg.RolloutPolicy)
+	if err != nil {
+		return nil, err
+	}
+	selection, err := game.ParseSelectionCriterion(cfg.Selection)
+	if err != nil {
+		return nil, err
 	}
 	factory := &trainingEngineFactory{
-		shared: (mode == engineMCTS || mode == engineTDUCB) && usePersistent,
+		shared: spec.dataFile != "" && usePersistent,
 	}
-	factory.builder = func() (game.Engine, error) {
-		return builder(mode, player)
+	factory.builder = func(seed int64) (game.Engine, error) {
+		params := defaultEngineParams(player, seed)
+		if iterations > 0 {
+			params.Iterations = iterations
+		}
+		params.RolloutPolicy = policy
+		params.Selection = selection
+		params.Contempt = cfg.Contempt
+		params.TD = cfg.TD
+		params.Evaluation = cfg.Evaluation
+		return builder(mode, params)
 	}
 	return factory, nil
 }
@@ -1292,29 +2499,67 @@ func (tm *trainingManager) runBatch(cfg trainingConfig, stop <-chan struct{}, en
 				<-sem
 				wg.Done()
 			}()
-			tm.playSingleGame(gameID, cfg, stop, engines)
+			tm.playSingleGame(gameID, trainingGameSeed(cfg.Seed, gameID), cfg, stop, engines)
 		}(id)
 	}
 	wg.Wait()
 	return aborted
 }
 
-func (tm *trainingManager) playSingleGame(id int, cfg trainingConfig, stop <-chan struct{}, engines *batchEngineSet) {
-	tm.registerGame(id)
+// trainingGameSeed derives the seed of game id from the run's seed.
+func trainingGameSeed(base int64, id int) int64 {
+	return base + int64(id)
+}
+
+// replayGame plays one training game with seed and fresh, non-persistent engines and returns
+// its moves. Games whose engines did not share learned data replay exactly.
+func replayGame(seed int64, cfg trainingConfig) ([]string, error) {
+	tm := newTrainingManager(nil)
+	engines, err := tm.newBatchEngineSet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Interval = 0
+	tm.playSingleGame(1, seed, cfg, nil, engines)
+	if status := tm.games[1]; status.Error != "" {
+		return nil, errors.New(status.Error)
+	}
+	moves := make([]string, 0, len(tm.history[1]))
+	for _, entry := range tm.history[1] {
+		moves = append(moves, entry.Move)
+	}
+	return moves, nil
+}
+
+// playSingleGame plays game id. seed sets the shuffled opening and the engines' seeds, which
+// are seed*2 for Bottom and seed*2+1 for Top.
+func (tm *trainingManager) playSingleGame(id int, seed int64, cfg trainingConfig, stop <-chan struct{}, engines *batchEngineSet) {
 	state := game.NewGame()
+	if cfg.Start != nil {
+		state = game.CloneState(*cfg.Start)
+	} else if cfg.ShuffledOpenings {
+		state = game.NewShuffledGame(seed)
+	}
+	tm.registerGame(id, seed, state)
 	tm.updateGameSnapshot(id, state)
-	bottomEngine, err := engines.acquire(game.Bottom)
+	bottomEngine, err := engines.acquire(game.Bottom, seed*2)
 	if err != nil {
 		tm.recordGameError(id, err)
 		return
 	}
-	topEngine, err := engines.acquire(game.Top)
+	topEngine, err := engines.acquire(game.Top, seed*2+1)
 	if err != nil {
 		tm.recordGameError(id, err)
 		return
 	}
 	moves := 0
 	lastMove := ""
+	var thinkTime [2]time.Duration
+	var sideMoves [2]int
+	// drawOffered records whether the engine that moved last offered a draw.
+	drawOffered := false
+	positions := []string{game.PositionKey(state)}
+	states := []game.GameState{state}
 	for {
 		select {
 		case <-stop:
@@ -1323,14 +2568,23 @@ func (tm *trainingManager) playSingleGame(id int, cfg trainingConfig, stop <-cha
 			return
 		default:
 		}
-		if mate, winner := game.CheckmateStatus(state); mate {
+		if result := game.DetermineResult(state, positions); result.Over {
 			tm.updateGameSnapshot(id, state)
-			tm.finishGameWin(id, winner, moves, lastMove)
+			if result.Draw {
+				tm.finishGameDraw(id, moves, lastMove, result.Reason, result.Reason)
+			} else {
+				tm.finishGameWin(id, result.Winner, moves, lastMove, result.Reason)
+			}
+			return
+		}
+		if game.NoProgress(states, cfg.NoProgressWindow) {
+			tm.updateGameSnapshot(id, state)
+			tm.finishGameDraw(id, moves, lastMove, game.ReasonNoProgress, game.ReasonNoProgress)
 			return
 		}
 		if cfg.MaxMoves > 0 && moves >= cfg.MaxMoves {
 			tm.updateGameSnapshot(id, state)
-			tm.finishGameDraw(id, moves, lastMove)
+			tm.finishGameDraw(id, moves, lastMove, "move-limit", "move-limit")
 			return
 		}
 		var eng game.Engine
@@ -1340,13 +2594,31 @@ func (tm *trainingManager) playSingleGame(id int, cfg trainingConfig, stop <-cha
 		} else {
 			eng = topEngine
 		}
+		offers := false
+		if offerer, ok := eng.(game.DrawOfferer); ok {
+			offers = offerer.OfferDraw(state)
+		}
+		if offers && drawOffered {
+			tm.finishGameDraw(id, moves, lastMove, "draw", drawReasonAgreement)
+			return
+		}
+		drawOffered = offers
+		started := time.Now()
 		mv, err := eng.NextMove(state)
+		thinkTime[currentPlayer] += time.Since(started)
+		sideMoves[currentPlayer]++
+		tm.recordThinkTime(id, currentPlayer, thinkTime[currentPlayer], sideMoves[currentPlayer])
 		if err != nil {
 			tm.recordGameError(id, err)
 			return
 		}
-		game.ApplyMove(&state, mv)
-		state.Turn = state.Turn.Opponent()
+		state, err = applyEngineMove(state, mv)
+		if err != nil {
+			tm.recordGameError(id, err)
+			return
+		}
+		positions = append(positions, game.PositionKey(state))
+		states = append(states, state)
 		moves++
 		lastMove = game.FormatMove(mv)
 		tm.appendHistory(id, currentPlayer, lastMove)
@@ -1364,14 +2636,16 @@ func (tm *trainingManager) playSingleGame(id int, cfg trainingConfig, stop <-cha
 	}
 }
 
-func (tm *trainingManager) registerGame(id int) {
+func (tm *trainingManager) registerGame(id int, seed int64, start game.GameState) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 	tm.games[id] = &trainingGameStatus{
 		ID:    id,
 		State: "running",
-		Turn:  playerKey(game.Bottom),
+		Turn:  playerKey(start.Turn),
+		Seed:  seed,
 	}
+	tm.starts[id] = game.CloneState(start)
 	tm.history[id] = nil
 }
 
@@ -1401,7 +2675,24 @@ func (tm *trainingManager) updateGameProgress(id, moves int, lastMove string, ne
 	}
 }
 
-func (tm *trainingManager) finishGameWin(id int, winner game.Player, moves int, lastMove string) {
+func (tm *trainingManager) recordThinkTime(id int, player game.Player, total time.Duration, moves int) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	status, ok := tm.games[id]
+	if !ok {
+		return
+	}
+	totalMS := float64(total) / float64(time.Millisecond)
+	if player == game.Bottom {
+		status.BottomThinkMS = totalMS
+		status.BottomAvgMoveMS = totalMS / float64(moves)
+	} else {
+		status.TopThinkMS = totalMS
+		status.TopAvgMoveMS = totalMS / float64(moves)
+	}
+}
+
+func (tm *trainingManager) finishGameWin(id int, winner game.Player, moves int, lastMove, reason string) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 	status := tm.ensureStatus(id)
@@ -1409,27 +2700,53 @@ func (tm *trainingManager) finishGameWin(id int, winner game.Player, moves int,
 	status.LastMove = lastMove
 	status.Winner = playerKey(winner)
 	status.Result = "win"
+	status.Reason = reason
 	status.State = "completed"
 	status.Turn = ""
 	tm.summary.Completed++
+	tm.countReasonLocked(reason)
+	tm.gamesRun++
 	if winner == game.Bottom {
 		tm.summary.BottomWins++
 	} else {
 		tm.summary.TopWins++
 	}
+	tm.recordScore(playerKey(winner))
+	tm.recordOpeningLocked(id, playerKey(winner))
 }
 
-func (tm *trainingManager) finishGameDraw(id, moves int, lastMove string) {
+// finishGameDraw records an undecided game. result is "draw", "move-limit" or "repetition";
+// each is counted separately in the summary but scores as a draw on the scoreboard.
+func (tm *trainingManager) finishGameDraw(id, moves int, lastMove, result, reason string) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 	status := tm.ensureStatus(id)
 	status.Moves = moves
 	status.LastMove = lastMove
-	status.Result = "draw"
+	status.Result = result
+	status.Reason = reason
 	status.State = "completed"
 	status.Turn = ""
 	tm.summary.Completed++
-	tm.summary.Draws++
+	tm.countReasonLocked(reason)
+	tm.gamesRun++
+	switch result {
+	case "move-limit":
+		tm.summary.MoveLimits++
+	case game.ReasonRepetition:
+		tm.summary.Repetitions++
+	default:
+		tm.summary.Draws++
+	}
+	tm.recordScore("")
+	tm.recordOpeningLocked(id, "")
+}
+
+func (tm *trainingManager) countReasonLocked(reason string) {
+	if tm.summary.Reasons == nil {
+		tm.summary.Reasons = make(map[string]int)
+	}
+	tm.summary.Reasons[reason]++
 }
 
 func (tm *trainingManager) recordGameError(id int, err error) {
@@ -1441,6 +2758,7 @@ func (tm *trainingManager) recordGameError(id int, err error) {
 	status.Error = err.Error()
 	status.Turn = ""
 	tm.summary.Completed++
+	tm.gamesRun++
 	tm.summary.Errors++
 }
 
@@ -0,0 +1,2091 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorogoro/game"
+)
+
+func newTestServer(t *testing.T, cfg Config) *Server {
+	t.Helper()
+	if cfg.DataDir == "" {
+		cfg.DataDir = t.TempDir()
+	}
+	srv := New(http.Dir(t.TempDir()), cfg)
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func doJSON(t *testing.T, handler http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to marshal body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAutosaveWritesEngineData(t *testing.T) {
+	dataDir := t.TempDir()
+	srv := newTestServer(t, Config{DataDir: dataDir, AutosaveInterval: 20 * time.Millisecond})
+	handler := srv.Handler()
+
+	rec := doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "top", Engine: engineTDUCB})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("engine change failed: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	path := filepath.Join(dataDir, "td_ucb_top.gz")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected autosave to write %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEngineSwapLoadsKnowledgeInBackground(t *testing.T) {
+	dataDir := t.TempDir()
+	srv := newTestServer(t, Config{DataDir: dataDir})
+	handler := srv.Handler()
+
+	trainer, err := game.NewPersistentMCTSEngine(200, 1, srv.engineDataPath("mcts_top.json"))
+	if err != nil {
+		t.Fatalf("NewPersistentMCTSEngine failed: %v", err)
+	}
+	state := game.NewGame()
+	for ply := 0; ply < 6; ply++ {
+		mv, err := trainer.NextMove(state)
+		if err != nil {
+			t.Fatalf("training move failed: %v", err)
+		}
+		game.ApplyMove(&state, mv)
+		state.Turn = state.Turn.Opponent()
+	}
+	if err := trainer.SaveIfNeeded(); err != nil {
+		t.Fatalf("failed to save knowledge: %v", err)
+	}
+	// The server engine only searches after b3b4, so the opening can come only from the file.
+	opening := game.PositionHash(game.NewGame())
+
+	if rec := doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "top", Engine: engineMCTS}); rec.Code != http.StatusOK {
+		t.Fatalf("engine change failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"}); rec.Code != http.StatusOK {
+		t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := doJSON(t, handler, http.MethodGet, "/api/knowledge/top-moves?n=100000&player=top", nil)
+		var report knowledgeReport
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Fatalf("failed to decode report: %v", err)
+		}
+		for _, pos := range report.Positions {
+			if pos.PositionHash == opening {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stored opening knowledge was never loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForceMoveRequiresDebug(t *testing.T) {
+	handler := newTestServer(t, Config{}).Handler()
+	rec := doJSON(t, handler, http.MethodPost, "/api/force-move", moveRequest{From: "b3", To: "b5"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", rec.Code)
+	}
+	if apiErr := decodeError(t, rec); apiErr.Code != errCodeForbidden {
+		t.Fatalf("error code = %q, want %q", apiErr.Code, errCodeForbidden)
+	}
+
+	srv := newTestServer(t, Config{Debug: true})
+	rec = doJSON(t, srv.Handler(), http.MethodPost, "/api/force-move", moveRequest{From: "b3", To: "b5"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp forceMoveResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Forced || resp.Warning == "" || resp.Legal {
+		t.Fatalf("response = %+v, want a forced illegal move with a warning", resp)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if piece := srv.game.Board[4][1]; !piece.Present || piece.Kind != game.Pawn || piece.Owner != game.Bottom {
+		t.Fatalf("b5 holds %+v, want the forced pawn", piece)
+	}
+	if srv.game.Turn != game.Top {
+		t.Fatalf("turn = %v, want top after the forced move", srv.game.Turn)
+	}
+}
+
+func TestKnowledgeResetClearsEngineAndFile(t *testing.T) {
+	if rec := doJSON(t, newTestServer(t, Config{}).Handler(), http.MethodPost, "/api/knowledge/reset?player=top", nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("reset without debug: status = %d, want 403", rec.Code)
+	}
+
+	srv := newTestServer(t, Config{Debug: true})
+	handler := srv.Handler()
+	if rec := doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "top", Engine: engineMCTS}); rec.Code != http.StatusOK {
+		t.Fatalf("engine change failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"}); rec.Code != http.StatusOK {
+		t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+	}
+	srv.mu.Lock()
+	engine := srv.engines[game.Top].(*game.MCTSEngine)
+	srv.mu.Unlock()
+	if err := engine.SaveIfNeeded(); err != nil {
+		t.Fatalf("SaveIfNeeded failed: %v", err)
+	}
+	if len(engine.TopPositions(1)) == 0 {
+		t.Fatalf("engine learned nothing before the reset")
+	}
+
+	if rec := doJSON(t, handler, http.MethodPost, "/api/knowledge/reset?player=top", nil); rec.Code != http.StatusOK {
+		t.Fatalf("reset failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if positions := engine.TopPositions(1); len(positions) != 0 {
+		t.Fatalf("engine still knows %d positions after the reset", len(positions))
+	}
+	reloaded, err := game.NewPersistentMCTSEngine(1, 1, srv.engineDataPath("mcts_top.json"))
+	if err != nil {
+		t.Fatalf("NewPersistentMCTSEngine failed: %v", err)
+	}
+	if positions := reloaded.TopPositions(1); len(positions) != 0 {
+		t.Fatalf("data file still holds %d positions after the reset", len(positions))
+	}
+	if rec := doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "d1", To: "d2"}); rec.Code != http.StatusOK {
+		t.Fatalf("move after the reset failed: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestStepPlaysOneEngineMove(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	handler := srv.Handler()
+
+	rec := doJSON(t, handler, http.MethodPost, "/api/step", nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("step on a human turn: status = %d, want 409", rec.Code)
+	}
+	if apiErr := decodeError(t, rec); apiErr.Code != errCodeHumanToMove {
+		t.Fatalf("error code = %q, want %q", apiErr.Code, errCodeHumanToMove)
+	}
+
+	rec = doJSON(t, handler, http.MethodPost, "/api/newgame", newGameRequest{Bottom: engineRandom, Top: engineRandom})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("newgame failed: %d %s", rec.Code, rec.Body.String())
+	}
+	for want := 1; want <= 2; want++ {
+		rec = doJSON(t, handler, http.MethodPost, "/api/step", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("step %d failed: %d %s", want, rec.Code, rec.Body.String())
+		}
+		resp := decodeMoveResponse(t, rec)
+		if !resp.Success || len(resp.State.History) != want {
+			t.Fatalf("step %d: success=%v history=%d, want %d moves", want, resp.Success, len(resp.State.History), want)
+		}
+	}
+}
+
+func TestResetWithSeedReplaysEngineMoves(t *testing.T) {
+	handler := newTestServer(t, Config{}).Handler()
+
+	play := func() []historyEntry {
+		rec := doJSON(t, handler, http.MethodPost, "/api/reset", resetRequest{Top: engineRandom, Seed: 12345})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("reset failed: %d %s", rec.Code, rec.Body.String())
+		}
+		var state statePayload
+		if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+			t.Fatalf("failed to decode state: %v", err)
+		}
+		if state.Seed != 12345 {
+			t.Fatalf("state seed = %d, want 12345", state.Seed)
+		}
+		var resp moveResponse
+		for _, mv := range []moveRequest{{From: "b3", To: "b4"}, {From: "c3", To: "c4"}} {
+			rec = doJSON(t, handler, http.MethodPost, "/api/move", mv)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+			}
+			resp = decodeMoveResponse(t, rec)
+		}
+		return resp.State.History
+	}
+
+	first, second := play(), play()
+	if len(first) != 4 {
+		t.Fatalf("history has %d moves, want 4", len(first))
+	}
+	for i := range first {
+		if first[i].Move != second[i].Move {
+			t.Fatalf("ply %d: %s then %s with the same seed", i, first[i].Move, second[i].Move)
+		}
+	}
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	handler := newTestServer(t, Config{}).Handler()
+	if rec := doJSON(t, handler, http.MethodGet, "/healthz", nil); rec.Code != http.StatusOK {
+		t.Fatalf("healthz status = %d, want 200", rec.Code)
+	}
+	if rec := doJSON(t, handler, http.MethodGet, "/readyz", nil); rec.Code != http.StatusOK {
+		t.Fatalf("readyz status = %d, want 200", rec.Code)
+	}
+}
+
+func TestReadinessReportsInitFailure(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatalf("failed to create blocking file: %v", err)
+	}
+	handler := newTestServer(t, Config{DataDir: filepath.Join(blocker, "data")}).Handler()
+
+	if rec := doJSON(t, handler, http.MethodGet, "/healthz", nil); rec.Code != http.StatusOK {
+		t.Fatalf("healthz status = %d, want 200", rec.Code)
+	}
+	if rec := doJSON(t, handler, http.MethodGet, "/readyz", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz status = %d, want 503", rec.Code)
+	}
+}
+
+func TestNamespaceWithPathSeparatorIsRejected(t *testing.T) {
+	for _, ns := range []string{"../other", `..\other`, "a/b"} {
+		if err := ValidateNamespace(ns); err == nil {
+			t.Fatalf("ValidateNamespace(%q) accepted a path separator", ns)
+		}
+	}
+	if err := ValidateNamespace("blue"); err != nil {
+		t.Fatalf("ValidateNamespace(%q) failed: %v", "blue", err)
+	}
+
+	dataDir := t.TempDir()
+	srv := newTestServer(t, Config{DataDir: dataDir, Namespace: "../other"})
+	if rec := doJSON(t, srv.Handler(), http.MethodGet, "/readyz", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz status = %d, want 503", rec.Code)
+	}
+	if path := srv.engineDataPath("mcts_top.json"); filepath.Dir(path) != dataDir {
+		t.Fatalf("engine data path %q escapes the data directory %q", path, dataDir)
+	}
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) apiError {
+	t.Helper()
+	var payload struct {
+		Error *apiError `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&payload); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	if payload.Error == nil {
+		t.Fatalf("response has no error object")
+	}
+	return *payload.Error
+}
+
+func TestUnknownEngineReturnsJSONError(t *testing.T) {
+	handler := newTestServer(t, Config{}).Handler()
+	rec := doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "top", Engine: "nope"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	apiErr := decodeError(t, rec)
+	if apiErr.Code != errCodeUnknownEngine || apiErr.Message == "" {
+		t.Fatalf("unexpected error payload: %+v", apiErr)
+	}
+}
+
+func TestMalformedMoveReturnsJSONError(t *testing.T) {
+	handler := newTestServer(t, Config{}).Handler()
+	req := httptest.NewRequest(http.MethodPost, "/api/move", bytes.NewReader([]byte("{")))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+	if apiErr := decodeError(t, rec); apiErr.Code != errCodeBadJSON {
+		t.Fatalf("error code = %q, want %q", apiErr.Code, errCodeBadJSON)
+	}
+}
+
+func TestPromotingDropIsRejected(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	srv.mu.Lock()
+	srv.game.Hands[game.Bottom][game.Pawn] = 1
+	srv.mu.Unlock()
+
+	promote := true
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/move", moveRequest{Drop: "P", To: "a3", Promote: &promote})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	var resp moveResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != errCodeBadRequest || resp.Error.Message != "dropped pieces cannot promote" {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+}
+
+// setHumanGame replaces the server game with state and makes both sides human.
+func setHumanGame(srv *Server, state game.GameState) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.game = state
+	srv.positions = []string{game.PositionKey(state)}
+	srv.engines[game.Bottom], srv.engines[game.Top] = nil, nil
+	srv.modes[game.Bottom], srv.modes[game.Top] = engineHuman, engineHuman
+}
+
+func decodeMoveResponse(t *testing.T, rec *httptest.ResponseRecorder) moveResponse {
+	t.Helper()
+	var resp moveResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode move response: %v", err)
+	}
+	return resp
+}
+
+func TestCheckmateReportsReason(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	state := game.NewGame()
+	state.Board = [game.BoardRows][game.BoardCols]game.Piece{}
+	state.Board[0][4] = game.Piece{Kind: game.King, Owner: game.Bottom, Present: true}
+	state.Board[5][0] = game.Piece{Kind: game.King, Owner: game.Top, Present: true}
+	state.Board[3][1] = game.Piece{Kind: game.Silver, Owner: game.Bottom, Present: true}
+	state.Board[4][2] = game.Piece{Kind: game.Gold, Owner: game.Bottom, Present: true}
+	state.Hands[game.Bottom][game.Pawn] = 1
+	setHumanGame(srv, state)
+
+	resp := decodeMoveResponse(t, doJSON(t, srv.Handler(), http.MethodPost, "/api/move", moveRequest{Drop: "P", To: "a5"}))
+	if !resp.Success || !resp.GameOver || resp.Reason != game.ReasonCheckmate || resp.Winner != "bottom" {
+		t.Fatalf("unexpected response: success=%v gameOver=%v reason=%q winner=%q", resp.Success, resp.GameOver, resp.Reason, resp.Winner)
+	}
+	if !resp.State.GameOver || resp.State.Reason != game.ReasonCheckmate {
+		t.Fatalf("state payload missing result: %v %q", resp.State.GameOver, resp.State.Reason)
+	}
+}
+
+func TestEngineWithoutMovesEndsGameInsteadOfFailing(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		from   string
+		to     string
+		reason string
+	}{
+		// The gold shuts in the king on a6 without giving check.
+		{name: "no moves", from: "d6", to: "c6", reason: game.ReasonNoMoves},
+		// The gold checks from b6, protected by the silver on b5.
+		{name: "checkmate", from: "c6", to: "b6", reason: game.ReasonCheckmate},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer(t, Config{})
+			state := game.NewGame()
+			state.Board = [game.BoardRows][game.BoardCols]game.Piece{}
+			state.Board[3][1] = game.Piece{Kind: game.King, Owner: game.Bottom, Present: true}
+			state.Board[5][0] = game.Piece{Kind: game.King, Owner: game.Top, Present: true}
+			from, _ := game.ParseCoord(tc.from)
+			state.Board[from.Y][from.X] = game.Piece{Kind: game.Gold, Owner: game.Bottom, Present: true}
+			if tc.reason == game.ReasonCheckmate {
+				state.Board[4][1] = game.Piece{Kind: game.Silver, Owner: game.Bottom, Present: true}
+			}
+			setHumanGame(srv, state)
+			srv.mu.Lock()
+			srv.engines[game.Top] = game.NewRandomEngine(1)
+			srv.modes[game.Top] = engineRandom
+			srv.mu.Unlock()
+
+			resp := decodeMoveResponse(t, doJSON(t, srv.Handler(), http.MethodPost, "/api/move", moveRequest{From: tc.from, To: tc.to}))
+			if !resp.Success || !resp.GameOver || resp.Reason != tc.reason || resp.Winner != "bottom" {
+				t.Fatalf("success=%v gameOver=%v reason=%q winner=%q error=%+v", resp.Success, resp.GameOver, resp.Reason, resp.Winner, resp.Error)
+			}
+		})
+	}
+}
+
+func TestOptionalPromotionAsksForChoice(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	state := game.NewGame()
+	state.Board = [game.BoardRows][game.BoardCols]game.Piece{}
+	state.Board[0][0] = game.Piece{Kind: game.King, Owner: game.Bottom, Present: true}
+	state.Board[5][4] = game.Piece{Kind: game.King, Owner: game.Top, Present: true}
+	state.Board[3][1] = game.Piece{Kind: game.Silver, Owner: game.Bottom, Present: true}
+	setHumanGame(srv, state)
+	handler := srv.Handler()
+
+	resp := decodeMoveResponse(t, doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b4", To: "b5"}))
+	if resp.Success || !resp.RequiresPromotionChoice || len(resp.PromotionOptions) != 2 {
+		t.Fatalf("expected a promotion prompt, got %+v", resp)
+	}
+	if len(resp.State.History) != 0 || resp.State.Board[4][1].Present {
+		t.Fatalf("the prompt must not apply the move")
+	}
+
+	promote := true
+	resp = decodeMoveResponse(t, doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b4", To: "b5", Promote: &promote}))
+	if !resp.Success || resp.RequiresPromotionChoice {
+		t.Fatalf("explicit choice should apply the move: %+v", resp)
+	}
+	if silver := resp.State.Board[4][1]; silver.Kind != "S" || !silver.Promoted {
+		t.Fatalf("b5 = %+v, want a promoted silver", silver)
+	}
+}
+
+func TestMoveHintsFlagPiecesWithoutLegalMoves(t *testing.T) {
+	srv := newTestServer(t, Config{MoveHints: true})
+	// Bottom's king is checked by the gold; the far pawn cannot answer the check.
+	state := game.NewGame()
+	state.Board = [game.BoardRows][game.BoardCols]game.Piece{}
+	state.Board[0][0] = game.Piece{Kind: game.King, Owner: game.Bottom, Present: true}
+	state.Board[2][4] = game.Piece{Kind: game.Pawn, Owner: game.Bottom, Present: true}
+	state.Board[1][0] = game.Piece{Kind: game.Gold, Owner: game.Top, Present: true}
+	state.Board[5][4] = game.Piece{Kind: game.King, Owner: game.Top, Present: true}
+	setHumanGame(srv, state)
+
+	rec := doJSON(t, srv.Handler(), http.MethodGet, "/api/state", nil)
+	var payload statePayload
+	if err := json.NewDecoder(rec.Body).Decode(&payload); err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+	pawn, king, gold := payload.Board[2][4], payload.Board[0][0], payload.Board[1][0]
+	if pawn.HasLegalMove == nil || *pawn.HasLegalMove {
+		t.Fatalf("pawn should be flagged without legal moves: %v", pawn.HasLegalMove)
+	}
+	if king.HasLegalMove == nil || !*king.HasLegalMove {
+		t.Fatalf("king should be flagged movable: %v", king.HasLegalMove)
+	}
+	if gold.HasLegalMove != nil {
+		t.Fatalf("opponent piece should not be annotated")
+	}
+}
+
+func TestLegalMovesFlagChecks(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	state := game.NewGame()
+	state.Board = [game.BoardRows][game.BoardCols]game.Piece{}
+	state.Board[0][4] = game.Piece{Kind: game.King, Owner: game.Bottom, Present: true}
+	state.Board[5][2] = game.Piece{Kind: game.King, Owner: game.Top, Present: true}
+	state.Board[3][0] = game.Piece{Kind: game.Gold, Owner: game.Bottom, Present: true}
+	setHumanGame(srv, state)
+
+	var resp legalResponse
+	if err := json.NewDecoder(doJSON(t, srv.Handler(), http.MethodGet, "/api/legal?from=a4", nil).Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode legal moves: %v", err)
+	}
+	if len(resp.Moves) < 2 {
+		t.Fatalf("expected several gold moves, got %+v", resp.Moves)
+	}
+	for _, mv := range resp.Moves {
+		// Only b5 reaches diagonally onto the king on c6.
+		if mv.GivesCheck != (mv.To == "b5") {
+			t.Fatalf("move to %s: givesCheck = %v", mv.To, mv.GivesCheck)
+		}
+	}
+}
+
+func TestLegalMovesReportCheck(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	state := game.NewGame()
+	state.Board = [game.BoardRows][game.BoardCols]game.Piece{}
+	state.Board[0][2] = game.Piece{Kind: game.King, Owner: game.Bottom, Present: true}
+	state.Board[5][2] = game.Piece{Kind: game.King, Owner: game.Top, Present: true}
+	// Both Top golds attack the king on c1.
+	state.Board[1][1] = game.Piece{Kind: game.Gold, Owner: game.Top, Present: true}
+	state.Board[1][3] = game.Piece{Kind: game.Gold, Owner: game.Top, Present: true}
+	setHumanGame(srv, state)
+
+	var resp legalResponse
+	if err := json.NewDecoder(doJSON(t, srv.Handler(), http.MethodGet, "/api/legal?from=c1", nil).Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode legal moves: %v", err)
+	}
+	if !resp.InCheck || !reflect.DeepEqual(resp.Checkers, []string{"b2", "d2"}) {
+		t.Fatalf("inCheck = %v, checkers = %v, want b2 and d2", resp.InCheck, resp.Checkers)
+	}
+}
+
+func TestLegalMovesByDestination(t *testing.T) {
+	srv := newTestServer(t, Config{})
+
+	var resp legalResponse
+	if err := json.NewDecoder(doJSON(t, srv.Handler(), http.MethodGet, "/api/legal?to=b2", nil).Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode legal moves: %v", err)
+	}
+	origins := map[string]bool{}
+	for _, mv := range resp.Moves {
+		if mv.To != "b2" {
+			t.Fatalf("move %+v does not land on b2", mv)
+		}
+		origins[mv.From] = true
+	}
+	// The silver on a1 and the gold on b1 both reach b2 in the opening.
+	if !origins["a1"] || !origins["b1"] {
+		t.Fatalf("origins = %v, want a1 and b1", origins)
+	}
+}
+
+func TestRepetitionEndsGame(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	state := game.NewGame()
+	state.Board = [game.BoardRows][game.BoardCols]game.Piece{}
+	state.Board[0][0] = game.Piece{Kind: game.King, Owner: game.Bottom, Present: true}
+	state.Board[5][4] = game.Piece{Kind: game.King, Owner: game.Top, Present: true}
+	setHumanGame(srv, state)
+	handler := srv.Handler()
+
+	cycle := []moveRequest{{From: "a1", To: "b1"}, {From: "e6", To: "d6"}, {From: "b1", To: "a1"}, {From: "d6", To: "e6"}}
+	var resp moveResponse
+	// The starting position recurs for the fourth time after three cycles.
+	for i := 0; i < 3*len(cycle); i++ {
+		resp = decodeMoveResponse(t, doJSON(t, handler, http.MethodPost, "/api/move", cycle[i%len(cycle)]))
+		if !resp.Success {
+			t.Fatalf("move %d failed: %+v", i, resp.Error)
+		}
+		if resp.GameOver != (i == 3*len(cycle)-1) {
+			t.Fatalf("move %d: gameOver = %v", i, resp.GameOver)
+		}
+	}
+	if resp.Reason != game.ReasonRepetition || resp.Winner != "" {
+		t.Fatalf("reason = %q winner = %q, want repetition without winner", resp.Reason, resp.Winner)
+	}
+
+	rec := doJSON(t, handler, http.MethodPost, "/api/move", cycle[0])
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status after repetition = %d, want 409", rec.Code)
+	}
+}
+
+func TestEngineMoveDelayWaitsBeforeReply(t *testing.T) {
+	const delay = 150 * time.Millisecond
+	handler := newTestServer(t, Config{EngineMoveDelay: delay}).Handler()
+
+	start := time.Now()
+	rec := doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Fatalf("engine replied after %v, want at least %v", elapsed, delay)
+	}
+	if resp := decodeMoveResponse(t, rec); len(resp.State.History) != 2 {
+		t.Fatalf("expected the engine to reply, history has %d moves", len(resp.State.History))
+	}
+}
+
+func TestAlphaBetaMoveRecordsAssessment(t *testing.T) {
+	handler := newTestServer(t, Config{}).Handler()
+	if rec := doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "top", Engine: engineAlphaBeta}); rec.Code != http.StatusOK {
+		t.Fatalf("engine switch failed: %d %s", rec.Code, rec.Body.String())
+	}
+	rec := doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+	}
+	history := decodeMoveResponse(t, rec).State.History
+	if len(history) != 2 {
+		t.Fatalf("expected the engine to reply, history has %d moves", len(history))
+	}
+	if history[0].Assessment != nil {
+		t.Fatalf("human move has assessment %+v", history[0].Assessment)
+	}
+	if history[1].Assessment == nil || history[1].Assessment.Depth == 0 {
+		t.Fatalf("engine move assessment = %+v, want a non-zero depth", history[1].Assessment)
+	}
+}
+
+func TestEngineFailureReportsAppliedMoves(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	srv.mu.Lock()
+	// Top replies once, then Bottom's engine fails on the next ply.
+	srv.engines[game.Top] = &scriptedEngine{moves: []string{"c4c3"}}
+	srv.engines[game.Bottom] = &scriptedEngine{}
+	srv.mu.Unlock()
+
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"})
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500: %s", rec.Code, rec.Body.String())
+	}
+	resp := decodeMoveResponse(t, rec)
+	if resp.Success || resp.Error == nil || resp.Error.Code != errCodeEngineFailed {
+		t.Fatalf("success=%v error=%+v, want engine-failed", resp.Success, resp.Error)
+	}
+	if want := []string{"b3b4", "c4c3"}; !reflect.DeepEqual(resp.Applied, want) {
+		t.Fatalf("applied = %v, want %v", resp.Applied, want)
+	}
+	if len(resp.State.History) != 2 || resp.State.Turn != "bottom" {
+		t.Fatalf("state does not reflect the applied moves: history=%d turn=%s", len(resp.State.History), resp.State.Turn)
+	}
+}
+
+func TestOpeningStatsCoverInteractiveAndTrainingGames(t *testing.T) {
+	// The shortest mate from the opening position takes five plies.
+	scripts := map[game.Player][]string{
+		game.Bottom: {"b1a2", "a2a3", "a3b4"},
+		game.Top:    {"c6b5", "b5a5"},
+	}
+	srv := newTestServer(t, Config{})
+	handler := srv.Handler()
+	srv.mu.Lock()
+	srv.engines[game.Top] = &scriptedEngine{moves: append([]string(nil), scripts[game.Top]...)}
+	srv.mu.Unlock()
+	for _, mv := range scripts[game.Bottom] {
+		req := moveRequest{From: mv[:2], To: mv[2:]}
+		if rec := doJSON(t, handler, http.MethodPost, "/api/move", req); rec.Code != http.StatusOK {
+			t.Fatalf("move %s failed: %d %s", mv, rec.Code, rec.Body.String())
+		}
+	}
+
+	srv.training.buildEngine = func(mode string, params EngineParams) (game.Engine, error) {
+		return &scriptedEngine{moves: append([]string(nil), scripts[params.Player]...)}, nil
+	}
+	rec := doJSON(t, handler, http.MethodPost, "/api/training", trainingRequest{Action: "start", Games: 1, EngineBottom: engineRandom, EngineTop: engineRandom})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("training start failed: %d %s", rec.Code, rec.Body.String())
+	}
+	waitForTraining(t, srv)
+
+	rec = doJSON(t, handler, http.MethodGet, "/api/openings", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var payload openingsPayload
+	if err := json.NewDecoder(rec.Body).Decode(&payload); err != nil {
+		t.Fatalf("failed to decode openings: %v", err)
+	}
+	want := openingEntry{Move: "b1a2", Player: "bottom", openingRecord: openingRecord{Wins: 2}, Games: 2, WinRate: 1}
+	if len(payload.Openings) != 1 || payload.Openings[0] != want {
+		t.Fatalf("openings = %+v, want only %+v", payload.Openings, want)
+	}
+
+	reloaded, err := loadOpeningStats(srv.engineDataPath("openings.json"))
+	if err != nil {
+		t.Fatalf("failed to reload opening statistics: %v", err)
+	}
+	if got := reloaded.Snapshot(); !reflect.DeepEqual(got, payload) {
+		t.Fatalf("reloaded openings = %+v, want %+v", got, payload)
+	}
+}
+
+func TestNewGameWithEngineBottomOpens(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/newgame", newGameRequest{Bottom: engineRandom, Top: engineHuman})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var state statePayload
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+	if len(state.History) != 1 || state.History[0].Player != "bottom" {
+		t.Fatalf("expected one engine move by bottom, got %+v", state.History)
+	}
+	if state.Turn != "top" || state.Engines["bottom"] != engineRandom || state.Engines["top"] != engineHuman {
+		t.Fatalf("unexpected setup: turn=%s engines=%v", state.Turn, state.Engines)
+	}
+}
+
+func TestHintDoesNotTouchPlayEngineKnowledge(t *testing.T) {
+	dataDir := t.TempDir()
+	srv := newTestServer(t, Config{DataDir: dataDir, AnalysisEngine: engineMCTS})
+	handler := srv.Handler()
+	if rec := doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "bottom", Engine: engineMCTS}); rec.Code != http.StatusOK {
+		t.Fatalf("engine status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := doJSON(t, handler, http.MethodGet, "/api/hint", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("hint status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var hint hintResponse
+	if err := json.NewDecoder(rec.Body).Decode(&hint); err != nil {
+		t.Fatalf("failed to decode hint: %v", err)
+	}
+	if hint.Player != "bottom" || hint.Move == "" || hint.Engine != engineMCTS {
+		t.Fatalf("unexpected hint: %+v", hint)
+	}
+
+	srv.Shutdown()
+	if _, err := os.Stat(filepath.Join(dataDir, "mcts_bottom.json")); !os.IsNotExist(err) {
+		t.Fatalf("hint wrote play engine knowledge: %v", err)
+	}
+}
+
+func TestAnalyzeLeavesGameUnchanged(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	setHumanGame(srv, game.NewGame())
+	handler := srv.Handler()
+	before := doJSON(t, handler, http.MethodGet, "/api/state", nil).Body.String()
+
+	srv.mu.Lock()
+	first := game.GenerateLegalMoves(srv.game, game.Bottom)[0]
+	_, next := game.TryApplyMove(srv.game, first)
+	srv.mu.Unlock()
+	next.Turn = game.Top
+	second := game.GenerateLegalMoves(next, game.Top)[0]
+	line := analyzeRequest{Moves: []string{game.FormatMove(first), game.FormatMove(second)}}
+
+	rec := doJSON(t, handler, http.MethodPost, "/api/analyze", line)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp analyzeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode analysis: %v", err)
+	}
+	if resp.Turn != "bottom" || len(resp.LegalMoves) == 0 {
+		t.Fatalf("unexpected analysis: turn=%s legal=%d", resp.Turn, len(resp.LegalMoves))
+	}
+	if b := resp.Breakdown; b.Material+b.Hand+b.Check+b.Terminal != b.Total {
+		t.Fatalf("breakdown %+v does not add up", b)
+	}
+	if after := doJSON(t, handler, http.MethodGet, "/api/state", nil).Body.String(); after != before {
+		t.Fatalf("analysis changed the game:\nbefore %s\nafter %s", before, after)
+	}
+
+	bad := doJSON(t, handler, http.MethodPost, "/api/analyze", analyzeRequest{Moves: []string{line.Moves[0], "a1a6"}})
+	if apiErr := decodeError(t, bad); bad.Code != http.StatusBadRequest || apiErr.Code != errCodeIllegalMove || !strings.Contains(apiErr.Message, "move 1") {
+		t.Fatalf("unexpected error %d %+v", bad.Code, apiErr)
+	}
+}
+
+func TestResetAppliesEngineModes(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/reset", resetRequest{Bottom: engineAlphaBeta, Top: engineHuman})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var state statePayload
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+	if state.Engines["bottom"] != engineAlphaBeta || state.Engines["top"] != engineHuman || len(state.History) != 0 {
+		t.Fatalf("unexpected state: engines=%v history=%d", state.Engines, len(state.History))
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.engines[game.Bottom] == nil || srv.engines[game.Top] != nil {
+		t.Fatalf("engines not applied: %v", srv.engines)
+	}
+}
+
+func TestNewGameRejectsUnknownEngine(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/newgame", newGameRequest{Bottom: "nope"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if apiErr := decodeError(t, rec); apiErr.Code != errCodeUnknownEngine {
+		t.Fatalf("error code = %q, want %q", apiErr.Code, errCodeUnknownEngine)
+	}
+}
+
+func TestNewGameKeepsBothEnginesWhenOneFailsToBuild(t *testing.T) {
+	t.Cleanup(RegisterEngine("broken-build-test", func(EngineParams) (game.Engine, error) {
+		return nil, errors.New("engine unavailable")
+	}))
+	srv := newTestServer(t, Config{})
+	handler := srv.Handler()
+	if rec := doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"}); rec.Code != http.StatusOK {
+		t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec := doJSON(t, handler, http.MethodPost, "/api/newgame", newGameRequest{Bottom: engineRandom, Top: "broken-build-test"})
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.modes[game.Bottom] != engineHuman || srv.engines[game.Bottom] != nil {
+		t.Fatalf("bottom switched to %q although top failed", srv.modes[game.Bottom])
+	}
+	if srv.modes[game.Top] != engineRandom {
+		t.Fatalf("top mode = %q, want the previous random engine", srv.modes[game.Top])
+	}
+	if len(srv.history) == 0 {
+		t.Fatalf("the game was reset although the new game failed")
+	}
+}
+
+func TestAutoRejectsNegativeInterval(t *testing.T) {
+	handler := newTestServer(t, Config{}).Handler()
+	doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "bottom", Engine: engineRandom})
+
+	rec := doJSON(t, handler, http.MethodPost, "/api/auto", autoRequest{Running: true, IntervalMS: -1})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if apiErr := decodeError(t, rec); apiErr.Message != "interval_ms must be between 0 and 60000" {
+		t.Fatalf("unexpected message: %q", apiErr.Message)
+	}
+}
+
+func TestAutoEchoesAcceptedInterval(t *testing.T) {
+	handler := newTestServer(t, Config{}).Handler()
+	doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "bottom", Engine: engineRandom})
+
+	rec := doJSON(t, handler, http.MethodPost, "/api/auto", autoRequest{Running: true, IntervalMS: 500})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp autoResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Running || resp.IntervalMS != 500 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestEnginesListsModesWithParams(t *testing.T) {
+	handler := newTestServer(t, Config{}).Handler()
+	rec := doJSON(t, handler, http.MethodGet, "/api/engines", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var resp enginesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	params := make(map[string][]string)
+	for _, info := range resp.Engines {
+		if info.Label == "" {
+			t.Fatalf("mode %q has no label", info.Mode)
+		}
+		names := []string{}
+		for _, param := range info.Params {
+			names = append(names, param.Name)
+		}
+		params[info.Mode] = names
+	}
+	want := map[string][]string{
+		engineHuman:             {},
+		engineRandom:            {"seed"},
+		engineAlphaBeta:         {"depth"},
+		engineAlphaBetaMobility: {"depth"},
+		engineTDUCB:             {"seed"},
+		engineMCTS:              {"iterations", "seed"},
+	}
+	for mode, names := range want {
+		got, ok := params[mode]
+		if !ok {
+			t.Fatalf("mode %q missing from %v", mode, params)
+		}
+		if len(got) != len(names) {
+			t.Fatalf("mode %q params = %v, want %v", mode, got, names)
+		}
+		for i := range names {
+			if got[i] != names[i] {
+				t.Fatalf("mode %q params = %v, want %v", mode, got, names)
+			}
+		}
+	}
+}
+
+func TestRegisteredEngineIsBuiltByName(t *testing.T) {
+	var got EngineParams
+	t.Cleanup(RegisterEngine("fake-registry-test", func(p EngineParams) (game.Engine, error) {
+		got = p
+		return game.NewRandomEngine(p.Seed), nil
+	}))
+
+	srv := newTestServer(t, Config{})
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/engine", engineRequest{Player: "top", Engine: "fake-registry-test"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if got.Player != game.Top || got.Depth != int(depthParam.Default) || got.StoragePath != "" {
+		t.Fatalf("unexpected params: %+v", got)
+	}
+	if _, err := newEngineForMode("fake-registry-test", defaultEngineParams(game.Bottom, 1)); err != nil {
+		t.Fatalf("newEngineForMode failed: %v", err)
+	}
+	if got.Player != game.Bottom {
+		t.Fatalf("player = %v, want bottom", got.Player)
+	}
+}
+
+func TestUnregisterEngineRemovesMode(t *testing.T) {
+	unregister := RegisterEngine("unregister-test", func(p EngineParams) (game.Engine, error) {
+		return game.NewRandomEngine(p.Seed), nil
+	})
+	unregister()
+	if _, err := lookupEngineMode("unregister-test"); err == nil {
+		t.Fatalf("engine still registered after unregister")
+	}
+	for _, info := range listEngineModes() {
+		if info.Mode == "unregister-test" {
+			t.Fatalf("engine still listed after unregister")
+		}
+	}
+	// The name is free again.
+	RegisterEngine("unregister-test", func(p EngineParams) (game.Engine, error) {
+		return game.NewRandomEngine(p.Seed), nil
+	})()
+}
+
+func TestStateReportsPlyAndMoveNumber(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	setHumanGame(srv, game.NewGame())
+	handler := srv.Handler()
+
+	var resp moveResponse
+	for i := 0; i < 3; i++ {
+		srv.mu.Lock()
+		mv := game.GenerateLegalMoves(srv.game, srv.game.Turn)[0]
+		srv.mu.Unlock()
+		req := moveRequest{To: game.CoordToString(mv.To), Promote: &mv.Promote}
+		if mv.Drop != nil {
+			req.Drop = game.PieceTypeCode(*mv.Drop)
+		} else {
+			req.From = game.CoordToString(*mv.From)
+		}
+		resp = decodeMoveResponse(t, doJSON(t, handler, http.MethodPost, "/api/move", req))
+	}
+	if resp.State.Ply != 3 || resp.State.MoveNumber != 2 {
+		t.Fatalf("ply = %d, move number = %d; want 3 and 2", resp.State.Ply, resp.State.MoveNumber)
+	}
+
+	var reset statePayload
+	if err := json.NewDecoder(doJSON(t, handler, http.MethodPost, "/api/reset", nil).Body).Decode(&reset); err != nil {
+		t.Fatalf("failed to decode reset state: %v", err)
+	}
+	if reset.Ply != 0 || reset.MoveNumber != 1 {
+		t.Fatalf("after reset ply = %d, move number = %d", reset.Ply, reset.MoveNumber)
+	}
+}
+
+func TestEvalHistoryGrowsOncePerPly(t *testing.T) {
+	srv := newTestServer(t, Config{EvalHistory: true})
+	handler := srv.Handler()
+	doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "top", Engine: engineAlphaBeta})
+
+	for ply := 1; ply <= 3; ply++ {
+		srv.mu.Lock()
+		mv := game.GenerateLegalMoves(srv.game, srv.game.Turn)[0]
+		srv.mu.Unlock()
+		req := moveRequest{To: game.CoordToString(mv.To), Promote: &mv.Promote}
+		if mv.Drop != nil {
+			req.Drop = game.PieceTypeCode(*mv.Drop)
+		} else {
+			req.From = game.CoordToString(*mv.From)
+		}
+		if rec := doJSON(t, handler, http.MethodPost, "/api/move", req); rec.Code != http.StatusOK {
+			t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+		}
+		var state statePayload
+		if err := json.NewDecoder(doJSON(t, handler, http.MethodGet, "/api/state", nil).Body).Decode(&state); err != nil {
+			t.Fatalf("failed to decode state: %v", err)
+		}
+		if len(state.EvalHistory) != 2*ply || len(state.History) != 2*ply {
+			t.Fatalf("after %d human moves: eval history %d, history %d", ply, len(state.EvalHistory), len(state.History))
+		}
+	}
+
+	var reset statePayload
+	if err := json.NewDecoder(doJSON(t, handler, http.MethodPost, "/api/reset", nil).Body).Decode(&reset); err != nil {
+		t.Fatalf("failed to decode reset: %v", err)
+	}
+	if len(reset.EvalHistory) != 0 {
+		t.Fatalf("reset kept eval history: %v", reset.EvalHistory)
+	}
+}
+
+func TestTrainingParallelIsClamped(t *testing.T) {
+	srv := newTestServer(t, Config{MaxTrainingParallel: 4})
+	cfg, err := srv.buildTrainingConfig(trainingRequest{
+		Games:        100,
+		Parallel:     10000,
+		EngineBottom: engineRandom,
+		EngineTop:    engineRandom,
+	})
+	if err != nil {
+		t.Fatalf("buildTrainingConfig failed: %v", err)
+	}
+	if cfg.Parallel != 4 {
+		t.Fatalf("parallel = %d, want 4", cfg.Parallel)
+	}
+}
+
+func waitForTraining(t *testing.T, srv *Server) trainingStatePayload {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		snapshot := srv.training.Snapshot()
+		srv.training.mu.Lock()
+		done := !snapshot.Running && srv.training.stopCh == nil
+		srv.training.mu.Unlock()
+		if done {
+			return snapshot
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("training did not finish in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type drawOfferingEngine struct {
+	game.Engine
+}
+
+func (drawOfferingEngine) OfferDraw(game.GameState) bool { return true }
+
+func TestTrainingAcceptsMutualDrawOffers(t *testing.T) {
+	t.Cleanup(RegisterEngine("draw-offer-test", func(p EngineParams) (game.Engine, error) {
+		return drawOfferingEngine{game.NewRandomEngine(p.Seed)}, nil
+	}))
+	srv := newTestServer(t, Config{})
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/training", trainingRequest{
+		Action:       "start",
+		Games:        1,
+		EngineBottom: "draw-offer-test",
+		EngineTop:    "draw-offer-test",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("training start failed: %d %s", rec.Code, rec.Body.String())
+	}
+	snapshot := waitForTraining(t, srv)
+	if snapshot.Summary.Draws != 1 || len(snapshot.Games) != 1 {
+		t.Fatalf("unexpected summary: %+v", snapshot.Summary)
+	}
+	// Bottom offers before its first move and Top agrees on the next ply.
+	status := snapshot.Games[0]
+	if status.Result != "draw" || status.Reason != drawReasonAgreement || status.Moves != 1 {
+		t.Fatalf("unexpected game status: %+v", status)
+	}
+}
+
+// scriptedEngine plays its moves in order.
+type scriptedEngine struct {
+	mu    sync.Mutex
+	moves []string
+}
+
+func (e *scriptedEngine) NextMove(game.GameState) (game.Move, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.moves) == 0 {
+		return game.Move{}, errors.New("script exhausted")
+	}
+	next := e.moves[0]
+	e.moves = e.moves[1:]
+	return game.ParseMove(next)
+}
+
+func TestTrainingUsesInjectedEngineFactory(t *testing.T) {
+	// The shortest mate from the opening position takes five plies.
+	scripts := map[game.Player][]string{
+		game.Bottom: {"b1a2", "a2a3", "a3b4"},
+		game.Top:    {"c6b5", "b5a5"},
+	}
+	tm := newTrainingManager(func(mode string, p EngineParams) (game.Engine, error) {
+		return &scriptedEngine{moves: append([]string(nil), scripts[p.Player]...)}, nil
+	})
+	if err := tm.Start(trainingConfig{Total: 1, Parallel: 1, BottomEngine: engineRandom, TopEngine: engineRandom}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tm.mu.Lock()
+		done := !tm.running
+		tm.mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("training did not finish in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	snapshot := tm.Snapshot()
+	if snapshot.Summary.BottomWins != 1 || snapshot.Games[0].Moves != 5 || snapshot.Games[0].Result != "win" {
+		t.Fatalf("unexpected result: summary %+v game %+v", snapshot.Summary, snapshot.Games[0])
+	}
+}
+
+func TestTrainingRecordsRepetitionReason(t *testing.T) {
+	// Both kings step forward and back; the opening recurs for the fourth time after 12 plies.
+	shuffle := func(from, to string) []string {
+		var moves []string
+		for i := 0; i < 3; i++ {
+			moves = append(moves, from+to, to+from)
+		}
+		return moves
+	}
+	scripts := map[game.Player][]string{
+		game.Bottom: shuffle("c1", "c2"),
+		game.Top:    shuffle("c6", "c5"),
+	}
+	tm := newTrainingManager(func(mode string, p EngineParams) (game.Engine, error) {
+		return &scriptedEngine{moves: append([]string(nil), scripts[p.Player]...)}, nil
+	})
+	if err := tm.Start(trainingConfig{Total: 1, Parallel: 1, BottomEngine: engineRandom, TopEngine: engineRandom}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tm.mu.Lock()
+		done := !tm.running
+		tm.mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("training did not finish in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	snapshot := tm.Snapshot()
+	status := snapshot.Games[0]
+	if status.Reason != game.ReasonRepetition || status.Moves != 12 {
+		t.Fatalf("unexpected game status: %+v", status)
+	}
+	if snapshot.Summary.Reasons[game.ReasonRepetition] != 1 || snapshot.Summary.Repetitions != 1 {
+		t.Fatalf("unexpected summary: %+v", snapshot.Summary)
+	}
+}
+
+func TestTrainingSnapshotReportsThroughput(t *testing.T) {
+	scripts := map[game.Player][]string{
+		game.Bottom: {"b1a2", "a2a3", "a3b4"},
+		game.Top:    {"c6b5", "b5a5"},
+	}
+	tm := newTrainingManager(func(mode string, p EngineParams) (game.Engine, error) {
+		script := &scriptedEngine{moves: append([]string(nil), scripts[p.Player]...)}
+		return slowEngine{Engine: script, delay: 5 * time.Millisecond}, nil
+	})
+	if err := tm.Start(trainingConfig{Total: 40, Parallel: 1, BatchSize: 40, BottomEngine: engineRandom, TopEngine: engineRandom}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer tm.Stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snapshot := tm.Snapshot()
+		if snapshot.Summary.Completed >= 2 {
+			summary := snapshot.Summary
+			if !snapshot.Running {
+				t.Fatalf("training finished before the snapshot")
+			}
+			if summary.StartedAt.IsZero() || summary.GamesPerSecond <= 0 || summary.GamesPerSecond > 1000 {
+				t.Fatalf("implausible throughput: %+v", summary)
+			}
+			if summary.ETASeconds <= 0 || summary.ETASeconds > 60 {
+				t.Fatalf("implausible ETA: %+v", summary)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("training did not complete two games in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReplayGameReproducesTrainingGame(t *testing.T) {
+	tm := newTrainingManager(nil)
+	cfg := trainingConfig{Total: 3, Parallel: 3, BottomEngine: engineRandom, TopEngine: engineAlphaBeta, MaxMoves: 30, ShuffledOpenings: true, Seed: 42}
+	if err := tm.Start(cfg); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		tm.mu.Lock()
+		done := tm.stopCh == nil
+		tm.mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("training did not finish in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	status, ok := tm.GameStatus(2)
+	if !ok || status.Seed != trainingGameSeed(42, 2) {
+		t.Fatalf("game 2 status = %+v, want the derived seed", status)
+	}
+	var recorded []string
+	for _, entry := range tm.history[2] {
+		recorded = append(recorded, entry.Move)
+	}
+	replayed, err := replayGame(status.Seed, cfg)
+	if err != nil {
+		t.Fatalf("replayGame failed: %v", err)
+	}
+	if len(recorded) == 0 || !reflect.DeepEqual(replayed, recorded) {
+		t.Fatalf("replayed %v, want %v", replayed, recorded)
+	}
+}
+
+func TestTrainingReplayActionReturnsRecordedMoves(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	req := trainingRequest{
+		Action:           "start",
+		Games:            3,
+		Parallel:         3,
+		MaxMoves:         30,
+		EngineBottom:     engineRandom,
+		EngineTop:        engineAlphaBeta,
+		ShuffledOpenings: true,
+		Seed:             11,
+	}
+	if rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/training", req); rec.Code != http.StatusOK {
+		t.Fatalf("training start failed: %d %s", rec.Code, rec.Body.String())
+	}
+	waitForTraining(t, srv)
+	status, ok := srv.training.GameStatus(2)
+	if !ok {
+		t.Fatalf("game 2 has no status")
+	}
+	var recorded []string
+	for _, entry := range srv.training.GameHistory(2) {
+		recorded = append(recorded, entry.Move)
+	}
+
+	req.Action = "replay"
+	req.GameSeed = status.Seed
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/training", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("replay failed: %d %s", rec.Code, rec.Body.String())
+	}
+	var resp trainingReplayResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode replay: %v", err)
+	}
+	if resp.Seed != status.Seed || len(recorded) == 0 || !reflect.DeepEqual(resp.Moves, recorded) {
+		t.Fatalf("replay = %+v, want seed %d and moves %v", resp, status.Seed, recorded)
+	}
+}
+
+func TestRandomTrainingBatchIsReproducibleWithSeed(t *testing.T) {
+	run := func() ([]trainingGameStatus, map[int][]string) {
+		srv := newTestServer(t, Config{})
+		rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/training", trainingRequest{
+			Action:       "start",
+			Games:        4,
+			Parallel:     2,
+			MaxMoves:     40,
+			EngineBottom: engineRandom,
+			EngineTop:    engineRandom,
+			Seed:         7,
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("training start failed: %d %s", rec.Code, rec.Body.String())
+		}
+		snapshot := waitForTraining(t, srv)
+		srv.training.mu.Lock()
+		defer srv.training.mu.Unlock()
+		moves := make(map[int][]string)
+		for id, entries := range srv.training.history {
+			for _, entry := range entries {
+				moves[id] = append(moves[id], entry.Move)
+			}
+		}
+		return snapshot.Games, moves
+	}
+
+	firstGames, firstMoves := run()
+	secondGames, secondMoves := run()
+	if len(firstMoves) != 4 || !reflect.DeepEqual(firstMoves, secondMoves) {
+		t.Fatalf("moves differ between runs with the same seed:\n%v\n%v", firstMoves, secondMoves)
+	}
+	results := func(games []trainingGameStatus) map[int]string {
+		out := make(map[int]string)
+		for _, g := range games {
+			out[g.ID] = g.Result + "/" + g.Winner + "/" + g.Reason
+		}
+		return out
+	}
+	if !reflect.DeepEqual(results(firstGames), results(secondGames)) {
+		t.Fatalf("results differ between runs: %v vs %v", results(firstGames), results(secondGames))
+	}
+}
+
+func TestTrainingBranchStartsFromRecordedPosition(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/training", trainingRequest{
+		Action:       "start",
+		Games:        1,
+		MaxMoves:     20,
+		EngineBottom: engineRandom,
+		EngineTop:    engineRandom,
+		Seed:         5,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("training start failed: %d %s", rec.Code, rec.Body.String())
+	}
+	waitForTraining(t, srv)
+	history := srv.training.GameHistory(1)
+	if len(history) < 2 {
+		t.Fatalf("expected a recorded game, got %d moves", len(history))
+	}
+	ply := len(history) / 2
+	want := game.NewGame()
+	for _, entry := range history[:ply] {
+		mv, err := game.ParseMove(entry.Move)
+		if err != nil {
+			t.Fatalf("ParseMove(%q) failed: %v", entry.Move, err)
+		}
+		if want, err = applyEngineMove(want, mv); err != nil {
+			t.Fatalf("replaying %s failed: %v", entry.Move, err)
+		}
+	}
+
+	rec = doJSON(t, srv.Handler(), http.MethodPost, "/api/training", trainingRequest{
+		Action:       "branch",
+		Games:        2,
+		MaxMoves:     20,
+		EngineBottom: engineRandom,
+		EngineTop:    engineAlphaBeta,
+		BranchGame:   1,
+		BranchPly:    ply,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("training branch failed: %d %s", rec.Code, rec.Body.String())
+	}
+	snapshot := waitForTraining(t, srv)
+	if snapshot.Config.StartSFEN != game.FormatSFEN(want) {
+		t.Fatalf("startSfen = %q, want %q", snapshot.Config.StartSFEN, game.FormatSFEN(want))
+	}
+	for id := 1; id <= 2; id++ {
+		start, err := srv.training.BranchPosition(id, 0)
+		if err != nil {
+			t.Fatalf("game %d: %v", id, err)
+		}
+		if game.PositionKey(start) != game.PositionKey(want) {
+			t.Fatalf("game %d started from %s, want %s", id, game.FormatSFEN(start), game.FormatSFEN(want))
+		}
+	}
+
+	rec = doJSON(t, srv.Handler(), http.MethodPost, "/api/training", trainingRequest{Action: "branch", Games: 1, EngineBottom: engineRandom, EngineTop: engineRandom, BranchGame: 9})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("branch from a missing game: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestIllegalEngineMoveIsReported(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	srv.mu.Lock()
+	srv.engines[game.Top] = &scriptedEngine{moves: []string{"a1a6"}}
+	srv.mu.Unlock()
+
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"})
+	resp := decodeMoveResponse(t, rec)
+	if rec.Code != http.StatusInternalServerError || resp.Error == nil || !strings.Contains(resp.Error.Message, "engine produced illegal move a1a6") {
+		t.Fatalf("unexpected response %d: %+v", rec.Code, resp.Error)
+	}
+	if len(resp.State.History) != 1 || resp.State.Turn != "top" {
+		t.Fatalf("board changed after illegal engine move: history %d turn %s", len(resp.State.History), resp.State.Turn)
+	}
+}
+
+type slowEngine struct {
+	game.Engine
+	delay time.Duration
+}
+
+func (e slowEngine) NextMove(state game.GameState) (game.Move, error) {
+	time.Sleep(e.delay)
+	return e.Engine.NextMove(state)
+}
+
+func TestEngineSwapDiscardsStaleMove(t *testing.T) {
+	// The factory hands out one instance, so swapping back restores the same engine.
+	shared := slowEngine{Engine: game.NewRandomEngine(1), delay: 200 * time.Millisecond}
+	t.Cleanup(RegisterEngine("stale-test", func(EngineParams) (game.Engine, error) { return shared, nil }))
+	srv := newTestServer(t, Config{})
+	handler := srv.Handler()
+	if rec := doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "top", Engine: "stale-test"}); rec.Code != http.StatusOK {
+		t.Fatalf("engine switch failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	for _, mode := range []string{engineHuman, "stale-test"} {
+		if rec := doJSON(t, handler, http.MethodPost, "/api/engine", engineRequest{Player: "top", Engine: mode}); rec.Code != http.StatusOK {
+			t.Fatalf("engine switch to %s failed: %d %s", mode, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := <-done
+	if rec.Code != http.StatusOK {
+		t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+	}
+	if resp := decodeMoveResponse(t, rec); len(resp.State.History) != 1 {
+		t.Fatalf("stale engine move was applied, history has %d moves", len(resp.State.History))
+	}
+}
+
+func TestTrainingRecordsThinkTimePerSide(t *testing.T) {
+	t.Cleanup(RegisterEngine("slow-test", func(p EngineParams) (game.Engine, error) {
+		return slowEngine{Engine: game.NewRandomEngine(p.Seed), delay: 20 * time.Millisecond}, nil
+	}))
+	srv := newTestServer(t, Config{})
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/training", trainingRequest{
+		Action:       "start",
+		Games:        1,
+		EngineBottom: "slow-test",
+		EngineTop:    engineRandom,
+		MaxMoves:     4,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("training start failed: %d %s", rec.Code, rec.Body.String())
+	}
+	status := waitForTraining(t, srv).Games[0]
+	if status.BottomThinkMS < 40 || status.BottomAvgMoveMS < 20 {
+		t.Fatalf("bottom think time too small: %+v", status)
+	}
+	if status.TopThinkMS >= status.BottomThinkMS {
+		t.Fatalf("slow side not attributed to bottom: %+v", status)
+	}
+}
+
+func TestTrainingReportsMoveLimitSeparately(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/training", trainingRequest{
+		Action:       "start",
+		Games:        1,
+		EngineBottom: engineRandom,
+		EngineTop:    engineRandom,
+		MaxMoves:     1,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("training start failed: %d %s", rec.Code, rec.Body.String())
+	}
+	snapshot := waitForTraining(t, srv)
+	if snapshot.Summary.MoveLimits != 1 || snapshot.Summary.Draws != 0 {
+		t.Fatalf("unexpected summary: %+v", snapshot.Summary)
+	}
+	if result := snapshot.Games[0].Result; result != "move-limit" {
+		t.Fatalf("result = %q, want move-limit", result)
+	}
+}
+
+func TestTrainingUsesIterationOverride(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	var mu sync.Mutex
+	var built []*game.MCTSEngine
+	srv.training.buildEngine = func(mode string, params EngineParams) (game.Engine, error) {
+		eng, err := srv.buildEngine(mode, params)
+		if mcts, ok := eng.(*game.MCTSEngine); ok {
+			mu.Lock()
+			built = append(built, mcts)
+			mu.Unlock()
+		}
+		return eng, err
+	}
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/training", trainingRequest{
+		Action:           "start",
+		Games:            1,
+		EngineBottom:     engineMCTS,
+		EngineTop:        engineRandom,
+		MaxMoves:         6,
+		BottomIterations: 10,
+		RolloutPolicy:    "captures",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("training start failed: %d %s", rec.Code, rec.Body.String())
+	}
+	waitForTraining(t, srv)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var simulations int64
+	for _, eng := range built {
+		simulations += eng.Simulations()
+	}
+	// Bottom plays at most three of the six plies.
+	if simulations == 0 || simulations > 3*10 {
+		t.Fatalf("simulations = %d, want at most %d", simulations, 3*10)
+	}
+}
+
+func TestTrainingRejectsInvalidOverrides(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	requests := []trainingRequest{
+		{Games: 1, EngineBottom: engineMCTS, EngineTop: engineRandom, BottomIterations: -1},
+		{Games: 1, EngineBottom: engineRandom, EngineTop: engineRandom, TopIterations: 10},
+		{Games: 1, EngineBottom: engineMCTS, EngineTop: engineRandom, RolloutPolicy: "greedy"},
+		{Games: 1, EngineBottom: engineTDUCB, EngineTop: engineRandom, TDGamma: 1.2},
+	}
+	for _, req := range requests {
+		if _, err := srv.buildTrainingConfig(req); err == nil {
+			t.Fatalf("expected %+v to be rejected", req)
+		}
+	}
+}
+
+func TestScoreboardPersistsTrainingResults(t *testing.T) {
+	dataDir := t.TempDir()
+	srv := newTestServer(t, Config{DataDir: dataDir})
+	handler := srv.Handler()
+
+	rec := doJSON(t, handler, http.MethodPost, "/api/training", trainingRequest{
+		Action:       "start",
+		Games:        3,
+		EngineBottom: engineRandom,
+		EngineTop:    engineRandom,
+		MaxMoves:     20,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("training start failed: %d %s", rec.Code, rec.Body.String())
+	}
+	summary := waitForTraining(t, srv).Summary
+
+	data, err := os.ReadFile(filepath.Join(dataDir, "scoreboard.json"))
+	if err != nil {
+		t.Fatalf("failed to read scoreboard: %v", err)
+	}
+	var stored scoreboardPayload
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("invalid scoreboard file: %v", err)
+	}
+	if len(stored.Entries) != 1 {
+		t.Fatalf("expected one matchup, got %+v", stored.Entries)
+	}
+	entry := stored.Entries[0]
+	if entry.BottomEngine != engineRandom || entry.TopEngine != engineRandom {
+		t.Fatalf("unexpected matchup: %+v", entry)
+	}
+	if entry.Wins != summary.BottomWins || entry.Losses != summary.TopWins || entry.Draws != summary.Draws+summary.MoveLimits+summary.Repetitions {
+		t.Fatalf("scoreboard %+v does not match summary %+v", entry, summary)
+	}
+	if entry.Wins+entry.Losses+entry.Draws != 3 {
+		t.Fatalf("expected 3 recorded games, got %+v", entry)
+	}
+}
+
+func TestMetricsCountMovesAndRejections(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	handler := srv.Handler()
+	if rec := doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"}); rec.Code != http.StatusOK {
+		t.Fatalf("legal move status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b4", To: "b6"}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("illegal move status = %d, want 400", rec.Code)
+	}
+
+	var metrics metricsResponse
+	if err := json.NewDecoder(doJSON(t, handler, http.MethodGet, "/api/metrics", nil).Body).Decode(&metrics); err != nil {
+		t.Fatalf("failed to decode metrics: %v", err)
+	}
+	// The human move is answered by the default random engine.
+	if metrics.MovesPlayed != 2 || metrics.EngineMoves[engineRandom] != 1 || metrics.IllegalMoves != 1 {
+		t.Fatalf("unexpected metrics: %+v", metrics)
+	}
+}
+
+type failingSaveEngine struct {
+	game.Engine
+}
+
+func (failingSaveEngine) SaveIfNeeded() error {
+	return errors.New("disk full")
+}
+
+func TestEngineSaveFailureIsLoggedAtWarn(t *testing.T) {
+	var logs bytes.Buffer
+	srv := newTestServer(t, Config{Logger: slog.New(slog.NewTextHandler(&logs, nil))})
+	srv.mu.Lock()
+	srv.engines[game.Top] = failingSaveEngine{Engine: game.NewRandomEngine(1)}
+	srv.mu.Unlock()
+
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/engine", engineRequest{Player: "top", Engine: engineHuman})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	out := logs.String()
+	if !strings.Contains(out, "level=WARN") || !strings.Contains(out, "failed to save engine data") || !strings.Contains(out, "disk full") {
+		t.Fatalf("expected a warn-level save failure, got %q", out)
+	}
+}
+
+func TestEngineLoadFailureIsLoggedAtWarn(t *testing.T) {
+	var logs bytes.Buffer
+	srv := newTestServer(t, Config{Logger: slog.New(slog.NewTextHandler(&logs, nil))})
+	if err := os.WriteFile(srv.engineDataPath("mcts_top.json"), []byte{0x1f, 0x8b, 'x'}, 0o644); err != nil {
+		t.Fatalf("failed to write corrupt knowledge: %v", err)
+	}
+
+	eng, err := srv.buildEngine(engineMCTS, EngineParams{Player: game.Top, Iterations: 1})
+	if err != nil || eng == nil {
+		t.Fatalf("buildEngine = %v, %v; want an engine despite the unreadable knowledge", eng, err)
+	}
+	out := logs.String()
+	if !strings.Contains(out, "level=WARN") || !strings.Contains(out, "failed to load engine knowledge") {
+		t.Fatalf("expected a warn-level load failure, got %q", out)
+	}
+}
+
+// countingEngine counts the searches played through NextMove; ponder searches are not counted.
+type countingEngine struct {
+	*game.MCTSEngine
+	mu    sync.Mutex
+	calls int
+}
+
+func (e *countingEngine) NextMove(state game.GameState) (game.Move, error) {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+	return e.MCTSEngine.NextMove(state)
+}
+
+func (e *countingEngine) callCount() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func TestPonderedSearchIsReusedForPredictedMove(t *testing.T) {
+	srv := newTestServer(t, Config{Ponder: true})
+	handler := srv.Handler()
+
+	srv.mu.Lock()
+	counter := &countingEngine{MCTSEngine: game.NewMCTSEngine(200, 1)}
+	srv.engines[game.Top] = counter
+	srv.modes[game.Top] = engineMCTS
+	srv.mu.Unlock()
+
+	if rec := doJSON(t, handler, http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"}); rec.Code != http.StatusOK {
+		t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+	}
+	srv.mu.Lock()
+	pending := srv.ponder
+	srv.mu.Unlock()
+	if pending == nil {
+		t.Fatalf("expected the engine to start pondering")
+	}
+	<-pending.done
+	if pending.err != nil {
+		t.Fatalf("ponder failed: %v", pending.err)
+	}
+	callsBefore := counter.callCount()
+
+	reply := pending.reply
+	req := moveRequest{To: game.CoordToString(reply.To), Promote: &reply.Promote}
+	if reply.Drop != nil {
+		req.Drop = game.PieceTypeCode(*reply.Drop)
+	} else {
+		req.From = game.CoordToString(*reply.From)
+	}
+	if rec := doJSON(t, handler, http.MethodPost, "/api/move", req); rec.Code != http.StatusOK {
+		t.Fatalf("predicted move failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	srv.mu.Lock()
+	last := srv.history[len(srv.history)-1]
+	next := srv.ponder
+	srv.mu.Unlock()
+	if last.Move != game.FormatMove(pending.move) {
+		t.Fatalf("engine played %s, want pondered %s", last.Move, game.FormatMove(pending.move))
+	}
+	if next != nil {
+		<-next.done
+	}
+	if calls := counter.callCount(); calls != callsBefore {
+		t.Fatalf("engine searched %d more times, want the pondered move reused", calls-callsBefore)
+	}
+}
+
+// blockingPonderEngine predicts a fixed reply and ponders until its search is cancelled.
+type blockingPonderEngine struct {
+	game.Engine
+	reply game.Move
+}
+
+func (e *blockingPonderEngine) ExpectedReply() (game.Move, bool) {
+	return e.reply, true
+}
+
+func (e *blockingPonderEngine) PonderSearch(ctx context.Context, state game.GameState) (game.Move, error) {
+	<-ctx.Done()
+	return game.Move{}, ctx.Err()
+}
+
+func startBlockingPonder(t *testing.T, srv *Server) *ponderState {
+	t.Helper()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	reply := game.GenerateLegalMoves(srv.game, srv.game.Turn)[0]
+	srv.engines[srv.game.Turn.Opponent()] = &blockingPonderEngine{Engine: game.NewRandomEngine(1), reply: reply}
+	srv.startPonderLocked()
+	if srv.ponder == nil {
+		t.Fatalf("expected the engine to start pondering")
+	}
+	return srv.ponder
+}
+
+func TestResetCancelsPonder(t *testing.T) {
+	srv := newTestServer(t, Config{Ponder: true})
+	pending := startBlockingPonder(t, srv)
+
+	if rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/reset", nil); rec.Code != http.StatusOK {
+		t.Fatalf("reset failed: %d %s", rec.Code, rec.Body.String())
+	}
+	select {
+	case <-pending.done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("reset left the ponder search running")
+	}
+	if !errors.Is(pending.err, context.Canceled) {
+		t.Fatalf("ponder error = %v, want context.Canceled", pending.err)
+	}
+}
+
+func TestShutdownWaitsForPonder(t *testing.T) {
+	srv := newTestServer(t, Config{Ponder: true})
+	pending := startBlockingPonder(t, srv)
+
+	srv.Shutdown()
+	select {
+	case <-pending.done:
+	default:
+		t.Fatalf("Shutdown returned while the ponder search was running")
+	}
+}
+
+func TestMoveTimeoutResignsIdleHuman(t *testing.T) {
+	srv := newTestServer(t, Config{MoveTimeout: 30 * time.Millisecond})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var state statePayload
+		rec := doJSON(t, srv.Handler(), http.MethodGet, "/api/state", nil)
+		if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+			t.Fatalf("failed to decode state: %v", err)
+		}
+		if state.GameOver {
+			if state.Reason != reasonTimeout || state.Winner != "top" {
+				t.Fatalf("result = %q winner %q, want timeout won by top", state.Reason, state.Winner)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("idle human was never timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/move", moveRequest{From: "b3", To: "b4"})
+	if resp := decodeMoveResponse(t, rec); resp.Success {
+		t.Fatalf("move after timeout should be rejected")
+	}
+}
+
+func TestMoveTimeoutPlaysRandomMoveForIdleHuman(t *testing.T) {
+	srv := newTestServer(t, Config{MoveTimeout: 30 * time.Millisecond, TimeoutAction: timeoutRandom})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		srv.mu.Lock()
+		history := append([]historyEntry(nil), srv.history...)
+		over := srv.resultLocked().Over
+		srv.mu.Unlock()
+		if over {
+			t.Fatalf("random timeout action should not end the game")
+		}
+		if len(history) > 0 {
+			if history[0].Player != "bottom" {
+				t.Fatalf("first move by %s, want bottom", history[0].Player)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no move was played for the idle human")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMoveTimeoutRandomMoveThatEndsGameFinishesIt(t *testing.T) {
+	srv := newTestServer(t, Config{MoveTimeout: 30 * time.Millisecond, TimeoutAction: timeoutRandom})
+	// Under king-capture rules every move of the bottom king walks into the top gold or
+	// silver, so whatever the timeout plays ends the game.
+	state := game.NewGame()
+	state.Rules = game.RulesKingCapture
+	state.Board = [game.BoardRows][game.BoardCols]game.Piece{}
+	state.Board[0][0] = game.Piece{Kind: game.King, Owner: game.Bottom, Present: true}
+	state.Board[5][4] = game.Piece{Kind: game.King, Owner: game.Top, Present: true}
+	state.Board[2][1] = game.Piece{Kind: game.Gold, Owner: game.Top, Present: true}
+	state.Board[1][2] = game.Piece{Kind: game.Silver, Owner: game.Top, Present: true}
+	setHumanGame(srv, state)
+	srv.mu.Lock()
+	srv.history = nil
+	srv.armMoveTimeoutLocked()
+	srv.mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		srv.mu.Lock()
+		moves, result, recorded := len(srv.history), srv.resultLocked(), srv.openingRecorded
+		srv.mu.Unlock()
+		if moves > 0 {
+			if !result.Over || result.Reason != game.ReasonKingCaptured || !recorded {
+				t.Fatalf("after the timeout move result = %+v, opening recorded = %v; want a finished game", result, recorded)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no move was played for the idle human")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBatchMovesAppliesOpeningWithoutEngineReplies(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/moves", movesRequest{Moves: []string{"b1a2", "c6b5", "a2a3", "b5a5", "a3b4"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp movesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Success || len(resp.Results) != 5 || len(resp.State.History) != 5 {
+		t.Fatalf("success=%v results=%d history=%d, want all five moves applied", resp.Success, len(resp.Results), len(resp.State.History))
+	}
+	board := resp.State.Board
+	if gold := board[3][1]; gold.Kind != "G" || gold.Owner != "bottom" {
+		t.Fatalf("b4 = %+v, want bottom gold", gold)
+	}
+	if king := board[4][0]; king.Kind != "K" || king.Owner != "top" {
+		t.Fatalf("a5 = %+v, want top king", king)
+	}
+	if board[0][1].Present || board[5][2].Present {
+		t.Fatalf("b1 and c6 should be empty")
+	}
+	if !resp.State.GameOver || resp.State.Winner != "bottom" {
+		t.Fatalf("gameOver=%v winner=%q, want checkmate by bottom", resp.State.GameOver, resp.State.Winner)
+	}
+}
+
+func TestBatchMovesStopsAtFirstIllegalMove(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/moves", movesRequest{Moves: []string{"b1a2", "c6c1", "a2a3"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	var resp movesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.FailedIndex == nil || *resp.FailedIndex != 1 || len(resp.Results) != 2 {
+		t.Fatalf("failedIndex=%v results=%d, want failure at index 1", resp.FailedIndex, len(resp.Results))
+	}
+	if resp.Results[1].Error == nil || resp.Results[1].Error.Code != errCodeIllegalMove {
+		t.Fatalf("result = %+v, want illegal-move error", resp.Results[1])
+	}
+	if len(resp.State.History) != 1 {
+		t.Fatalf("history has %d moves, want only the first", len(resp.State.History))
+	}
+}
+
+func TestPositionHashIgnoresMoveOrder(t *testing.T) {
+	hashAfter := func(moves []string) string {
+		srv := newTestServer(t, Config{})
+		rec := doJSON(t, srv.Handler(), http.MethodPost, "/api/moves", movesRequest{Moves: moves})
+		var resp movesResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if !resp.Success {
+			t.Fatalf("moves %v rejected: %+v", moves, resp.Results)
+		}
+		return resp.State.PositionHash
+	}
+
+	initial := hashAfter(nil)
+	first := hashAfter([]string{"a1a2", "a6a5", "e1e2", "e6e5"})
+	second := hashAfter([]string{"e1e2", "e6e5", "a1a2", "a6a5"})
+	if first == "" || first != second {
+		t.Fatalf("hashes differ for the same position: %q vs %q", first, second)
+	}
+	if first == initial {
+		t.Fatalf("hash did not change after moves")
+	}
+}
+
+func TestKnowledgeReportListsOpeningPosition(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	handler := srv.Handler()
+	if rec := doJSON(t, handler, http.MethodPost, "/api/newgame", newGameRequest{Bottom: engineMCTS, Top: engineHuman}); rec.Code != http.StatusOK {
+		t.Fatalf("newgame failed: %d %s", rec.Code, rec.Body.String())
+	}
+	srv.mu.Lock()
+	var reply game.Move
+	for _, mv := range game.GenerateLegalMoves(srv.game, srv.game.Turn) {
+		if mv.Drop == nil {
+			reply = mv
+			break
+		}
+	}
+	srv.mu.Unlock()
+	req := moveRequest{From: game.CoordToString(*reply.From), To: game.CoordToString(reply.To), Promote: &reply.Promote}
+	if rec := doJSON(t, handler, http.MethodPost, "/api/move", req); rec.Code != http.StatusOK {
+		t.Fatalf("move failed: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec := doJSON(t, handler, http.MethodGet, "/api/knowledge/top-moves?n=20", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var report knowledgeReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	opening := game.NewGame()
+	for _, pos := range report.Positions {
+		if pos.PositionHash != game.PositionHash(opening) {
+			continue
+		}
+		mv, err := game.ParseMove(pos.BestMove)
+		if err != nil {
+			t.Fatalf("unreadable best move %q: %v", pos.BestMove, err)
+		}
+		if pos.SFEN != game.FormatSFEN(opening) {
+			t.Fatalf("opening entry has SFEN %q", pos.SFEN)
+		}
+		if legal, _ := game.TryApplyMove(opening, mv); !legal || pos.BestVisits <= 0 || pos.BestMoveText == "" {
+			t.Fatalf("opening entry %+v does not describe a played legal move", pos)
+		}
+		return
+	}
+	t.Fatalf("report of %d positions does not list the opening position", len(report.Positions))
+}
+
+func TestEvalMovePrefersFreeCapture(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	// The pawn on c4 is undefended, so the gold on c3 wins it for free.
+	sfen := url.QueryEscape("4k/5/2p2/2G2/5/K4 b - 1")
+	evalMove := func(from, to string) evalMoveResponse {
+		t.Helper()
+		rec := doJSON(t, srv.Handler(), http.MethodGet, "/api/eval-move?sfen="+sfen+"&from="+from+"&to="+to, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s%s: status = %d, want 200: %s", from, to, rec.Code, rec.Body.String())
+		}
+		var resp evalMoveResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp
+	}
+	capture, passive := evalMove("c3", "c4"), evalMove("a1", "a2")
+	if capture.Evaluation <= passive.Evaluation {
+		t.Fatalf("capture evaluates to %d, not above the passive move's %d", capture.Evaluation, passive.Evaluation)
+	}
+
+	if rec := doJSON(t, srv.Handler(), http.MethodGet, "/api/eval-move?sfen="+sfen+"&from=c3&to=c5", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("illegal move status = %d, want 400", rec.Code)
+	}
+}
+
+func TestMateEndpointAnalysesSFENPosition(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	// Bottom mates by dropping the gold next to the cornered king.
+	sfen := "k4/5/1S3/5/5/4K b G 1"
+	rec := doJSON(t, srv.Handler(), http.MethodGet, "/api/mate?depth=1&sfen="+url.QueryEscape(sfen), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp mateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.Found || len(resp.Moves) != 1 || resp.Player != "bottom" {
+		t.Fatalf("unexpected mate response %+v", resp)
+	}
+	state, _ := game.ParseSFEN(sfen)
+	mv, err := game.ParseMove(resp.Moves[0])
+	if err != nil {
+		t.Fatalf("unreadable move %q: %v", resp.Moves[0], err)
+	}
+	legal, next := game.TryApplyMove(state, mv)
+	next.Turn = game.Top
+	if mate, _ := game.CheckmateStatus(next); !legal || !mate {
+		t.Fatalf("%s does not mate", resp.Moves[0])
+	}
+
+	if rec := doJSON(t, srv.Handler(), http.MethodGet, "/api/hint?sfen="+url.QueryEscape("k4/5 b -"), nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid SFEN status = %d, want 400", rec.Code)
+	}
+}
+
+func TestAnalysisRejectsUnreachableSFEN(t *testing.T) {
+	srv := newTestServer(t, Config{})
+	for _, sfen := range []string{
+		"5/5/5/5/5/K4 b - 1",   // Top has no king.
+		"k4/5/5/5/5/+K4 b - 1", // Kings cannot promote.
+		"k4/5/5/5/5/K4 w K 1",  // Kings cannot be held in hand.
+	} {
+		for _, path := range []string{"/api/hint", "/api/mate", "/api/attackmap"} {
+			rec := doJSON(t, srv.Handler(), http.MethodGet, path+"?sfen="+url.QueryEscape(sfen), nil)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("%s with %q: status = %d, want 400: %s", path, sfen, rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestConfigHandMultiplierWeightsAnalysis(t *testing.T) {
+	hand := func(multiplier float64) int {
+		srv := newTestServer(t, Config{Evaluation: game.EvalConfig{HandMultiplier: multiplier}})
+		rec := doJSON(t, srv.Handler(), http.MethodGet, "/api/hint?sfen="+url.QueryEscape("k4/5/5/5/5/4K b G 1"), nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("hint failed: %d %s", rec.Code, rec.Body.String())
+		}
+		var resp hintResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp.Breakdown.Hand
+	}
+	if base, doubled := hand(0), hand(2); doubled != 2*base || base <= 0 {
+		t.Fatalf("hand term = %d with multiplier 2, want twice the default %d", doubled, base)
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"time"
+
+	"gorogoro/game"
+)
+
+const (
+	// timeoutResign ends the game in the idle human's opponent's favour.
+	timeoutResign = "resign"
+	// timeoutRandom plays a random legal move for the idle human.
+	timeoutRandom = "random"
+	reasonTimeout = "timeout"
+)
+
+// moveTimeoutState is the inactivity timer for human turns. generation invalidates timers
+// that fire after being replaced; forfeited records a game lost on time.
+type moveTimeoutState struct {
+	timer      *time.Timer
+	generation int
+	forfeited  bool
+	loser      game.Player
+}
+
+// armMoveTimeoutLocked restarts the inactivity countdown when a human is to move. Any
+// earlier countdown is cancelled, so each human action starts a fresh one.
+func (s *Server) armMoveTimeoutLocked() {
+	s.timeout.generation++
+	if s.timeout.timer != nil {
+		s.timeout.timer.Stop()
+		s.timeout.timer = nil
+	}
+	if s.moveTimeout <= 0 || s.auto.active || s.engines[s.game.Turn] != nil || s.resultLocked().Over {
+		return
+	}
+	generation := s.timeout.generation
+	s.timeout.timer = time.AfterFunc(s.moveTimeout, func() { s.onMoveTimeout(generation) })
+}
+
+func (s *Server) onMoveTimeout(generation int) {
+	s.mu.Lock()
+	if generation != s.timeout.generation || s.auto.active || s.engines[s.game.Turn] != nil || s.resultLocked().Over {
+		s.mu.Unlock()
+		return
+	}
+	player := s.game.Turn
+	if s.timeoutAction != timeoutRandom {
+		s.timeout.forfeited = true
+		s.timeout.loser = player
+		s.stopPonderLocked()
+		s.finishGameLocked()
+		s.logger.Info("human player timed out", "player", playerKey(player))
+		s.mu.Unlock()
+		return
+	}
+
+	mv, err := game.NewRandomEngine(time.Now().UnixNano()).NextMove(s.game)
+	if err == nil {
+		var next game.GameState
+		if next, err = applyEngineMove(s.game, mv); err == nil {
+			s.game = next
+			s.recordMove(player, mv, s.makeBoardPayload(s.game))
+			if s.resultLocked().Over {
+				s.finishGameLocked()
+			}
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.logger.Error("failed to play timeout move", "err", err)
+		return
+	}
+	if _, err := s.respondWithEngines(); err != nil {
+		s.logger.Error("engine reply after timeout move failed", "err", err)
+	}
+	s.mu.Lock()
+	s.startPonderLocked()
+	s.armMoveTimeoutLocked()
+	s.mu.Unlock()
+}